@@ -27,6 +27,8 @@ var EnvReservedPorts = []string{
 	HTTPServerListenPort,
 	HTTPSServerListenPort,
 	CaddyAPIListenPort,
+	ErrorPageServerListenPort,
+	InspectorServerListenPort,
 }
 
 func GetVSCodeWorkspaceConfigFilePath(envName string) string {
@@ -4,4 +4,16 @@ const (
 	HTTPServerListenPort  = "80"
 	HTTPSServerListenPort = "443"
 	CaddyAPIListenPort    = "2019"
+
+	CaddyUserName          = "caddy"
+	CaddyAccessLogsDirPath = "/var/log/eleven/access-logs"
+
+	// Used when Caddy is not installed
+	HTTPProxyCertsDirPath = ElevenAgentConfigDirPath + "/certs"
+
+	ErrorPageServerListenPort = "2020"
+	ErrorPageServerListenAddr = "127.0.0.1:" + ErrorPageServerListenPort
+
+	InspectorServerListenPort = "2021"
+	InspectorServerListenAddr = "127.0.0.1:" + InspectorServerListenPort
 )
@@ -7,5 +7,41 @@ const (
 	SSHServerHostKeyFilePath   = ElevenUserHomeDirPath + "/.ssh/eleven-ssh-server-host-key"
 
 	ElevenUserAuthorizedSSHKeysFilePath = ElevenUserHomeDirPath + "/.ssh/authorized_keys"
+	ElevenUserTrustedSSHCAKeysFilePath  = ElevenUserHomeDirPath + "/.ssh/trusted_user_ca_keys"
 	GitHubPublicSSHKeyFilePath          = ElevenUserHomeDirPath + "/.ssh/" + ElevenUserName + "-github.pub"
+
+	// Installed with the "openssh-sftp-server" package
+	SFTPServerBinaryPath = "/usr/lib/openssh/sftp-server"
+
+	SessionRecordingsDirPath = ElevenAgentConfigDirPath + "/recordings"
 )
+
+// Environment variables that clients could pass
+// to SSH sessions (like OpenSSH "AcceptEnv")
+var SSHServerAcceptedEnv = []string{
+	"LANG",
+	"LANGUAGE",
+	"LC_*",
+	"COLORTERM",
+	"TERM_PROGRAM",
+	"TERM_PROGRAM_VERSION",
+	"GIT_PROTOCOL",
+}
+
+// Destinations ("<host>:<port>" with "*" as wildcard)
+// that clients could reach with local port forwarding
+var SSHServerAllowedLocalForwards = []string{
+	"*:*",
+}
+
+// Addresses ("<host>:<port>" with "*" as wildcard)
+// that clients could bind with reverse port forwarding
+var SSHServerAllowedReverseForwards = []string{
+	"*:*",
+}
+
+// Unix sockets that clients could forward to.
+// Used by the Eleven CLI to reach the GRPC server.
+var SSHServerAllowedSocketPaths = []string{
+	GRPCServerAddr,
+}
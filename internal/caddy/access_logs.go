@@ -0,0 +1,261 @@
+package caddy
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	configLoggingDefaultLogKey  = "default"
+	configLoggingAccessLogger   = "http.log.access"
+	configLoggingAccessLogsExt  = ".log"
+	configLoggingAccessLogsMode = "file"
+	configLoggingAccessLogsFmt  = "json"
+)
+
+type ConfigLoggingApp struct {
+	Logs map[string]ConfigLog `json:"logs"`
+}
+
+type ConfigLog struct {
+	Writer  *ConfigLogWriter  `json:"writer,omitempty"`
+	Encoder *ConfigLogEncoder `json:"encoder,omitempty"`
+	Include []string          `json:"include,omitempty"`
+	Exclude []string          `json:"exclude,omitempty"`
+}
+
+type ConfigLogWriter struct {
+	Output   string `json:"output"`
+	Filename string `json:"filename"`
+}
+
+type ConfigLogEncoder struct {
+	Format string `json:"format"`
+}
+
+type ConfigHTTPServerLogs struct {
+	DefaultLoggerName string            `json:"default_logger_name,omitempty"`
+	LoggerNames       map[string]string `json:"logger_names,omitempty"`
+	SkipUnmappedHosts bool              `json:"skip_unmapped_hosts,omitempty"`
+}
+
+// AccessLogEntry represents one request
+// logged by Caddy for a served port.
+type AccessLogEntry struct {
+	Port       string
+	Timestamp  time.Time
+	RemoteAddr string
+	Proto      string
+	Method     string
+	Host       string
+	URI        string
+	Status     int
+	Size       int64
+	Duration   time.Duration
+}
+
+// caddyAccessLogLine matches the JSON
+// structure of the Caddy access logs.
+// See: https://caddyserver.com/docs/logging#structured-logs
+type caddyAccessLogLine struct {
+	Timestamp float64 `json:"ts"`
+	Request   struct {
+		RemoteIP   string `json:"remote_ip,omitempty"`
+		RemotePort string `json:"remote_port,omitempty"`
+		RemoteAddr string `json:"remote_addr,omitempty"`
+		Proto      string `json:"proto"`
+		Method     string `json:"method"`
+		Host       string `json:"host"`
+		URI        string `json:"uri"`
+	} `json:"request"`
+	Status   int     `json:"status"`
+	Size     int64   `json:"size"`
+	Duration float64 `json:"duration"`
+}
+
+// UpdateConfigToEnableAccessLogs makes Caddy write
+// the access logs of each served port in its own file,
+// located in the passed directory.
+func UpdateConfigToEnableAccessLogs(
+	config *Config,
+	ports map[string]*proto.EnvServedPortBindings,
+	logsDirPath string,
+) {
+
+	servedPorts := buildServedPorts(ports)
+
+	logs := map[string]ConfigLog{
+		// Access logs are written in dedicated
+		// files and must not pollute the default log
+		configLoggingDefaultLogKey: {
+			Exclude: []string{configLoggingAccessLogger},
+		},
+	}
+
+	for _, servedPort := range servedPorts {
+		port := servedPort.port
+		loggerName := buildAccessLoggerName(port)
+		portHasBindings := false
+
+		for bindingsIndex, bindings := range servedPort.bindings {
+			if len(bindings.httpsDomains) > 0 {
+				portHasBindings = true
+
+				setServerLoggerForHosts(
+					config,
+					configServersHTTPSDomainsKey,
+					bindings.httpsDomains,
+					loggerName,
+				)
+
+				setServerLoggerForHosts(
+					config,
+					configServersHTTPDomainsKey,
+					bindings.httpDomains,
+					loggerName,
+				)
+			}
+
+			if len(bindings.ports) == 0 {
+				continue
+			}
+
+			portHasBindings = true
+
+			serverKey := buildPortsServerKey(port, bindingsIndex)
+			serverConfig, hasServerConfig := config.Apps.HTTP.Servers[serverKey]
+
+			if !hasServerConfig {
+				continue
+			}
+
+			serverConfig.Logs = &ConfigHTTPServerLogs{
+				DefaultLoggerName: loggerName,
+			}
+
+			config.Apps.HTTP.Servers[serverKey] = serverConfig
+		}
+
+		if !portHasBindings {
+			continue
+		}
+
+		logs[loggerName] = ConfigLog{
+			Writer: &ConfigLogWriter{
+				Output:   configLoggingAccessLogsMode,
+				Filename: GetAccessLogsFilePath(logsDirPath, port),
+			},
+			Encoder: &ConfigLogEncoder{
+				Format: configLoggingAccessLogsFmt,
+			},
+			Include: []string{configLoggingAccessLogger + "." + loggerName},
+		}
+	}
+
+	config.Apps.Logging = &ConfigLoggingApp{
+		Logs: logs,
+	}
+}
+
+func GetAccessLogsFilePath(logsDirPath, port string) string {
+	return filepath.Join(logsDirPath, port+configLoggingAccessLogsExt)
+}
+
+func ParseAccessLogLine(port string, line []byte) (*AccessLogEntry, error) {
+	var parsedLine caddyAccessLogLine
+	err := json.Unmarshal(line, &parsedLine)
+
+	if err != nil {
+		return nil, err
+	}
+
+	timestampSeconds, timestampFraction := math.Modf(parsedLine.Timestamp)
+
+	remoteAddr := parsedLine.Request.RemoteAddr
+
+	// Caddy >= 2.5 splits the remote address in two fields
+	if len(parsedLine.Request.RemoteIP) > 0 {
+		remoteAddr = net.JoinHostPort(
+			parsedLine.Request.RemoteIP,
+			parsedLine.Request.RemotePort,
+		)
+	}
+
+	return &AccessLogEntry{
+		Port: port,
+		Timestamp: time.Unix(
+			int64(timestampSeconds),
+			int64(timestampFraction*float64(time.Second)),
+		),
+		RemoteAddr: remoteAddr,
+		Proto:      parsedLine.Request.Proto,
+		Method:     parsedLine.Request.Method,
+		Host:       parsedLine.Request.Host,
+		URI:        parsedLine.Request.URI,
+		Status:     parsedLine.Status,
+		Size:       parsedLine.Size,
+		Duration:   time.Duration(parsedLine.Duration * float64(time.Second)),
+	}, nil
+}
+
+// FormatAccessLogLine is the inverse of "ParseAccessLogLine".
+// It lets other proxies write access logs that could be
+// read like the ones written by Caddy.
+func FormatAccessLogLine(entry *AccessLogEntry) ([]byte, error) {
+	var line caddyAccessLogLine
+
+	line.Timestamp = float64(entry.Timestamp.UnixNano()) / float64(time.Second)
+	line.Request.RemoteAddr = entry.RemoteAddr
+	line.Request.Proto = entry.Proto
+	line.Request.Method = entry.Method
+	line.Request.Host = entry.Host
+	line.Request.URI = entry.URI
+	line.Status = entry.Status
+	line.Size = entry.Size
+	line.Duration = entry.Duration.Seconds()
+
+	return json.Marshal(line)
+}
+
+func buildAccessLoggerName(port string) string {
+	return configServersPortsKeyPrefix + port
+}
+
+func setServerLoggerForHosts(
+	config *Config,
+	serverKey string,
+	hosts []string,
+	loggerName string,
+) {
+
+	if len(hosts) == 0 {
+		return
+	}
+
+	serverConfig, hasServerConfig := config.Apps.HTTP.Servers[serverKey]
+
+	if !hasServerConfig {
+		return
+	}
+
+	if serverConfig.Logs == nil {
+		serverConfig.Logs = &ConfigHTTPServerLogs{
+			LoggerNames: map[string]string{},
+			// Prevent requests made to hosts
+			// not bound to a port (like the ones
+			// used to check domain reachability) to be logged
+			SkipUnmappedHosts: true,
+		}
+	}
+
+	for _, host := range hosts {
+		serverConfig.Logs.LoggerNames[host] = loggerName
+	}
+
+	config.Apps.HTTP.Servers[serverKey] = serverConfig
+}
@@ -0,0 +1,320 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/env"
+)
+
+const (
+	accessLogsTailerPollInterval = 400 * time.Millisecond
+	accessLogsTailerChunkSize    = 4096
+)
+
+type accessLogsFile struct {
+	port        string
+	file        *os.File
+	offset      int64
+	pendingLine []byte
+}
+
+// TailAccessLogs calls the passed handler for each new
+// access log entry written for the passed ports
+// (or for all the served ports if no ports are passed).
+// The last "tailLines" entries of each file are handled first.
+// It returns when the context is done or when the handler fails.
+func TailAccessLogs(
+	ctx context.Context,
+	logsDirPath string,
+	ports []string,
+	tailLines int,
+	entryHandler func(*AccessLogEntry) error,
+) error {
+
+	openedFiles := map[string]*accessLogsFile{}
+
+	defer func() {
+		for _, openedFile := range openedFiles {
+			openedFile.file.Close()
+		}
+	}()
+
+	isFirstScan := true
+
+	for {
+		portsToTail := ports
+
+		if len(portsToTail) == 0 {
+			servedPorts, err := listPortsWithAccessLogs(logsDirPath)
+
+			if err != nil {
+				return err
+			}
+
+			portsToTail = servedPorts
+		}
+
+		for _, port := range portsToTail {
+			openedFile, err := openOrReopenAccessLogsFile(
+				logsDirPath,
+				port,
+				openedFiles[port],
+				isFirstScan,
+				tailLines,
+			)
+
+			if err != nil {
+				return err
+			}
+
+			if openedFile == nil { // Nothing logged yet
+				continue
+			}
+
+			openedFiles[port] = openedFile
+
+			err = readAccessLogsFile(openedFile, entryHandler)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		isFirstScan = false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(accessLogsTailerPollInterval):
+		}
+	}
+}
+
+func listPortsWithAccessLogs(logsDirPath string) ([]string, error) {
+	logsFilePaths, err := filepath.Glob(
+		filepath.Join(logsDirPath, "*"+configLoggingAccessLogsExt),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []string{}
+
+	for _, logsFilePath := range logsFilePaths {
+		port := strings.TrimSuffix(
+			filepath.Base(logsFilePath),
+			configLoggingAccessLogsExt,
+		)
+
+		// Skip files rotated by Caddy
+		// (named like "8080-2022-09-26T17-16-39.371.log")
+		if !env.IsValidServedPortName(port) {
+			continue
+		}
+
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
+
+func openOrReopenAccessLogsFile(
+	logsDirPath string,
+	port string,
+	openedFile *accessLogsFile,
+	isFirstScan bool,
+	tailLines int,
+) (*accessLogsFile, error) {
+
+	logsFilePath := GetAccessLogsFilePath(logsDirPath, port)
+	logsFileInfo, err := os.Stat(logsFilePath)
+
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		return openedFile, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if openedFile != nil {
+		openedFileInfo, err := openedFile.file.Stat()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if os.SameFile(openedFileInfo, logsFileInfo) {
+			// File truncated
+			if logsFileInfo.Size() < openedFile.offset {
+				openedFile.offset = 0
+				openedFile.pendingLine = nil
+			}
+
+			return openedFile, nil
+		}
+
+		// File rotated. We don't try to read the end of the
+		// rotated file given that Caddy only rotates full files.
+		openedFile.file.Close()
+	}
+
+	file, err := os.Open(logsFilePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	newOpenedFile := &accessLogsFile{
+		port: port,
+		file: file,
+	}
+
+	// Files created after the first scan
+	// only contain new entries
+	if !isFirstScan || openedFile != nil {
+		return newOpenedFile, nil
+	}
+
+	newOpenedFile.offset, err = findTailOffset(
+		file,
+		logsFileInfo.Size(),
+		tailLines,
+	)
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return newOpenedFile, nil
+}
+
+// findTailOffset returns the offset of the
+// start of the last "tailLines" lines of the passed file.
+func findTailOffset(
+	file *os.File,
+	fileSize int64,
+	tailLines int,
+) (int64, error) {
+
+	offset := fileSize
+
+	if tailLines <= 0 {
+		return offset, nil
+	}
+
+	chunk := make([]byte, accessLogsTailerChunkSize)
+	newLinesCount := 0
+
+	for offset > 0 {
+		chunkSize := int64(len(chunk))
+
+		if offset < chunkSize {
+			chunkSize = offset
+		}
+
+		offset -= chunkSize
+
+		_, err := file.ReadAt(chunk[:chunkSize], offset)
+
+		if err != nil {
+			return 0, err
+		}
+
+		for i := chunkSize - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				continue
+			}
+
+			// Last line ends with a new line character
+			if offset+i == fileSize-1 {
+				continue
+			}
+
+			newLinesCount++
+
+			if newLinesCount == tailLines {
+				return offset + i + 1, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+func readAccessLogsFile(
+	openedFile *accessLogsFile,
+	entryHandler func(*AccessLogEntry) error,
+) error {
+
+	chunk := make([]byte, accessLogsTailerChunkSize)
+
+	for {
+		readBytes, err := openedFile.file.ReadAt(chunk, openedFile.offset)
+
+		if readBytes > 0 {
+			openedFile.offset += int64(readBytes)
+			openedFile.pendingLine = append(openedFile.pendingLine, chunk[:readBytes]...)
+
+			if err := handleAccessLogsLines(openedFile, entryHandler); err != nil {
+				return err
+			}
+		}
+
+		if err != nil && errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func handleAccessLogsLines(
+	openedFile *accessLogsFile,
+	entryHandler func(*AccessLogEntry) error,
+) error {
+
+	for {
+		newLineIndex := bytes.IndexByte(openedFile.pendingLine, '\n')
+
+		// Line not fully written yet
+		if newLineIndex == -1 {
+			return nil
+		}
+
+		line := openedFile.pendingLine[:newLineIndex]
+		openedFile.pendingLine = openedFile.pendingLine[newLineIndex+1:]
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry, err := ParseAccessLogLine(openedFile.port, line)
+
+		if err != nil {
+			log.Printf(
+				"[Access logs] Error when parsing log line for port %s: %v",
+				openedFile.port,
+				err,
+			)
+
+			continue
+		}
+
+		if err := entryHandler(entry); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package caddy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestListPortsWithAccessLogs(t *testing.T) {
+	logsDirPath := t.TempDir()
+
+	logsFiles := []string{
+		"8080.log",
+		"docs.log",
+		"8080-2022-09-26T17-16-39.371.log",
+		"docs-2022-09-26T17-16-39.371.log",
+	}
+
+	for _, logsFile := range logsFiles {
+		err := os.WriteFile(filepath.Join(logsDirPath, logsFile), nil, 0600)
+
+		if err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+	}
+
+	ports, err := listPortsWithAccessLogs(logsDirPath)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	sort.Strings(ports)
+	expectedPorts := []string{"8080", "docs"}
+
+	if !reflect.DeepEqual(ports, expectedPorts) {
+		t.Fatalf(
+			"expected ports to equal '%+v', got '%+v'",
+			expectedPorts,
+			ports,
+		)
+	}
+}
@@ -0,0 +1,336 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestUpdateConfigToEnableAccessLogs(t *testing.T) {
+	testCases := []struct {
+		test           string
+		servedPorts    map[string]*proto.EnvServedPortBindings
+		logsDirPath    string
+		expectedConfig string
+	}{
+		{
+			test:        "with no served ports",
+			servedPorts: map[string]*proto.EnvServedPortBindings{},
+			logsDirPath: "/var/log/eleven/access-logs",
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{}
+					},
+					"logging":{
+						"logs":{
+							"default":{
+								"exclude":[
+									"http.log.access"
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with domain and port bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:           "a.domain.com",
+							Type:            string(entities.EnvServedPortBindingTypeDomain),
+							RedirectToHttps: true,
+						},
+					},
+				},
+
+				"11000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:           "b.domain.com",
+							Type:            string(entities.EnvServedPortBindingTypeDomain),
+							RedirectToHttps: false,
+						},
+
+						{
+							Value: "11000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+
+				"6000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "6000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			logsDirPath: "/var/log/eleven/access-logs",
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"https-domains":{
+								"listen":[
+									":443"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"b.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:11000"
+													}
+												]
+											}
+										]
+									},
+									{
+										"match":[
+											{
+												"host":[
+													"a.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								],
+								"logs":{
+									"logger_names":{
+										"a.domain.com":"port-8080",
+										"b.domain.com":"port-11000"
+									},
+									"skip_unmapped_hosts":true
+								}
+							},
+							"http-domains":{
+								"listen":[
+									":80"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"b.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:11000"
+													}
+												]
+											}
+										]
+									}
+								],
+								"logs":{
+									"logger_names":{
+										"b.domain.com":"port-11000"
+									},
+									"skip_unmapped_hosts":true
+								}
+							},
+							"port-11000":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:11000"
+													}
+												]
+											}
+										]
+									}
+								],
+								"logs":{
+									"default_logger_name":"port-11000"
+								}
+							}
+						}
+					},
+					"logging":{
+						"logs":{
+							"default":{
+								"exclude":[
+									"http.log.access"
+								]
+							},
+							"port-11000":{
+								"writer":{
+									"output":"file",
+									"filename":"/var/log/eleven/access-logs/11000.log"
+								},
+								"encoder":{
+									"format":"json"
+								},
+								"include":[
+									"http.log.access.port-11000"
+								]
+							},
+							"port-8080":{
+								"writer":{
+									"output":"file",
+									"filename":"/var/log/eleven/access-logs/8080.log"
+								},
+								"encoder":{
+									"format":"json"
+								},
+								"include":[
+									"http.log.access.port-8080"
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			caddyConfig := CreateConfigFromServedPorts(tc.servedPorts)
+
+			UpdateConfigToEnableAccessLogs(
+				caddyConfig,
+				tc.servedPorts,
+				tc.logsDirPath,
+			)
+
+			var expectedConfig *Config
+			err := json.Unmarshal([]byte(tc.expectedConfig), &expectedConfig)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(caddyConfig, expectedConfig) {
+				t.Fatalf(
+					"expected config to equal '%+v', got '%+v'",
+					expectedConfig,
+					caddyConfig,
+				)
+			}
+		})
+	}
+}
+
+func TestParseAccessLogLine(t *testing.T) {
+	testCases := []struct {
+		test          string
+		port          string
+		line          string
+		expectedEntry *AccessLogEntry
+		expectError   bool
+	}{
+		{
+			test: "with remote IP and port",
+			port: "8080",
+			line: `{"level":"info","ts":1664212599.5,"logger":"http.log.access.port-8080","msg":"handled request","request":{"remote_ip":"82.65.12.3","remote_port":"53412","proto":"HTTP/2.0","method":"POST","host":"api.domain.com","uri":"/webhooks?a=b","headers":{}},"bytes_read":12,"user_id":"","duration":0.25,"size":2,"status":201,"resp_headers":{}}`,
+			expectedEntry: &AccessLogEntry{
+				Port:       "8080",
+				Timestamp:  time.Unix(1664212599, int64(500*time.Millisecond)),
+				RemoteAddr: "82.65.12.3:53412",
+				Proto:      "HTTP/2.0",
+				Method:     "POST",
+				Host:       "api.domain.com",
+				URI:        "/webhooks?a=b",
+				Status:     201,
+				Size:       2,
+				Duration:   250 * time.Millisecond,
+			},
+		},
+
+		{
+			test: "with remote address",
+			port: "3000",
+			line: `{"level":"info","ts":1664212599,"logger":"http.log.access.port-3000","msg":"handled request","request":{"remote_addr":"82.65.12.3:53412","proto":"HTTP/1.1","method":"GET","host":"localhost:8000","uri":"/"},"duration":1,"size":0,"status":502}`,
+			expectedEntry: &AccessLogEntry{
+				Port:       "3000",
+				Timestamp:  time.Unix(1664212599, 0),
+				RemoteAddr: "82.65.12.3:53412",
+				Proto:      "HTTP/1.1",
+				Method:     "GET",
+				Host:       "localhost:8000",
+				URI:        "/",
+				Status:     502,
+				Size:       0,
+				Duration:   1 * time.Second,
+			},
+		},
+
+		{
+			test:        "with invalid JSON",
+			port:        "3000",
+			line:        `{"level":"info",`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			entry, err := ParseAccessLogLine(tc.port, []byte(tc.line))
+
+			if tc.expectError && err == nil {
+				t.Fatalf("expected error, got nothing")
+			}
+
+			if !tc.expectError && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(entry, tc.expectedEntry) {
+				t.Fatalf(
+					"expected entry to equal '%+v', got '%+v'",
+					tc.expectedEntry,
+					entry,
+				)
+			}
+		})
+	}
+}
@@ -19,16 +19,45 @@ func NewAPI() *API {
 }
 
 func (a *API) Load(config *Config) error {
-	configAsJson, err := json.Marshal(config)
+	return a.sendRequest("POST", "/load", config, nil)
+}
+
+// GetRawConfig returns the running config
+// decoded as a generic JSON value.
+func (a *API) GetRawConfig() (interface{}, error) {
+	var rawConfig interface{}
+	err := a.sendRequest("GET", "/config/", nil, &rawConfig)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return rawConfig, nil
+}
+
+func (a *API) sendRequest(
+	method string,
+	path string,
+	body interface{},
+	response interface{},
+) error {
+
+	var reqBody io.Reader
+
+	if body != nil {
+		bodyAsJson, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewBuffer(bodyAsJson)
 	}
 
 	req, err := http.NewRequest(
-		"POST",
-		APIEndpoint+"/load",
-		bytes.NewBuffer(configAsJson),
+		method,
+		APIEndpoint+path,
+		reqBody,
 	)
 
 	if err != nil {
@@ -59,5 +88,9 @@ func (a *API) Load(config *Config) error {
 		return fmt.Errorf("caddy API error: %s", body)
 	}
 
-	return nil
+	if response == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
 }
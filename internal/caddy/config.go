@@ -1,24 +1,38 @@
 package caddy
 
 import (
-	"net"
 	"sort"
+	"strconv"
 
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/netsim"
 	"github.com/eleven-sh/agent/proto"
 	"github.com/eleven-sh/eleven/entities"
+	goproto "google.golang.org/protobuf/proto"
 )
 
 const (
-	configHTTPAppKey             = "http"
 	configServersHTTPSDomainsKey = "https-domains"
 	configServersHTTPDomainsKey  = "http-domains"
 	configServersPortsKeyPrefix  = "port-"
 	configServersRPHandler       = "reverse_proxy"
 	configServersStaticHandler   = "static_response"
+	configServersHeadersHandler  = "headers"
+	configServersEncodeHandler   = "encode"
+	configServersSubrouteHandler = "subroute"
+	configServersRewriteHandler  = "rewrite"
+	configServersFileHandler     = "file_server"
+	configServersErrorHandler    = "error"
 )
 
 type Config struct {
-	Apps map[string]ConfigHTTPApp `json:"apps"`
+	Apps ConfigApps `json:"apps"`
+}
+
+type ConfigApps struct {
+	HTTP    ConfigHTTPApp     `json:"http"`
+	Logging *ConfigLoggingApp `json:"logging,omitempty"`
+	TLS     *ConfigTLSApp     `json:"tls,omitempty"`
 }
 
 type ConfigHTTPApp struct {
@@ -30,6 +44,8 @@ type ConfigHTTPServers map[string]ConfigHTTPServer
 type ConfigHTTPServer struct {
 	Listen []string                `json:"listen"`
 	Routes []ConfigHTTPServerRoute `json:"routes"`
+	Logs   *ConfigHTTPServerLogs   `json:"logs,omitempty"`
+	Errors *ConfigHTTPServerErrors `json:"errors,omitempty"`
 }
 
 type ConfigHTTPServerRoute struct {
@@ -37,29 +53,88 @@ type ConfigHTTPServerRoute struct {
 	Handle []ConfigHTTPServerHandle `json:"handle"`
 }
 
+type ConfigHTTPServerErrors struct {
+	Routes []ConfigHTTPServerRoute `json:"routes"`
+}
+
 type ConfigHTTPServerMatch struct {
-	Host []string `json:"host"`
+	Host       []string              `json:"host,omitempty"`
+	Method     []string              `json:"method,omitempty"`
+	Header     map[string][]string   `json:"header,omitempty"`
+	Expression string                `json:"expression,omitempty"`
+	File       *ConfigHTTPServerFile `json:"file,omitempty"`
+}
+
+type ConfigHTTPServerFile struct {
+	Root     string   `json:"root,omitempty"`
+	TryFiles []string `json:"try_files,omitempty"`
 }
 
 type ConfigHTTPServerHandle struct {
-	Handler   string                      `json:"handler"`
-	Upstreams []ConfigHTTPServerUpstreams `json:"upstreams,omitempty"`
-	Body      string                      `json:"body,omitempty"`
+	Handler        string                              `json:"handler"`
+	Upstreams      []ConfigHTTPServerUpstreams         `json:"upstreams,omitempty"`
+	LoadBalancing  *ConfigHTTPServerLoadBalancing      `json:"load_balancing,omitempty"`
+	HealthChecks   *ConfigHTTPServerHealthChecks       `json:"health_checks,omitempty"`
+	Body           string                              `json:"body,omitempty"`
+	StatusCode     int                                 `json:"status_code,omitempty"`
+	Request        *ConfigHTTPServerHeaderOps          `json:"request,omitempty"`
+	Response       *ConfigHTTPServerHeaderOps          `json:"response,omitempty"`
+	Encodings      map[string]ConfigHTTPServerEncoding `json:"encodings,omitempty"`
+	Prefer         []string                            `json:"prefer,omitempty"`
+	Routes         []ConfigHTTPServerRoute             `json:"routes,omitempty"`
+	URI            string                              `json:"uri,omitempty"`
+	Root           string                              `json:"root,omitempty"`
+	Headers        map[string][]string                 `json:"headers,omitempty"`
+	Abort          bool                                `json:"abort,omitempty"`
+	Error          string                              `json:"error,omitempty"`
+	HandleResponse []ConfigHTTPServerResponseHandler   `json:"handle_response,omitempty"`
+}
+
+type ConfigHTTPServerResponseHandler struct {
+	Match  *ConfigHTTPServerResponseMatch `json:"match,omitempty"`
+	Routes []ConfigHTTPServerRoute        `json:"routes"`
+}
+
+type ConfigHTTPServerResponseMatch struct {
+	Headers map[string][]string `json:"headers,omitempty"`
 }
 
 type ConfigHTTPServerUpstreams struct {
 	Dial string `json:"dial"`
 }
 
+type ConfigHTTPServerHeaderOps struct {
+	Add      map[string][]string `json:"add,omitempty"`
+	Set      map[string][]string `json:"set,omitempty"`
+	Delete   []string            `json:"delete,omitempty"`
+	Deferred bool                `json:"deferred,omitempty"`
+}
+
+type ConfigHTTPServerEncoding struct{}
+
 type servedPorts []servedPort
 type servedPort struct {
-	port     string
-	bindings servedPortBindings
+	port string
+	// Bindings are grouped by HTTP options
+	// and target given that bindings with different
+	// options or targets cannot share the same route
+	bindings []servedPortBindings
 }
 type servedPortBindings struct {
-	httpsDomains []string
-	httpDomains  []string
-	ports        []string
+	httpOptions *proto.EnvServedPortBindingHTTPOptions
+	// Set for directory and redirect bindings only
+	directory *proto.EnvServedPortBindingDirectory
+	redirect  *proto.EnvServedPortBindingRedirect
+	// Set for domain bindings with multiple upstreams only
+	upstreams   []*proto.EnvServedPortBindingUpstream
+	healthCheck *proto.EnvServedPortBindingHealthCheck
+	// Set for bindings proxied to their served port only
+	networkConditions *proto.EnvServedPortBindingNetworkConditions
+	// Set for bindings proxied to a Unix socket only
+	unixSocketPath string
+	httpsDomains   []string
+	httpDomains    []string
+	ports          []string
 }
 
 func CreateConfigFromServedPorts(
@@ -71,96 +146,81 @@ func CreateConfigFromServedPorts(
 
 	for _, servedPort := range servedPorts {
 		port := servedPort.port
-		bindings := servedPort.bindings
 
-		if len(bindings.httpsDomains) > 0 {
-			httpsConfig, hasHTTPSConfig := httpServersConfig[configServersHTTPSDomainsKey]
+		for bindingsIndex, bindings := range servedPort.bindings {
+			if len(bindings.httpsDomains) > 0 {
+				httpsConfig, hasHTTPSConfig := httpServersConfig[configServersHTTPSDomainsKey]
 
-			if !hasHTTPSConfig {
-				httpsConfig = ConfigHTTPServer{
-					Listen: []string{":443"},
-					Routes: []ConfigHTTPServerRoute{},
+				if !hasHTTPSConfig {
+					httpsConfig = ConfigHTTPServer{
+						Listen: []string{":443"},
+						Routes: []ConfigHTTPServerRoute{},
+					}
 				}
-			}
 
-			newRoute := func(domains []string) ConfigHTTPServerRoute {
-				return ConfigHTTPServerRoute{
-					Match: []ConfigHTTPServerMatch{
-						{
-							Host: domains,
-						},
-					},
-					Handle: []ConfigHTTPServerHandle{
-						{
-							Handler: configServersRPHandler,
-							Upstreams: []ConfigHTTPServerUpstreams{
-								{
-									Dial: net.JoinHostPort(
-										"127.0.0.1",
-										port,
-									),
-								},
+				newRoute := func(domains []string, isHTTPS bool) ConfigHTTPServerRoute {
+					return ConfigHTTPServerRoute{
+						Match: []ConfigHTTPServerMatch{
+							{
+								Host: domains,
 							},
 						},
-					},
+						Handle: buildRouteHandlers(
+							port,
+							bindings,
+							isHTTPS,
+						),
+					}
 				}
-			}
 
-			httpsConfig.Routes = append(
-				httpsConfig.Routes,
-				newRoute(bindings.httpsDomains),
-			)
+				httpsConfig.Routes = append(
+					httpsConfig.Routes,
+					newRoute(bindings.httpsDomains, true),
+				)
 
-			httpServersConfig[configServersHTTPSDomainsKey] = httpsConfig
+				httpServersConfig[configServersHTTPSDomainsKey] = httpsConfig
 
-			if len(bindings.httpDomains) > 0 {
-				httpConfig, hasHTTPConfig := httpServersConfig[configServersHTTPDomainsKey]
+				if len(bindings.httpDomains) > 0 {
+					httpConfig, hasHTTPConfig := httpServersConfig[configServersHTTPDomainsKey]
 
-				if !hasHTTPConfig {
-					httpConfig = ConfigHTTPServer{
-						Listen: []string{":80"},
-						Routes: []ConfigHTTPServerRoute{},
+					if !hasHTTPConfig {
+						httpConfig = ConfigHTTPServer{
+							Listen: []string{":80"},
+							Routes: []ConfigHTTPServerRoute{},
+						}
 					}
-				}
 
-				httpConfig.Routes = append(
-					httpConfig.Routes,
-					newRoute(bindings.httpDomains),
-				)
+					httpConfig.Routes = append(
+						httpConfig.Routes,
+						newRoute(bindings.httpDomains, false),
+					)
 
-				httpServersConfig[configServersHTTPDomainsKey] = httpConfig
+					httpServersConfig[configServersHTTPDomainsKey] = httpConfig
+				}
 			}
-		}
 
-		if len(bindings.ports) == 0 {
-			continue
-		}
+			if len(bindings.ports) == 0 {
+				continue
+			}
 
-		httpServersConfig[configServersPortsKeyPrefix+port] = ConfigHTTPServer{
-			Listen: bindings.ports,
-			Routes: []ConfigHTTPServerRoute{
-				{
-					Handle: []ConfigHTTPServerHandle{
-						{
-							Handler: configServersRPHandler,
-							Upstreams: []ConfigHTTPServerUpstreams{
-								{
-									Dial: net.JoinHostPort(
-										"127.0.0.1",
-										port,
-									),
-								},
-							},
-						},
+			httpServersConfig[buildPortsServerKey(port, bindingsIndex)] = ConfigHTTPServer{
+				Listen: bindings.ports,
+				Routes: []ConfigHTTPServerRoute{
+					{
+						Handle: buildRouteHandlers(
+							port,
+							bindings,
+							false,
+						),
 					},
 				},
-			},
+			}
 		}
 	}
 
 	return &Config{
-		Apps: map[string]ConfigHTTPApp{
-			configHTTPAppKey: {
+		Apps: ConfigApps{
+			HTTP: ConfigHTTPApp{
 				Servers: httpServersConfig,
 			},
 		},
@@ -173,7 +233,7 @@ func UpdateConfigToCheckDomainReachability(
 	uniqueID string,
 ) {
 
-	httpConfig, hasHTTPConfig := config.Apps[configHTTPAppKey].Servers[configServersHTTPDomainsKey]
+	httpConfig, hasHTTPConfig := config.Apps.HTTP.Servers[configServersHTTPDomainsKey]
 
 	if !hasHTTPConfig {
 		httpConfig = ConfigHTTPServer{
@@ -197,7 +257,17 @@ func UpdateConfigToCheckDomainReachability(
 		},
 	})
 
-	config.Apps[configHTTPAppKey].Servers[configServersHTTPDomainsKey] = httpConfig
+	config.Apps.HTTP.Servers[configServersHTTPDomainsKey] = httpConfig
+}
+
+func buildPortsServerKey(port string, bindingsIndex int) string {
+	serverKey := configServersPortsKeyPrefix + port
+
+	if bindingsIndex > 0 {
+		serverKey += "-" + strconv.Itoa(bindingsIndex)
+	}
+
+	return serverKey
 }
 
 func buildServedPorts(
@@ -217,23 +287,89 @@ func buildServedPorts(
 
 	for _, port := range sortedPorts {
 		portBindings := ports[port]
-
-		httpsDomains := []string{}
-		httpDomains := []string{}
-		ports := []string{}
+		groupedBindings := []servedPortBindings{}
 
 		for _, binding := range portBindings.Bindings {
 
-			if binding.Type == string(entities.EnvServedPortBindingTypeDomain) {
+			// Non-HTTP bindings are served by the agent
+			if env.GetServedPortBindingProtocol(binding) != env.ConfigServedPortProtocolHTTP {
+				continue
+			}
+
+			isDomainBinding := env.IsDomainServedPortBinding(binding)
+
+			// Port already bound by user application
+			if !isDomainBinding && binding.Value == port {
+				continue
+			}
+
+			bindingHTTPOptions := getBindingHTTPOptions(binding)
+			bindingDirectory, bindingRedirect := getBindingTarget(binding)
+			var bindingUpstreams []*proto.EnvServedPortBindingUpstream
+			var bindingHealthCheck *proto.EnvServedPortBindingHealthCheck
+
+			if isDomainBinding && len(binding.Upstreams) > 0 {
+				bindingUpstreams = binding.Upstreams
+				bindingHealthCheck = binding.HealthCheck
+			}
+
+			var bindingNetworkConditions *proto.EnvServedPortBindingNetworkConditions
+
+			if netsim.IsSupported(port, binding) {
+				bindingNetworkConditions = netsim.GetConditions(binding)
+			}
+
+			bindingUnixSocketPath := ""
+
+			if bindingDirectory == nil && bindingRedirect == nil && bindingUpstreams == nil {
+				bindingUnixSocketPath = env.GetServedPortBindingUnixSocketPath(binding)
+			}
+
+			bindingsIndex := -1
 
-				httpsDomains = append(
-					httpsDomains,
+			for groupIndex, group := range groupedBindings {
+				if goproto.Equal(group.httpOptions, bindingHTTPOptions) &&
+					goproto.Equal(group.directory, bindingDirectory) &&
+					goproto.Equal(group.redirect, bindingRedirect) &&
+					areUpstreamsEqual(group.upstreams, bindingUpstreams) &&
+					goproto.Equal(group.healthCheck, bindingHealthCheck) &&
+					goproto.Equal(group.networkConditions, bindingNetworkConditions) &&
+					group.unixSocketPath == bindingUnixSocketPath {
+
+					bindingsIndex = groupIndex
+					break
+				}
+			}
+
+			if bindingsIndex == -1 {
+				groupedBindings = append(groupedBindings, servedPortBindings{
+					httpOptions:       bindingHTTPOptions,
+					directory:         bindingDirectory,
+					redirect:          bindingRedirect,
+					upstreams:         bindingUpstreams,
+					healthCheck:       bindingHealthCheck,
+					networkConditions: bindingNetworkConditions,
+					unixSocketPath:    bindingUnixSocketPath,
+					httpsDomains:      []string{},
+					httpDomains:       []string{},
+					ports:             []string{},
+				})
+
+				bindingsIndex = len(groupedBindings) - 1
+			}
+
+			bindings := &groupedBindings[bindingsIndex]
+
+			if isDomainBinding {
+
+				bindings.httpsDomains = append(
+					bindings.httpsDomains,
 					binding.Value,
 				)
 
 				if !binding.RedirectToHttps {
-					httpDomains = append(
-						httpDomains,
+					bindings.httpDomains = append(
+						bindings.httpDomains,
 						binding.Value,
 					)
 				}
@@ -241,23 +377,70 @@ func buildServedPorts(
 				continue
 			}
 
-			// Port already bound by user application
-			if binding.Value == port {
-				continue
-			}
-
-			ports = append(ports, ":"+binding.Value)
+			bindings.ports = append(bindings.ports, ":"+binding.Value)
 		}
 
 		servedPorts = append(servedPorts, servedPort{
-			port: port,
-			bindings: servedPortBindings{
-				httpsDomains: httpsDomains,
-				httpDomains:  httpDomains,
-				ports:        ports,
-			},
+			port:     port,
+			bindings: groupedBindings,
 		})
 	}
 
 	return servedPorts
 }
+
+// getBindingHTTPOptions returns nil when no HTTP options
+// are set so that bindings without options (or with
+// empty ones) are grouped together
+func getBindingHTTPOptions(
+	binding *proto.EnvServedPortBinding,
+) *proto.EnvServedPortBindingHTTPOptions {
+
+	if binding.HttpOptions == nil || goproto.Size(binding.HttpOptions) == 0 {
+		return nil
+	}
+
+	return binding.HttpOptions
+}
+
+// getBindingTarget returns the directory or the redirect
+// served by the passed binding (or nil for port bindings)
+func getBindingTarget(
+	binding *proto.EnvServedPortBinding,
+) (*proto.EnvServedPortBindingDirectory, *proto.EnvServedPortBindingRedirect) {
+
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case env.EnvServedPortBindingTypeDirectory:
+		if binding.Directory == nil {
+			return &proto.EnvServedPortBindingDirectory{}, nil
+		}
+
+		return binding.Directory, nil
+	case env.EnvServedPortBindingTypeRedirect:
+		if binding.Redirect == nil {
+			return nil, &proto.EnvServedPortBindingRedirect{}
+		}
+
+		return nil, binding.Redirect
+	}
+
+	return nil, nil
+}
+
+func areUpstreamsEqual(
+	upstreams []*proto.EnvServedPortBindingUpstream,
+	otherUpstreams []*proto.EnvServedPortBindingUpstream,
+) bool {
+
+	if len(upstreams) != len(otherUpstreams) {
+		return false
+	}
+
+	for upstreamIndex, upstream := range upstreams {
+		if !goproto.Equal(upstream, otherUpstreams[upstreamIndex]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/eleven-sh/agent/internal/env"
 	"github.com/eleven-sh/agent/proto"
 	"github.com/eleven-sh/eleven/entities"
 )
@@ -48,6 +49,34 @@ func TestCreateConfigFromServedPorts(t *testing.T) {
 			}`,
 		},
 
+		{
+			test: "with only TCP and UDP port bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"5432": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "15432",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+
+						{
+							Value:    "15433",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolUDP),
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{}
+					}
+				}
+			}`,
+		},
+
 		{
 			test: "with only domain bindings with redirect to HTTPS",
 			servedPorts: map[string]*proto.EnvServedPortBindings{
@@ -353,6 +382,450 @@ func TestCreateConfigFromServedPorts(t *testing.T) {
 				}
 			}`,
 		},
+
+		{
+			test: "with HTTP options",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:           "a.domain.com",
+							Type:            string(entities.EnvServedPortBindingTypeDomain),
+							RedirectToHttps: false,
+						},
+
+						{
+							Value:           "b.domain.com",
+							Type:            string(entities.EnvServedPortBindingTypeDomain),
+							RedirectToHttps: true,
+							HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+								ResponseHeaders: &proto.EnvServedPortBindingHTTPHeaders{
+									Add: map[string]string{
+										"X-Frame-Options": "DENY",
+									},
+									Remove: []string{"Server"},
+								},
+								Encodings: []string{"zstd", "gzip", "unknown"},
+								Hsts: &proto.EnvServedPortBindingHSTS{
+									IncludeSubdomains: true,
+								},
+							},
+						},
+
+						{
+							Value: "9000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+							HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+								RequestHeaders: &proto.EnvServedPortBindingHTTPHeaders{
+									Add: map[string]string{
+										"X-Sandbox": "true",
+									},
+								},
+								Cors: &proto.EnvServedPortBindingCORS{
+									AllowedOrigins:   []string{"https://app.domain.com"},
+									AllowCredentials: true,
+								},
+								Hsts: &proto.EnvServedPortBindingHSTS{},
+							},
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"https-domains":{
+								"listen":[
+									":443"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"a.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									},
+									{
+										"match":[
+											{
+												"host":[
+													"b.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"encode",
+												"encodings":{
+													"gzip":{},
+													"zstd":{}
+												},
+												"prefer":[
+													"zstd",
+													"gzip"
+												]
+											},
+											{
+												"handler":"headers",
+												"response":{
+													"add":{
+														"X-Frame-Options":[
+															"DENY"
+														]
+													},
+													"set":{
+														"Strict-Transport-Security":[
+															"max-age=31536000; includeSubDomains"
+														]
+													},
+													"delete":[
+														"Server"
+													],
+													"deferred":true
+												}
+											},
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								]
+							},
+							"http-domains":{
+								"listen":[
+									":80"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"a.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								]
+							},
+							"port-8080-2":{
+								"listen":[
+									":9000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"headers",
+												"request":{
+													"add":{
+														"X-Sandbox":[
+															"true"
+														]
+													}
+												}
+											},
+											{
+												"handler":"subroute",
+												"routes":[
+													{
+														"match":[
+															{
+																"header":{
+																	"Origin":[
+																		"https://app.domain.com"
+																	]
+																}
+															}
+														],
+														"handle":[
+															{
+																"handler":"headers",
+																"response":{
+																	"set":{
+																		"Access-Control-Allow-Credentials":[
+																			"true"
+																		],
+																		"Access-Control-Allow-Headers":[
+																			"{http.request.header.Access-Control-Request-Headers}"
+																		],
+																		"Access-Control-Allow-Methods":[
+																			"GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS"
+																		],
+																		"Access-Control-Allow-Origin":[
+																			"{http.request.header.Origin}"
+																		],
+																		"Vary":[
+																			"Origin"
+																		]
+																	},
+																	"deferred":true
+																}
+															}
+														]
+													},
+													{
+														"match":[
+															{
+																"method":[
+																	"OPTIONS"
+																],
+																"header":{
+																	"Access-Control-Request-Method":[
+																		"*"
+																	],
+																	"Origin":[
+																		"https://app.domain.com"
+																	]
+																}
+															}
+														],
+														"handle":[
+															{
+																"handler":"static_response",
+																"status_code":204
+															}
+														]
+													}
+												]
+											},
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with directory and redirect bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"static": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:           "docs.domain.com",
+							Type:            string(env.EnvServedPortBindingTypeDirectory),
+							RedirectToHttps: true,
+							Directory: &proto.EnvServedPortBindingDirectory{
+								Path:        "website/dist",
+								SpaFallback: true,
+							},
+						},
+
+						{
+							Value:           "blog.domain.com",
+							Type:            string(env.EnvServedPortBindingTypeDirectory),
+							RedirectToHttps: true,
+							Directory: &proto.EnvServedPortBindingDirectory{
+								Path: "/home/eleven/workspace/blog/public",
+							},
+						},
+
+						{
+							Value: "www.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeRedirect),
+							Redirect: &proto.EnvServedPortBindingRedirect{
+								Url:       "https://domain.com",
+								Permanent: true,
+							},
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"https-domains":{
+								"listen":[
+									":443"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"docs.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"subroute",
+												"routes":[
+													{
+														"match":[
+															{
+																"file":{
+																	"root":"/home/eleven/workspace/website/dist",
+																	"try_files":[
+																		"{http.request.uri.path}",
+																		"/index.html"
+																	]
+																}
+															}
+														],
+														"handle":[
+															{
+																"handler":"rewrite",
+																"uri":"{http.matchers.file.relative}"
+															}
+														]
+													}
+												]
+											},
+											{
+												"handler":"file_server",
+												"root":"/home/eleven/workspace/website/dist"
+											}
+										]
+									},
+									{
+										"match":[
+											{
+												"host":[
+													"blog.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"file_server",
+												"root":"/home/eleven/workspace/blog/public"
+											}
+										]
+									},
+									{
+										"match":[
+											{
+												"host":[
+													"www.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"static_response",
+												"status_code":301,
+												"headers":{
+													"Location":[
+														"https://domain.com"
+													]
+												}
+											}
+										]
+									}
+								]
+							},
+							"http-domains":{
+								"listen":[
+									":80"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"www.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"static_response",
+												"status_code":301,
+												"headers":{
+													"Location":[
+														"https://domain.com"
+													]
+												}
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with Unix socket bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"gunicorn": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:      "8000",
+							Type:       string(entities.EnvServedPortBindingTypePort),
+							UnixSocket: "api/gunicorn.sock",
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"port-gunicorn":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"error",
+												"error":"Unix socket unavailable",
+												"status_code":502
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
 	}
 
 	for _, tc := range testCases {
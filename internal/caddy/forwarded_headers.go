@@ -0,0 +1,47 @@
+package caddy
+
+const (
+	// Caddy already sets the "X-Forwarded-*" headers
+	// (ignoring the ones sent by clients).
+	// Ref: https://www.rfc-editor.org/rfc/rfc7239
+	forwardedHeaderValue = `for="{http.request.remote}";host="{http.request.host}";proto={http.request.scheme}`
+)
+
+// UpdateConfigToForwardClientAddr makes Caddy set the "Forwarded"
+// header on all the requests sent to upstreams so that
+// they could recover the address of the clients.
+func UpdateConfigToForwardClientAddr(config *Config) {
+	for serverKey, serverConfig := range config.Apps.HTTP.Servers {
+		serverConfig.Routes = addForwardedHeaderToRoutes(serverConfig.Routes)
+		config.Apps.HTTP.Servers[serverKey] = serverConfig
+	}
+}
+
+func addForwardedHeaderToRoutes(routes []ConfigHTTPServerRoute) []ConfigHTTPServerRoute {
+	for routeIndex, route := range routes {
+		handlers := []ConfigHTTPServerHandle{}
+
+		for _, handler := range route.Handle {
+			if handler.Handler == configServersSubrouteHandler {
+				handler.Routes = addForwardedHeaderToRoutes(handler.Routes)
+			}
+
+			if handler.Handler == configServersRPHandler {
+				handlers = append(handlers, ConfigHTTPServerHandle{
+					Handler: configServersHeadersHandler,
+					Request: &ConfigHTTPServerHeaderOps{
+						Set: map[string][]string{
+							"Forwarded": {forwardedHeaderValue},
+						},
+					},
+				})
+			}
+
+			handlers = append(handlers, handler)
+		}
+
+		routes[routeIndex].Handle = handlers
+	}
+
+	return routes
+}
@@ -0,0 +1,120 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestUpdateConfigToForwardClientAddr(t *testing.T) {
+	servedPorts := map[string]*proto.EnvServedPortBindings{
+		"8080": {
+			Bindings: []*proto.EnvServedPortBinding{
+				{
+					Value: "8000",
+					Type:  string(entities.EnvServedPortBindingTypePort),
+				},
+			},
+		},
+
+		"www": {
+			Bindings: []*proto.EnvServedPortBinding{
+				{
+					Value: "www.domain.com",
+					Type:  string(env.EnvServedPortBindingTypeRedirect),
+					Redirect: &proto.EnvServedPortBindingRedirect{
+						Url: "https://domain.com",
+					},
+					RedirectToHttps: true,
+				},
+			},
+		},
+	}
+
+	expectedConfig := `{
+		"apps":{
+			"http":{
+				"servers":{
+					"port-8080":{
+						"listen":[
+							":8000"
+						],
+						"routes":[
+							{
+								"handle":[
+									{
+										"handler":"headers",
+										"request":{
+											"set":{
+												"Forwarded":[
+													"for=\"{http.request.remote}\";host=\"{http.request.host}\";proto={http.request.scheme}"
+												]
+											}
+										}
+									},
+									{
+										"handler":"reverse_proxy",
+										"upstreams":[
+											{
+												"dial":"127.0.0.1:8080"
+											}
+										]
+									}
+								]
+							}
+						]
+					},
+					"https-domains":{
+						"listen":[
+							":443"
+						],
+						"routes":[
+							{
+								"match":[
+									{
+										"host":[
+											"www.domain.com"
+										]
+									}
+								],
+								"handle":[
+									{
+										"handler":"static_response",
+										"status_code":302,
+										"headers":{
+											"Location":[
+												"https://domain.com"
+											]
+										}
+									}
+								]
+							}
+						]
+					}
+				}
+			}
+		}
+	}`
+
+	caddyConfig := CreateConfigFromServedPorts(servedPorts)
+	UpdateConfigToForwardClientAddr(caddyConfig)
+
+	var expectedCaddyConfig *Config
+	err := json.Unmarshal([]byte(expectedConfig), &expectedCaddyConfig)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if !reflect.DeepEqual(caddyConfig, expectedCaddyConfig) {
+		t.Fatalf(
+			"expected config to equal '%+v', got '%+v'",
+			expectedCaddyConfig,
+			caddyConfig,
+		)
+	}
+}
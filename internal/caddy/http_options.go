@@ -0,0 +1,304 @@
+package caddy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	CORSDefaultAllowedMethods = "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS"
+	hstsDefaultMaxAgeSeconds  = 31536000 // One year
+)
+
+var supportedEncodings = map[string]bool{
+	"gzip": true,
+	"zstd": true,
+}
+
+func buildRouteHandlers(
+	port string,
+	bindings servedPortBindings,
+	isHTTPS bool,
+) []ConfigHTTPServerHandle {
+
+	httpOptions := bindings.httpOptions
+	handlers := []ConfigHTTPServerHandle{}
+
+	if httpOptions != nil {
+		if encodeHandler := buildEncodeHandler(httpOptions.Encodings); encodeHandler != nil {
+			handlers = append(handlers, *encodeHandler)
+		}
+
+		if headersHandler := buildHeadersHandler(httpOptions, isHTTPS); headersHandler != nil {
+			handlers = append(handlers, *headersHandler)
+		}
+
+		if httpOptions.Cors != nil {
+			handlers = append(handlers, buildCORSHandler(httpOptions.Cors))
+		}
+	}
+
+	if bindings.directory != nil {
+		return append(handlers, buildDirectoryHandlers(bindings.directory)...)
+	}
+
+	if bindings.redirect != nil {
+		return append(handlers, buildRedirectHandler(bindings.redirect))
+	}
+
+	if len(bindings.upstreams) > 0 {
+		return append(handlers, buildUpstreamsHandler(bindings))
+	}
+
+	upstreamDial := net.JoinHostPort("127.0.0.1", port)
+
+	if len(bindings.unixSocketPath) > 0 {
+		// Caddy dials the resolved path so that the socket
+		// could not be replaced by a symlink once checked.
+		// The config is re-applied when the resolved path
+		// changes (see "Proxy.Health").
+		socketPath, err := env.ResolveWorkspaceUnixSocket(bindings.unixSocketPath)
+
+		if err != nil {
+			return append(handlers, ConfigHTTPServerHandle{
+				Handler:    configServersErrorHandler,
+				Error:      "Unix socket unavailable",
+				StatusCode: 502,
+			})
+		}
+
+		upstreamDial = "unix/" + socketPath
+	}
+
+	return append(handlers, ConfigHTTPServerHandle{
+		Handler: configServersRPHandler,
+		Upstreams: []ConfigHTTPServerUpstreams{
+			{
+				Dial: upstreamDial,
+			},
+		},
+	})
+}
+
+func buildEncodeHandler(encodings []string) *ConfigHTTPServerHandle {
+	handlerEncodings := map[string]ConfigHTTPServerEncoding{}
+	preferredEncodings := []string{}
+
+	for _, encoding := range encodings {
+		if !supportedEncodings[encoding] {
+			continue
+		}
+
+		if _, alreadyAdded := handlerEncodings[encoding]; alreadyAdded {
+			continue
+		}
+
+		handlerEncodings[encoding] = ConfigHTTPServerEncoding{}
+		preferredEncodings = append(preferredEncodings, encoding)
+	}
+
+	if len(handlerEncodings) == 0 {
+		return nil
+	}
+
+	return &ConfigHTTPServerHandle{
+		Handler:   configServersEncodeHandler,
+		Encodings: handlerEncodings,
+		Prefer:    preferredEncodings,
+	}
+}
+
+func buildHeadersHandler(
+	httpOptions *proto.EnvServedPortBindingHTTPOptions,
+	isHTTPS bool,
+) *ConfigHTTPServerHandle {
+
+	requestHeaderOps := buildHeaderOps(httpOptions.RequestHeaders)
+	responseHeaderOps := buildHeaderOps(httpOptions.ResponseHeaders)
+
+	// HSTS headers are ignored by browsers
+	// when sent over plain HTTP
+	if isHTTPS && httpOptions.Hsts != nil {
+		if responseHeaderOps == nil {
+			responseHeaderOps = &ConfigHTTPServerHeaderOps{}
+		}
+
+		responseHeaderOps.Set = map[string][]string{
+			"Strict-Transport-Security": {BuildHSTSHeaderValue(httpOptions.Hsts)},
+		}
+	}
+
+	if requestHeaderOps == nil && responseHeaderOps == nil {
+		return nil
+	}
+
+	if responseHeaderOps != nil {
+		// Response headers need to be applied
+		// after the upstream has written its own
+		// otherwise they could be duplicated or not removed
+		responseHeaderOps.Deferred = true
+	}
+
+	return &ConfigHTTPServerHandle{
+		Handler:  configServersHeadersHandler,
+		Request:  requestHeaderOps,
+		Response: responseHeaderOps,
+	}
+}
+
+func buildHeaderOps(
+	headers *proto.EnvServedPortBindingHTTPHeaders,
+) *ConfigHTTPServerHeaderOps {
+
+	if headers == nil || (len(headers.Add) == 0 && len(headers.Remove) == 0) {
+		return nil
+	}
+
+	headerOps := &ConfigHTTPServerHeaderOps{}
+
+	if len(headers.Add) > 0 {
+		headerOps.Add = map[string][]string{}
+
+		for headerName, headerValue := range headers.Add {
+			headerOps.Add[headerName] = []string{headerValue}
+		}
+	}
+
+	if len(headers.Remove) > 0 {
+		headerOps.Delete = headers.Remove
+	}
+
+	return headerOps
+}
+
+// BuildHSTSHeaderValue returns the "Strict-Transport-Security"
+// header value for the passed HSTS options.
+func BuildHSTSHeaderValue(hsts *proto.EnvServedPortBindingHSTS) string {
+	maxAgeSeconds := hsts.MaxAgeSeconds
+
+	if maxAgeSeconds <= 0 {
+		maxAgeSeconds = hstsDefaultMaxAgeSeconds
+	}
+
+	headerValue := "max-age=" + strconv.FormatInt(maxAgeSeconds, 10)
+
+	if hsts.IncludeSubdomains {
+		headerValue += "; includeSubDomains"
+	}
+
+	if hsts.Preload {
+		headerValue += "; preload"
+	}
+
+	return headerValue
+}
+
+// HasExplicitCORSOrigins returns true if the passed CORS options
+// list the allowed origins without the "*" wildcard. Credentials
+// are only allowed for explicit origins given that the
+// request origin is echoed back.
+func HasExplicitCORSOrigins(cors *proto.EnvServedPortBindingCORS) bool {
+	if len(cors.AllowedOrigins) == 0 {
+		return false
+	}
+
+	for _, allowedOrigin := range cors.AllowedOrigins {
+		if allowedOrigin == "*" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// buildCORSHandler returns a subroute that adds the CORS
+// response headers to requests coming from allowed origins
+// and that responds to preflight requests directly,
+// without reaching the upstream.
+func buildCORSHandler(cors *proto.EnvServedPortBindingCORS) ConfigHTTPServerHandle {
+	allowedOrigins := cors.AllowedOrigins
+
+	if len(allowedOrigins) == 0 {
+		allowedOrigins = []string{"*"}
+	}
+
+	allowedMethods := CORSDefaultAllowedMethods
+
+	if len(cors.AllowedMethods) > 0 {
+		allowedMethods = strings.Join(cors.AllowedMethods, ", ")
+	}
+
+	// Allow all headers requested during preflight by default
+	allowedHeaders := "{http.request.header.Access-Control-Request-Headers}"
+
+	if len(cors.AllowedHeaders) > 0 {
+		allowedHeaders = strings.Join(cors.AllowedHeaders, ", ")
+	}
+
+	corsHeaders := map[string][]string{
+		// The origin is echoed instead of using "*"
+		// given that "*" is not allowed with credentials
+		"Access-Control-Allow-Origin":  {"{http.request.header.Origin}"},
+		"Access-Control-Allow-Methods": {allowedMethods},
+		"Access-Control-Allow-Headers": {allowedHeaders},
+		"Vary":                         {"Origin"},
+	}
+
+	// Rejected during validation. Also checked here
+	// for the bindings persisted before.
+	if cors.AllowCredentials && HasExplicitCORSOrigins(cors) {
+		corsHeaders["Access-Control-Allow-Credentials"] = []string{"true"}
+	}
+
+	if cors.MaxAgeSeconds > 0 {
+		corsHeaders["Access-Control-Max-Age"] = []string{
+			strconv.FormatInt(cors.MaxAgeSeconds, 10),
+		}
+	}
+
+	return ConfigHTTPServerHandle{
+		Handler: configServersSubrouteHandler,
+		Routes: []ConfigHTTPServerRoute{
+			{
+				Match: []ConfigHTTPServerMatch{
+					{
+						Header: map[string][]string{
+							"Origin": allowedOrigins,
+						},
+					},
+				},
+				Handle: []ConfigHTTPServerHandle{
+					{
+						Handler: configServersHeadersHandler,
+						Response: &ConfigHTTPServerHeaderOps{
+							Set:      corsHeaders,
+							Deferred: true,
+						},
+					},
+				},
+			},
+
+			{
+				Match: []ConfigHTTPServerMatch{
+					{
+						Method: []string{"OPTIONS"},
+						Header: map[string][]string{
+							"Origin":                        allowedOrigins,
+							"Access-Control-Request-Method": {"*"},
+						},
+					},
+				},
+				Handle: []ConfigHTTPServerHandle{
+					{
+						Handler:    configServersStaticHandler,
+						StatusCode: 204,
+					},
+				},
+			},
+		},
+	}
+}
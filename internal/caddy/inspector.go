@@ -0,0 +1,87 @@
+package caddy
+
+import (
+	"net"
+
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/internal/netsim"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// UpdateConfigToInspectPorts makes Caddy send the requests
+// of the ports that have their inspector enabled to the agent
+// inspector server listening at the passed address.
+// Only bindings proxied to their served port are inspected
+// (not the ones with multiple upstreams or the preview domain).
+func UpdateConfigToInspectPorts(
+	config *Config,
+	ports map[string]*proto.EnvServedPortBindings,
+	inspectorServerAddr string,
+) {
+
+	// Upstreams mapped to their port
+	inspectedUpstreams := map[string]string{}
+
+	for port, portBindings := range ports {
+		if !inspector.IsEnabled(portBindings) {
+			continue
+		}
+
+		inspectedUpstreams[net.JoinHostPort("127.0.0.1", port)] = port
+	}
+
+	if len(inspectedUpstreams) == 0 {
+		return
+	}
+
+	for serverKey, serverConfig := range config.Apps.HTTP.Servers {
+		for routeIndex, route := range serverConfig.Routes {
+			handlers := []ConfigHTTPServerHandle{}
+
+			for _, handler := range route.Handle {
+				if handler.Handler != configServersRPHandler ||
+					len(handler.Upstreams) != 1 {
+
+					handlers = append(handlers, handler)
+					continue
+				}
+
+				port, isInspected := inspectedUpstreams[handler.Upstreams[0].Dial]
+
+				if !isInspected {
+					handlers = append(handlers, handler)
+					continue
+				}
+
+				handlers = append(
+					handlers,
+					ConfigHTTPServerHandle{
+						Handler: configServersHeadersHandler,
+						Request: &ConfigHTTPServerHeaderOps{
+							Set: map[string][]string{
+								inspector.InspectedPortHeader: {port},
+							},
+							// Only set by the agent (see "network_conditions.go")
+							Delete: []string{
+								netsim.ConditionsHeader,
+							},
+						},
+					},
+
+					ConfigHTTPServerHandle{
+						Handler: configServersRPHandler,
+						Upstreams: []ConfigHTTPServerUpstreams{
+							{
+								Dial: inspectorServerAddr,
+							},
+						},
+					},
+				)
+			}
+
+			serverConfig.Routes[routeIndex].Handle = handlers
+		}
+
+		config.Apps.HTTP.Servers[serverKey] = serverConfig
+	}
+}
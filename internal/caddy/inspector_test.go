@@ -0,0 +1,143 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestUpdateConfigToInspectPorts(t *testing.T) {
+	testCases := []struct {
+		test           string
+		servedPorts    map[string]*proto.EnvServedPortBindings
+		expectedConfig string
+	}{
+		{
+			test: "with inspector disabled",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"port-8080":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with inspector enabled",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+					Inspector: &proto.EnvServedPortInspector{
+						Enabled: true,
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"port-8080":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"headers",
+												"request":{
+													"set":{
+														"X-Eleven-Inspected-Port":[
+															"8080"
+														]
+													},
+													"delete":[
+														"X-Eleven-Network-Conditions"
+													]
+												}
+											},
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:2021"
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			caddyConfig := CreateConfigFromServedPorts(tc.servedPorts)
+
+			UpdateConfigToInspectPorts(
+				caddyConfig,
+				tc.servedPorts,
+				"127.0.0.1:2021",
+			)
+
+			var expectedConfig *Config
+			err := json.Unmarshal([]byte(tc.expectedConfig), &expectedConfig)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(caddyConfig, expectedConfig) {
+				t.Fatalf(
+					"expected config to equal '%+v', got '%+v'",
+					expectedConfig,
+					caddyConfig,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package caddy
+
+import (
+	"net"
+	"reflect"
+
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/internal/netsim"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// UpdateConfigToSimulateNetworkConditions makes Caddy send the
+// requests of the bindings that have network conditions to the agent
+// inspector server listening at the passed address that simulates them.
+// Connections are closed without response when the agent asks for it.
+func UpdateConfigToSimulateNetworkConditions(
+	config *Config,
+	ports map[string]*proto.EnvServedPortBindings,
+	inspectorServerAddr string,
+) {
+
+	servedPorts := buildServedPorts(ports)
+
+	for _, servedPort := range servedPorts {
+		port := servedPort.port
+
+		for bindingsIndex, bindings := range servedPort.bindings {
+			if bindings.networkConditions == nil {
+				continue
+			}
+
+			handlers := buildNetworkConditionsHandlers(
+				port,
+				bindings.networkConditions,
+				inspectorServerAddr,
+			)
+
+			replaceServerRoutesUpstream(
+				config,
+				configServersHTTPSDomainsKey,
+				bindings.httpsDomains,
+				port,
+				handlers,
+			)
+
+			replaceServerRoutesUpstream(
+				config,
+				configServersHTTPDomainsKey,
+				bindings.httpDomains,
+				port,
+				handlers,
+			)
+
+			if len(bindings.ports) == 0 {
+				continue
+			}
+
+			replaceServerRoutesUpstream(
+				config,
+				buildPortsServerKey(port, bindingsIndex),
+				nil,
+				port,
+				handlers,
+			)
+		}
+	}
+}
+
+func buildNetworkConditionsHandlers(
+	port string,
+	conditions *proto.EnvServedPortBindingNetworkConditions,
+	inspectorServerAddr string,
+) []ConfigHTTPServerHandle {
+
+	return []ConfigHTTPServerHandle{
+		{
+			Handler: configServersHeadersHandler,
+			Request: &ConfigHTTPServerHeaderOps{
+				Set: map[string][]string{
+					inspector.InspectedPortHeader: {port},
+					netsim.ConditionsHeader:       {netsim.BuildHeaderValue(conditions)},
+				},
+			},
+		},
+
+		{
+			Handler: configServersRPHandler,
+			Upstreams: []ConfigHTTPServerUpstreams{
+				{
+					Dial: inspectorServerAddr,
+				},
+			},
+			// Simulates dropped connections
+			HandleResponse: []ConfigHTTPServerResponseHandler{
+				{
+					Match: &ConfigHTTPServerResponseMatch{
+						Headers: map[string][]string{
+							netsim.DroppedConnectionHeader: {"true"},
+						},
+					},
+					Routes: []ConfigHTTPServerRoute{
+						{
+							Handle: []ConfigHTTPServerHandle{
+								{
+									Handler: configServersStaticHandler,
+									Abort:   true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// replaceServerRoutesUpstream replaces the handler that proxies
+// to the passed port with the passed handlers in the routes
+// of the passed hosts (or of all routes if hosts are nil).
+func replaceServerRoutesUpstream(
+	config *Config,
+	serverKey string,
+	hosts []string,
+	port string,
+	upstreamHandlers []ConfigHTTPServerHandle,
+) {
+
+	if hosts != nil && len(hosts) == 0 {
+		return
+	}
+
+	serverConfig, hasServerConfig := config.Apps.HTTP.Servers[serverKey]
+
+	if !hasServerConfig {
+		return
+	}
+
+	upstreamDial := net.JoinHostPort("127.0.0.1", port)
+
+	for routeIndex, route := range serverConfig.Routes {
+		if hosts != nil &&
+			(len(route.Match) == 0 || !reflect.DeepEqual(route.Match[0].Host, hosts)) {
+
+			continue
+		}
+
+		handlers := []ConfigHTTPServerHandle{}
+
+		for _, handler := range route.Handle {
+			if handler.Handler != configServersRPHandler ||
+				len(handler.Upstreams) != 1 ||
+				handler.Upstreams[0].Dial != upstreamDial {
+
+				handlers = append(handlers, handler)
+				continue
+			}
+
+			handlers = append(handlers, upstreamHandlers...)
+		}
+
+		serverConfig.Routes[routeIndex].Handle = handlers
+	}
+
+	config.Apps.HTTP.Servers[serverKey] = serverConfig
+}
@@ -0,0 +1,190 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestUpdateConfigToSimulateNetworkConditions(t *testing.T) {
+	testCases := []struct {
+		test           string
+		servedPorts    map[string]*proto.EnvServedPortBindings
+		expectedConfig string
+	}{
+		{
+			test: "with empty network conditions",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:             "8000",
+							Type:              string(entities.EnvServedPortBindingTypePort),
+							NetworkConditions: &proto.EnvServedPortBindingNetworkConditions{},
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"port-8080":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with network conditions",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+
+						{
+							Value: "8001",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+							NetworkConditions: &proto.EnvServedPortBindingNetworkConditions{
+								LatencyMs: 200,
+								DropRate:  0.1,
+							},
+						},
+					},
+				},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"port-8080":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								]
+							},
+							"port-8080-1":{
+								"listen":[
+									":8001"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"headers",
+												"request":{
+													"set":{
+														"X-Eleven-Inspected-Port":[
+															"8080"
+														],
+														"X-Eleven-Network-Conditions":[
+															"drop_rate=0.1&latency_ms=200"
+														]
+													}
+												}
+											},
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:2021"
+													}
+												],
+												"handle_response":[
+													{
+														"match":{
+															"headers":{
+																"X-Eleven-Dropped-Connection":[
+																	"true"
+																]
+															}
+														},
+														"routes":[
+															{
+																"handle":[
+																	{
+																		"handler":"static_response",
+																		"abort":true
+																	}
+																]
+															}
+														]
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					}
+				}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			caddyConfig := CreateConfigFromServedPorts(tc.servedPorts)
+
+			UpdateConfigToSimulateNetworkConditions(
+				caddyConfig,
+				tc.servedPorts,
+				"127.0.0.1:2021",
+			)
+
+			var expectedConfig *Config
+			err := json.Unmarshal([]byte(tc.expectedConfig), &expectedConfig)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(caddyConfig, expectedConfig) {
+				t.Fatalf(
+					"expected config to equal '%+v', got '%+v'",
+					expectedConfig,
+					caddyConfig,
+				)
+			}
+		})
+	}
+}
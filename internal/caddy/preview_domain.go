@@ -0,0 +1,132 @@
+package caddy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/errorpage"
+	"github.com/eleven-sh/agent/internal/proxy"
+)
+
+type ConfigTLSApp struct {
+	Automation ConfigTLSAutomation `json:"automation"`
+}
+
+type ConfigTLSAutomation struct {
+	Policies []ConfigTLSAutomationPolicy `json:"policies"`
+	OnDemand *ConfigTLSOnDemand          `json:"on_demand,omitempty"`
+}
+
+type ConfigTLSAutomationPolicy struct {
+	Subjects []string `json:"subjects"`
+	OnDemand bool     `json:"on_demand"`
+}
+
+type ConfigTLSOnDemand struct {
+	Ask string `json:"ask"`
+}
+
+// UpdateConfigToServePreviewDomain routes "<port>.<domain>" to the
+// local port "<port>" for each port allowed by the preview domain config.
+// The route doesn't depend on the listening ports so that the config
+// is not reloaded each time a port is listened on. Certificates are
+// obtained on first request, once the error page server has checked
+// that the port is listened on (see "errorpage.OnDemandTLSAskPath").
+func UpdateConfigToServePreviewDomain(
+	config *Config,
+	previewDomain *env.ConfigPreviewDomain,
+	errorPageServerAddr string,
+) {
+
+	if previewDomain == nil {
+		return
+	}
+
+	httpsConfig, hasHTTPSConfig := config.Apps.HTTP.Servers[configServersHTTPSDomainsKey]
+
+	if !hasHTTPSConfig {
+		httpsConfig = ConfigHTTPServer{
+			Listen: []string{":443"},
+			Routes: []ConfigHTTPServerRoute{},
+		}
+	}
+
+	// Host labels are in reverse order (eg: "labels.0" is the TLD)
+	// so the port label index is the number of labels of the domain
+	portLabel := "{http.request.host.labels." +
+		strconv.Itoa(strings.Count(previewDomain.Domain, ".")+1) + "}"
+
+	wildcardHost := "*." + previewDomain.Domain
+
+	httpsConfig.Routes = append(httpsConfig.Routes, ConfigHTTPServerRoute{
+		Match: []ConfigHTTPServerMatch{
+			{
+				Host:       []string{wildcardHost},
+				Expression: buildPreviewPortMatchExpression(portLabel, previewDomain),
+			},
+		},
+		Handle: []ConfigHTTPServerHandle{
+			{
+				Handler: configServersRPHandler,
+				Upstreams: []ConfigHTTPServerUpstreams{
+					{
+						Dial: "127.0.0.1:" + portLabel,
+					},
+				},
+			},
+		},
+	})
+
+	config.Apps.HTTP.Servers[configServersHTTPSDomainsKey] = httpsConfig
+
+	config.Apps.TLS = &ConfigTLSApp{
+		Automation: ConfigTLSAutomation{
+			Policies: []ConfigTLSAutomationPolicy{
+				{
+					Subjects: []string{wildcardHost},
+					OnDemand: true,
+				},
+			},
+			OnDemand: &ConfigTLSOnDemand{
+				Ask: "http://" + errorPageServerAddr + errorpage.OnDemandTLSAskPath,
+			},
+		},
+	}
+}
+
+// buildPreviewPortMatchExpression returns the CEL expression
+// matching the port label of the allowed ports. Ports are
+// matched in canonical form given that "02019" is dialed as "2019".
+func buildPreviewPortMatchExpression(
+	portLabel string,
+	previewDomain *env.ConfigPreviewDomain,
+) string {
+
+	expression := portLabel + `.matches("^[1-9][0-9]*$")`
+
+	if len(previewDomain.AllowedPorts) > 0 {
+		expression += fmt.Sprintf(
+			" && %s in %s",
+			portLabel,
+			buildCELStringList(previewDomain.AllowedPorts),
+		)
+	}
+
+	return expression + fmt.Sprintf(
+		" && !(%s in %s)",
+		portLabel,
+		buildCELStringList(proxy.GetPreviewDeniedPorts(previewDomain)),
+	)
+}
+
+func buildCELStringList(values []string) string {
+	quotedValues := []string{}
+
+	for _, value := range values {
+		quotedValues = append(quotedValues, strconv.Quote(value))
+	}
+
+	return "[" + strings.Join(quotedValues, ", ") + "]"
+}
@@ -0,0 +1,175 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+)
+
+func TestUpdateConfigToServePreviewDomain(t *testing.T) {
+	testCases := []struct {
+		test           string
+		previewDomain  *env.ConfigPreviewDomain
+		expectedConfig string
+	}{
+		{
+			test:          "with no preview domain",
+			previewDomain: nil,
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with preview domain",
+			previewDomain: &env.ConfigPreviewDomain{
+				Domain: "myenv.dev.domain.com",
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"https-domains":{
+								"listen":[
+									":443"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"*.myenv.dev.domain.com"
+												],
+												"expression":"{http.request.host.labels.4}.matches(\"^[1-9][0-9]*$\") && !({http.request.host.labels.4} in [\"2019\", \"2020\", \"2021\", \"22\", \"2200\", \"443\", \"80\"])"
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:{http.request.host.labels.4}"
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					},
+					"tls":{
+						"automation":{
+							"policies":[
+								{
+									"subjects":[
+										"*.myenv.dev.domain.com"
+									],
+									"on_demand":true
+								}
+							],
+							"on_demand":{
+								"ask":"http://127.0.0.1:2020/on-demand-tls-ask"
+							}
+						}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with allowed and denied ports",
+			previewDomain: &env.ConfigPreviewDomain{
+				Domain:       "myenv.domain.com",
+				AllowedPorts: []string{"3000", "8080"},
+				DeniedPorts:  []string{"8080"},
+			},
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"https-domains":{
+								"listen":[
+									":443"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"*.myenv.domain.com"
+												],
+												"expression":"{http.request.host.labels.3}.matches(\"^[1-9][0-9]*$\") && {http.request.host.labels.3} in [\"3000\", \"8080\"] && !({http.request.host.labels.3} in [\"2019\", \"2020\", \"2021\", \"22\", \"2200\", \"443\", \"80\", \"8080\"])"
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:{http.request.host.labels.3}"
+													}
+												]
+											}
+										]
+									}
+								]
+							}
+						}
+					},
+					"tls":{
+						"automation":{
+							"policies":[
+								{
+									"subjects":[
+										"*.myenv.domain.com"
+									],
+									"on_demand":true
+								}
+							],
+							"on_demand":{
+								"ask":"http://127.0.0.1:2020/on-demand-tls-ask"
+							}
+						}
+					}
+				}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			caddyConfig := CreateConfigFromServedPorts(
+				map[string]*proto.EnvServedPortBindings{},
+			)
+
+			UpdateConfigToServePreviewDomain(
+				caddyConfig,
+				tc.previewDomain,
+				"127.0.0.1:2020",
+			)
+
+			var expectedConfig *Config
+			err := json.Unmarshal([]byte(tc.expectedConfig), &expectedConfig)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(caddyConfig, expectedConfig) {
+				t.Fatalf(
+					"expected config to equal '%+v', got '%+v'",
+					expectedConfig,
+					caddyConfig,
+				)
+			}
+		})
+	}
+}
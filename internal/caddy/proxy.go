@@ -0,0 +1,225 @@
+package caddy
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/proxy"
+)
+
+// Proxy is the Caddy implementation of "proxy.Proxy".
+// Caddy is configured through its API.
+type Proxy struct {
+	api          *API
+	appliedState *proxy.State
+	// Used to detect config changes
+	// made outside of the agent
+	appliedRawConfig interface{}
+	lock             sync.Mutex
+}
+
+func NewProxy() *Proxy {
+	return &Proxy{
+		api: NewAPI(),
+	}
+}
+
+func (p *Proxy) Apply(state *proxy.State) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := ensureAccessLogsDirExists(config.CaddyAccessLogsDirPath)
+
+	if err != nil {
+		return err
+	}
+
+	caddyConfig := BuildConfig(state)
+	rawConfig, err := toRawConfig(caddyConfig)
+
+	if err != nil {
+		return err
+	}
+
+	// Not reloaded if the state changes don't
+	// change the config (eg: preview ports)
+	if p.isRunningConfig(rawConfig) {
+		p.appliedState = state.Clone()
+		return nil
+	}
+
+	err = p.api.Load(caddyConfig)
+
+	if err != nil {
+		return err
+	}
+
+	p.appliedState = state.Clone()
+	p.appliedRawConfig = rawConfig
+
+	return nil
+}
+
+// isRunningConfig returns true if the passed config
+// is the applied one and is still run by Caddy
+func (p *Proxy) isRunningConfig(rawConfig interface{}) bool {
+	if p.appliedRawConfig == nil ||
+		!reflect.DeepEqual(rawConfig, p.appliedRawConfig) {
+
+		return false
+	}
+
+	runningRawConfig, err := p.api.GetRawConfig()
+
+	return err == nil && reflect.DeepEqual(runningRawConfig, rawConfig)
+}
+
+func (p *Proxy) Current() (*proxy.State, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.appliedState == nil {
+		return nil, nil
+	}
+
+	return p.appliedState.Clone(), nil
+}
+
+// Health returns "proxy.ErrStateDrifted" if the running config
+// doesn't match the applied one (eg: Caddy was restarted) or if
+// the applied one is outdated (eg: Unix socket created).
+func (p *Proxy) Health() error {
+	runningRawConfig, err := p.api.GetRawConfig()
+
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.appliedState == nil {
+		return nil
+	}
+
+	if !reflect.DeepEqual(runningRawConfig, p.appliedRawConfig) {
+		return proxy.ErrStateDrifted
+	}
+
+	// Unix sockets are resolved when the config
+	// is built (eg: socket created once bound)
+	currentRawConfig, err := toRawConfig(BuildConfig(p.appliedState))
+
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(currentRawConfig, p.appliedRawConfig) {
+		return proxy.ErrStateDrifted
+	}
+
+	return nil
+}
+
+// BuildConfig returns the Caddy config used to serve the passed state.
+func BuildConfig(state *proxy.State) *Config {
+	caddyConfig := CreateConfigFromServedPorts(state.ServedPorts)
+
+	// Before the inspector given that the
+	// conditioned requests are inspected too
+	UpdateConfigToSimulateNetworkConditions(
+		caddyConfig,
+		state.ServedPorts,
+		config.InspectorServerListenAddr,
+	)
+
+	UpdateConfigToInspectPorts(
+		caddyConfig,
+		state.ServedPorts,
+		config.InspectorServerListenAddr,
+	)
+
+	UpdateConfigToEnableAccessLogs(
+		caddyConfig,
+		state.ServedPorts,
+		config.CaddyAccessLogsDirPath,
+	)
+
+	UpdateConfigToHandleUpstreamErrors(
+		caddyConfig,
+		state.ServedPorts,
+		config.ErrorPageServerListenAddr,
+	)
+
+	UpdateConfigToServePreviewDomain(
+		caddyConfig,
+		state.PreviewDomain,
+		config.ErrorPageServerListenAddr,
+	)
+
+	UpdateConfigToForwardClientAddr(caddyConfig)
+
+	if state.DomainReachabilityCheck != nil {
+		UpdateConfigToCheckDomainReachability(
+			caddyConfig,
+			state.DomainReachabilityCheck.Domain,
+			state.DomainReachabilityCheck.UniqueID,
+		)
+	}
+
+	return caddyConfig
+}
+
+// toRawConfig converts the passed config to a generic
+// JSON value comparable with the one returned by the API.
+func toRawConfig(caddyConfig *Config) (interface{}, error) {
+	configAsJSON, err := json.Marshal(caddyConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rawConfig interface{}
+	err = json.Unmarshal(configAsJSON, &rawConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rawConfig, nil
+}
+
+func ensureAccessLogsDirExists(accessLogsDirPath string) error {
+	err := os.MkdirAll(accessLogsDirPath, os.FileMode(0750))
+
+	if err != nil {
+		return err
+	}
+
+	// Logs files are written by Caddy
+	// that doesn't run as root
+	caddyUser, err := user.Lookup(config.CaddyUserName)
+
+	if err != nil {
+		return err
+	}
+
+	caddyUserID, err := strconv.Atoi(caddyUser.Uid)
+
+	if err != nil {
+		return err
+	}
+
+	caddyGroupID, err := strconv.Atoi(caddyUser.Gid)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Chown(accessLogsDirPath, caddyUserID, caddyGroupID)
+}
@@ -0,0 +1,74 @@
+package caddy
+
+import (
+	"net/http"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	spaFallbackFilePath = "/index.html"
+)
+
+func buildDirectoryHandlers(
+	directory *proto.EnvServedPortBindingDirectory,
+) []ConfigHTTPServerHandle {
+
+	// Paths are validated before the config is built
+	rootPath, _ := env.ResolveWorkspacePath(directory.Path)
+	handlers := []ConfigHTTPServerHandle{}
+
+	if directory.SpaFallback {
+		// Equivalent of the Caddyfile
+		// "try_files {path} /index.html" directive
+		handlers = append(handlers, ConfigHTTPServerHandle{
+			Handler: configServersSubrouteHandler,
+			Routes: []ConfigHTTPServerRoute{
+				{
+					Match: []ConfigHTTPServerMatch{
+						{
+							File: &ConfigHTTPServerFile{
+								Root: rootPath,
+								TryFiles: []string{
+									"{http.request.uri.path}",
+									spaFallbackFilePath,
+								},
+							},
+						},
+					},
+					Handle: []ConfigHTTPServerHandle{
+						{
+							Handler: configServersRewriteHandler,
+							URI:     "{http.matchers.file.relative}",
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return append(handlers, ConfigHTTPServerHandle{
+		Handler: configServersFileHandler,
+		Root:    rootPath,
+	})
+}
+
+func buildRedirectHandler(
+	redirect *proto.EnvServedPortBindingRedirect,
+) ConfigHTTPServerHandle {
+
+	statusCode := http.StatusFound
+
+	if redirect.Permanent {
+		statusCode = http.StatusMovedPermanently
+	}
+
+	return ConfigHTTPServerHandle{
+		Handler:    configServersStaticHandler,
+		StatusCode: statusCode,
+		Headers: map[string][]string{
+			"Location": {redirect.Url},
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package caddy
+
+import (
+	"github.com/eleven-sh/agent/internal/errorpage"
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	// "reverse_proxy" returns a "502 Bad Gateway"
+	// error when the upstream could not be reached
+	upstreamErrorMatchExpression = "{http.error.status_code} == 502"
+)
+
+// UpdateConfigToHandleUpstreamErrors makes Caddy
+// proxy requests that failed to reach their upstream
+// to the agent error page server listening at the passed address.
+func UpdateConfigToHandleUpstreamErrors(
+	config *Config,
+	ports map[string]*proto.EnvServedPortBindings,
+	errorPageServerAddr string,
+) {
+
+	servedPorts := buildServedPorts(ports)
+
+	for _, servedPort := range servedPorts {
+		port := servedPort.port
+
+		for bindingsIndex, bindings := range servedPort.bindings {
+			// Directory and redirect bindings have no upstream
+			if bindings.directory != nil || bindings.redirect != nil {
+				continue
+			}
+
+			errorRouteHandlers := buildUpstreamErrorRouteHandlers(
+				port,
+				bindings.httpOptions,
+				errorPageServerAddr,
+			)
+
+			addServerErrorRoute(
+				config,
+				configServersHTTPSDomainsKey,
+				bindings.httpsDomains,
+				errorRouteHandlers,
+			)
+
+			addServerErrorRoute(
+				config,
+				configServersHTTPDomainsKey,
+				bindings.httpDomains,
+				errorRouteHandlers,
+			)
+
+			if len(bindings.ports) == 0 {
+				continue
+			}
+
+			addServerErrorRoute(
+				config,
+				buildPortsServerKey(port, bindingsIndex),
+				nil,
+				errorRouteHandlers,
+			)
+		}
+	}
+}
+
+func buildUpstreamErrorRouteHandlers(
+	port string,
+	httpOptions *proto.EnvServedPortBindingHTTPOptions,
+	errorPageServerAddr string,
+) []ConfigHTTPServerHandle {
+
+	refreshSeconds := int32(0)
+
+	if httpOptions != nil {
+		refreshSeconds = httpOptions.ErrorPageRefreshSeconds
+	}
+
+	return []ConfigHTTPServerHandle{
+		{
+			Handler: configServersRewriteHandler,
+			URI:     errorpage.BuildUpstreamErrorPageURI(port, refreshSeconds),
+		},
+
+		{
+			Handler: configServersRPHandler,
+			Upstreams: []ConfigHTTPServerUpstreams{
+				{
+					Dial: errorPageServerAddr,
+				},
+			},
+		},
+	}
+}
+
+// addServerErrorRoute adds an error route for the
+// passed hosts (or for all hosts if hosts are nil)
+// if the server exists.
+func addServerErrorRoute(
+	config *Config,
+	serverKey string,
+	hosts []string,
+	handlers []ConfigHTTPServerHandle,
+) {
+
+	if hosts != nil && len(hosts) == 0 {
+		return
+	}
+
+	serverConfig, hasServerConfig := config.Apps.HTTP.Servers[serverKey]
+
+	if !hasServerConfig {
+		return
+	}
+
+	if serverConfig.Errors == nil {
+		serverConfig.Errors = &ConfigHTTPServerErrors{
+			Routes: []ConfigHTTPServerRoute{},
+		}
+	}
+
+	serverConfig.Errors.Routes = append(
+		serverConfig.Errors.Routes,
+		ConfigHTTPServerRoute{
+			Match: []ConfigHTTPServerMatch{
+				{
+					Host:       hosts,
+					Expression: upstreamErrorMatchExpression,
+				},
+			},
+			Handle: handlers,
+		},
+	)
+
+	config.Apps.HTTP.Servers[serverKey] = serverConfig
+}
@@ -0,0 +1,189 @@
+package caddy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestUpdateConfigToHandleUpstreamErrors(t *testing.T) {
+	testCases := []struct {
+		test                string
+		servedPorts         map[string]*proto.EnvServedPortBindings
+		errorPageServerAddr string
+		expectedConfig      string
+	}{
+		{
+			test:                "with no served ports",
+			servedPorts:         map[string]*proto.EnvServedPortBindings{},
+			errorPageServerAddr: "127.0.0.1:2020",
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{}
+					}
+				}
+			}`,
+		},
+
+		{
+			test: "with domain and port bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:           "a.domain.com",
+							Type:            string(entities.EnvServedPortBindingTypeDomain),
+							RedirectToHttps: true,
+							HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+								ErrorPageRefreshSeconds: 5,
+							},
+						},
+
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			errorPageServerAddr: "127.0.0.1:2020",
+			expectedConfig: `{
+				"apps":{
+					"http":{
+						"servers":{
+							"https-domains":{
+								"listen":[
+									":443"
+								],
+								"routes":[
+									{
+										"match":[
+											{
+												"host":[
+													"a.domain.com"
+												]
+											}
+										],
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								],
+								"errors":{
+									"routes":[
+										{
+											"match":[
+												{
+													"host":[
+														"a.domain.com"
+													],
+													"expression":"{http.error.status_code} == 502"
+												}
+											],
+											"handle":[
+												{
+													"handler":"rewrite",
+													"uri":"/upstream-error?port=8080&refresh=5"
+												},
+												{
+													"handler":"reverse_proxy",
+													"upstreams":[
+														{
+															"dial":"127.0.0.1:2020"
+														}
+													]
+												}
+											]
+										}
+									]
+								}
+							},
+							"port-8080-1":{
+								"listen":[
+									":8000"
+								],
+								"routes":[
+									{
+										"handle":[
+											{
+												"handler":"reverse_proxy",
+												"upstreams":[
+													{
+														"dial":"127.0.0.1:8080"
+													}
+												]
+											}
+										]
+									}
+								],
+								"errors":{
+									"routes":[
+										{
+											"match":[
+												{
+													"expression":"{http.error.status_code} == 502"
+												}
+											],
+											"handle":[
+												{
+													"handler":"rewrite",
+													"uri":"/upstream-error?port=8080"
+												},
+												{
+													"handler":"reverse_proxy",
+													"upstreams":[
+														{
+															"dial":"127.0.0.1:2020"
+														}
+													]
+												}
+											]
+										}
+									]
+								}
+							}
+						}
+					}
+				}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			caddyConfig := CreateConfigFromServedPorts(tc.servedPorts)
+
+			UpdateConfigToHandleUpstreamErrors(
+				caddyConfig,
+				tc.servedPorts,
+				tc.errorPageServerAddr,
+			)
+
+			var expectedConfig *Config
+			err := json.Unmarshal([]byte(tc.expectedConfig), &expectedConfig)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(caddyConfig, expectedConfig) {
+				t.Fatalf(
+					"expected config to equal '%+v', got '%+v'",
+					expectedConfig,
+					caddyConfig,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package caddy
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	upstreamsFirstPolicy              = "first"
+	upstreamsRoundRobinPolicy         = "round_robin"
+	upstreamsWeightedRoundRobinPolicy = "weighted_round_robin"
+
+	// Duration during which a failed request
+	// is retried on the next available upstream
+	upstreamsTryDuration = "2s"
+	// Duration during which a failed upstream
+	// is considered down when there is no active health check
+	upstreamsPassiveFailDuration = "10s"
+
+	healthCheckDefaultIntervalSeconds = 5
+	healthCheckDefaultTimeoutSeconds  = 2
+)
+
+type ConfigHTTPServerLoadBalancing struct {
+	SelectionPolicy *ConfigHTTPServerSelectionPolicy `json:"selection_policy,omitempty"`
+	TryDuration     string                           `json:"try_duration,omitempty"`
+}
+
+type ConfigHTTPServerSelectionPolicy struct {
+	Policy  string `json:"policy"`
+	Weights []int  `json:"weights,omitempty"`
+}
+
+type ConfigHTTPServerHealthChecks struct {
+	Active  *ConfigHTTPServerActiveHealthChecks  `json:"active,omitempty"`
+	Passive *ConfigHTTPServerPassiveHealthChecks `json:"passive,omitempty"`
+}
+
+type ConfigHTTPServerActiveHealthChecks struct {
+	URI          string `json:"uri,omitempty"`
+	Interval     string `json:"interval,omitempty"`
+	Timeout      string `json:"timeout,omitempty"`
+	ExpectStatus int    `json:"expect_status,omitempty"`
+}
+
+type ConfigHTTPServerPassiveHealthChecks struct {
+	FailDuration string `json:"fail_duration,omitempty"`
+}
+
+func buildUpstreamsHandler(bindings servedPortBindings) ConfigHTTPServerHandle {
+	activeUpstreams := []*proto.EnvServedPortBindingUpstream{}
+	standbyUpstreams := []*proto.EnvServedPortBindingUpstream{}
+	hasWeights := false
+
+	for _, upstream := range bindings.upstreams {
+		if upstream.Standby {
+			standbyUpstreams = append(standbyUpstreams, upstream)
+			continue
+		}
+
+		activeUpstreams = append(activeUpstreams, upstream)
+		hasWeights = hasWeights || upstream.Weight > 0
+	}
+
+	handler := ConfigHTTPServerHandle{
+		Handler:   configServersRPHandler,
+		Upstreams: []ConfigHTTPServerUpstreams{},
+	}
+
+	// Standby upstreams are only used
+	// when active ones are unavailable
+	for _, upstream := range append(activeUpstreams, standbyUpstreams...) {
+		handler.Upstreams = append(handler.Upstreams, ConfigHTTPServerUpstreams{
+			Dial: buildUpstreamDial(upstream.Port),
+		})
+	}
+
+	if len(handler.Upstreams) == 1 && bindings.healthCheck == nil {
+		return handler
+	}
+
+	switch {
+	case len(standbyUpstreams) > 0:
+		handler.LoadBalancing = buildUpstreamsLoadBalancing(
+			upstreamsFirstPolicy,
+			nil,
+		)
+	case hasWeights:
+		weights := []int{}
+
+		for _, upstream := range activeUpstreams {
+			weight := int(upstream.Weight)
+
+			if weight <= 0 {
+				weight = 1
+			}
+
+			weights = append(weights, weight)
+		}
+
+		handler.LoadBalancing = buildUpstreamsLoadBalancing(
+			upstreamsWeightedRoundRobinPolicy,
+			weights,
+		)
+	default:
+		handler.LoadBalancing = buildUpstreamsLoadBalancing(
+			upstreamsRoundRobinPolicy,
+			nil,
+		)
+	}
+
+	handler.HealthChecks = buildUpstreamsHealthChecks(bindings.healthCheck)
+
+	return handler
+}
+
+func buildUpstreamsLoadBalancing(
+	policy string,
+	weights []int,
+) *ConfigHTTPServerLoadBalancing {
+
+	return &ConfigHTTPServerLoadBalancing{
+		SelectionPolicy: &ConfigHTTPServerSelectionPolicy{
+			Policy:  policy,
+			Weights: weights,
+		},
+		TryDuration: upstreamsTryDuration,
+	}
+}
+
+func buildUpstreamsHealthChecks(
+	healthCheck *proto.EnvServedPortBindingHealthCheck,
+) *ConfigHTTPServerHealthChecks {
+
+	healthChecks := &ConfigHTTPServerHealthChecks{
+		Passive: &ConfigHTTPServerPassiveHealthChecks{
+			FailDuration: upstreamsPassiveFailDuration,
+		},
+	}
+
+	if healthCheck == nil || len(healthCheck.Path) == 0 {
+		return healthChecks
+	}
+
+	intervalSeconds := int(healthCheck.IntervalSeconds)
+
+	if intervalSeconds <= 0 {
+		intervalSeconds = healthCheckDefaultIntervalSeconds
+	}
+
+	timeoutSeconds := int(healthCheck.TimeoutSeconds)
+
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = healthCheckDefaultTimeoutSeconds
+	}
+
+	healthChecks.Active = &ConfigHTTPServerActiveHealthChecks{
+		URI:          healthCheck.Path,
+		Interval:     strconv.Itoa(intervalSeconds) + "s",
+		Timeout:      strconv.Itoa(timeoutSeconds) + "s",
+		ExpectStatus: int(healthCheck.ExpectedStatus),
+	}
+
+	return healthChecks
+}
+
+func buildUpstreamDial(port string) string {
+	return net.JoinHostPort("127.0.0.1", port)
+}
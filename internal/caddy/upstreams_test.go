@@ -0,0 +1,119 @@
+package caddy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+func TestBuildUpstreamsHandler(t *testing.T) {
+	testCases := []struct {
+		test            string
+		bindings        servedPortBindings
+		expectedHandler ConfigHTTPServerHandle
+	}{
+		{
+			test: "with weighted upstreams",
+			bindings: servedPortBindings{
+				upstreams: []*proto.EnvServedPortBindingUpstream{
+					{
+						Port:   "3000",
+						Weight: 3,
+					},
+
+					{
+						Port: "3001",
+					},
+				},
+			},
+			expectedHandler: ConfigHTTPServerHandle{
+				Handler: "reverse_proxy",
+				Upstreams: []ConfigHTTPServerUpstreams{
+					{
+						Dial: "127.0.0.1:3000",
+					},
+
+					{
+						Dial: "127.0.0.1:3001",
+					},
+				},
+				LoadBalancing: &ConfigHTTPServerLoadBalancing{
+					SelectionPolicy: &ConfigHTTPServerSelectionPolicy{
+						Policy:  "weighted_round_robin",
+						Weights: []int{3, 1},
+					},
+					TryDuration: "2s",
+				},
+				HealthChecks: &ConfigHTTPServerHealthChecks{
+					Passive: &ConfigHTTPServerPassiveHealthChecks{
+						FailDuration: "10s",
+					},
+				},
+			},
+		},
+
+		{
+			test: "with standby upstream and health check",
+			bindings: servedPortBindings{
+				upstreams: []*proto.EnvServedPortBindingUpstream{
+					{
+						Port:    "3000",
+						Standby: true,
+					},
+
+					{
+						Port: "3001",
+					},
+				},
+				healthCheck: &proto.EnvServedPortBindingHealthCheck{
+					Path:           "/health",
+					ExpectedStatus: 200,
+				},
+			},
+			expectedHandler: ConfigHTTPServerHandle{
+				Handler: "reverse_proxy",
+				Upstreams: []ConfigHTTPServerUpstreams{
+					{
+						Dial: "127.0.0.1:3001",
+					},
+
+					{
+						Dial: "127.0.0.1:3000",
+					},
+				},
+				LoadBalancing: &ConfigHTTPServerLoadBalancing{
+					SelectionPolicy: &ConfigHTTPServerSelectionPolicy{
+						Policy: "first",
+					},
+					TryDuration: "2s",
+				},
+				HealthChecks: &ConfigHTTPServerHealthChecks{
+					Active: &ConfigHTTPServerActiveHealthChecks{
+						URI:          "/health",
+						Interval:     "5s",
+						Timeout:      "2s",
+						ExpectStatus: 200,
+					},
+					Passive: &ConfigHTTPServerPassiveHealthChecks{
+						FailDuration: "10s",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			handler := buildUpstreamsHandler(tc.bindings)
+
+			if !reflect.DeepEqual(handler, tc.expectedHandler) {
+				t.Fatalf(
+					"expected handler to equal '%+v', got '%+v'",
+					tc.expectedHandler,
+					handler,
+				)
+			}
+		})
+	}
+}
@@ -3,22 +3,146 @@ package env
 import (
 	"encoding/json"
 	"os"
+	"regexp"
 	"sync"
 
 	"github.com/eleven-sh/agent/internal/system"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Bindings that are not backed by a local port
+const (
+	EnvServedPortBindingTypeDirectory entities.EnvServedPortBindingType = "directory"
+	EnvServedPortBindingTypeRedirect  entities.EnvServedPortBindingType = "redirect"
 )
 
 type ConfigServedPort string
 type ConfigServedPorts map[ConfigServedPort]bool
 
+type ConfigServedPortProtocol string
+
+const (
+	ConfigServedPortProtocolHTTP ConfigServedPortProtocol = "http"
+	ConfigServedPortProtocolTCP  ConfigServedPortProtocol = "tcp"
+	ConfigServedPortProtocolUDP  ConfigServedPortProtocol = "udp"
+)
+
+type ConfigProxyProtocolVersion string
+
+const (
+	ConfigProxyProtocolV1 ConfigProxyProtocolVersion = "v1"
+	ConfigProxyProtocolV2 ConfigProxyProtocolVersion = "v2"
+)
+
+// PROXY protocol version sent to the served ports
+type ConfigProxyProtocols map[ConfigServedPort]ConfigProxyProtocolVersion
+
+// Non-HTTP port binding served by the agent instead of Caddy
+type ConfigForwardedPort struct {
+	Protocol         ConfigServedPortProtocol   `json:"protocol"`
+	ListenPort       string                     `json:"listen_port"`
+	TargetPort       string                     `json:"target_port"`
+	TargetUnixSocket string                     `json:"target_unix_socket,omitempty"`
+	ProxyProtocol    ConfigProxyProtocolVersion `json:"proxy_protocol,omitempty"`
+}
+
+type ConfigForwardedPorts []ConfigForwardedPort
+
+// Wildcard domain used to serve listening ports as "<port>.<domain>"
+type ConfigPreviewDomain struct {
+	Domain       string   `json:"domain"`
+	AllowedPorts []string `json:"allowed_ports"`
+	DeniedPorts  []string `json:"denied_ports"`
+}
+
+// Addresses on which the served ports listened on localhost are exposed
+type ConfigLocalhostProxies struct {
+	BindInterface string   `json:"bind_interface"`
+	BindAddrs     []string `json:"bind_addrs"`
+}
+
+// "MaxRecordings" set to 0 means the default limit
+type ConfigSessionRecording struct {
+	Enabled       bool `json:"enabled"`
+	MaxRecordings int  `json:"max_recordings"`
+}
+
+// Persisted with "protojson" given that "encoding/json" is not stable for proto messages
+type ConfigServedPortsBindings map[string]*proto.EnvServedPortBindings
+
+func (c ConfigServedPortsBindings) MarshalJSON() ([]byte, error) {
+	bindingsJSON := map[string]json.RawMessage{}
+
+	for port, bindings := range c {
+		// Proto names are the names used before "protojson"
+		bindingJSON, err := protojson.MarshalOptions{
+			UseProtoNames: true,
+		}.Marshal(bindings)
+
+		if err != nil {
+			return nil, err
+		}
+
+		bindingsJSON[port] = bindingJSON
+	}
+
+	return json.Marshal(bindingsJSON)
+}
+
+func (c *ConfigServedPortsBindings) UnmarshalJSON(data []byte) error {
+	var bindingsJSON map[string]json.RawMessage
+	err := json.Unmarshal(data, &bindingsJSON)
+
+	if err != nil {
+		return err
+	}
+
+	servedPortsBindings := ConfigServedPortsBindings{}
+
+	for port, bindingJSON := range bindingsJSON {
+		if string(bindingJSON) == "null" {
+			continue
+		}
+
+		bindings := &proto.EnvServedPortBindings{}
+
+		// Fields removed since the config was saved are ignored
+		err := protojson.UnmarshalOptions{
+			DiscardUnknown: true,
+		}.Unmarshal(bindingJSON, bindings)
+
+		if err != nil {
+			return err
+		}
+
+		servedPortsBindings[port] = bindings
+	}
+
+	*c = servedPortsBindings
+
+	return nil
+}
+
 type ConfigLongRunningProcessWD string
 type ConfigLongRunningProcessCmd string
 type ConfigLongRunningProcesses map[ConfigLongRunningProcessWD]ConfigLongRunningProcessCmd
 
+// Ports listened on by the processes when started
+type ConfigLongRunningProcessesPorts map[ConfigLongRunningProcessWD][]string
+
 type Config struct {
-	Workspace            *WorkspaceConfig           `json:"workspace"`
-	ServedPorts          ConfigServedPorts          `json:"served_ports"`
-	LongRunningProcesses ConfigLongRunningProcesses `json:"long_running_processes"`
+	Workspace                 *WorkspaceConfig                `json:"workspace"`
+	ServedPorts               ConfigServedPorts               `json:"served_ports"`
+	ServedPortsBindings       ConfigServedPortsBindings       `json:"served_ports_bindings"`
+	ForwardedPorts            ConfigForwardedPorts            `json:"forwarded_ports"`
+	ProxyProtocols            ConfigProxyProtocols            `json:"proxy_protocols"`
+	PreviewDomain             *ConfigPreviewDomain            `json:"preview_domain"`
+	LongRunningProcesses      ConfigLongRunningProcesses      `json:"long_running_processes"`
+	LongRunningProcessesPorts ConfigLongRunningProcessesPorts `json:"long_running_processes_ports,omitempty"`
+	LocalhostProxies          *ConfigLocalhostProxies         `json:"localhost_proxies"`
+	SessionRecording          *ConfigSessionRecording         `json:"session_recording"`
 }
 
 var configLock sync.RWMutex
@@ -27,31 +151,71 @@ func NewConfig() *Config {
 	return &Config{
 		Workspace:            newWorkspaceConfig(),
 		ServedPorts:          ConfigServedPorts{},
+		ServedPortsBindings:  ConfigServedPortsBindings{},
+		ForwardedPorts:       ConfigForwardedPorts{},
+		ProxyProtocols:       ConfigProxyProtocols{},
 		LongRunningProcesses: ConfigLongRunningProcesses{},
 	}
 }
 
-func LoadConfig(
-	configFilePath string,
-) (*Config, error) {
+// Served port keys are used as file names (eg: access logs)
+var servedPortNameRegExp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
 
-	configLock.RLock()
-	defer configLock.RUnlock()
+func IsValidServedPortName(name string) bool {
+	return servedPortNameRegExp.MatchString(name)
+}
 
-	configFileContent, err := os.ReadFile(configFilePath)
+func IsDomainServedPortBinding(binding *proto.EnvServedPortBinding) bool {
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case entities.EnvServedPortBindingTypeDomain,
+		EnvServedPortBindingTypeDirectory,
+		EnvServedPortBindingTypeRedirect:
+		return true
+	}
 
-	if err != nil {
-		return nil, err
+	return false
+}
+
+// Domain bindings and bindings without protocol are HTTP bindings
+func GetServedPortBindingProtocol(
+	binding *proto.EnvServedPortBinding,
+) ConfigServedPortProtocol {
+
+	if IsDomainServedPortBinding(binding) ||
+		len(binding.Protocol) == 0 {
+
+		return ConfigServedPortProtocolHTTP
 	}
 
-	var config *Config
-	err = json.Unmarshal(configFileContent, &config)
+	return ConfigServedPortProtocol(binding.Protocol)
+}
+
+// Symlinks are not resolved given that the socket may not exist yet
+func GetServedPortBindingUnixSocketPath(
+	binding *proto.EnvServedPortBinding,
+) string {
+
+	if len(binding.UnixSocket) == 0 {
+		return ""
+	}
+
+	socketPath, err := ResolveWorkspacePath(binding.UnixSocket)
 
 	if err != nil {
-		return nil, err
+		return ""
 	}
 
-	return config, nil
+	return socketPath
+}
+
+func LoadConfig(
+	configFilePath string,
+) (*Config, error) {
+
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	return loadConfig(configFilePath)
 }
 
 func LoadConfigIfExists(
@@ -79,6 +243,56 @@ func SaveConfigAsFile(
 	configLock.Lock()
 	defer configLock.Unlock()
 
+	return saveConfigAsFile(configFilePath, config)
+}
+
+// The config is locked from load to save
+func UpdateConfig(
+	configFilePath string,
+	update func(config *Config) error,
+) error {
+
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	config, err := loadConfig(configFilePath)
+
+	if err != nil {
+		return err
+	}
+
+	if err := update(config); err != nil {
+		return err
+	}
+
+	return saveConfigAsFile(configFilePath, config)
+}
+
+func loadConfig(
+	configFilePath string,
+) (*Config, error) {
+
+	configFileContent, err := os.ReadFile(configFilePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var config *Config
+	err = json.Unmarshal(configFileContent, &config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func saveConfigAsFile(
+	configFilePath string,
+	config *Config,
+) error {
+
 	configAsJSON, err := json.Marshal(config)
 
 	if err != nil {
@@ -0,0 +1,81 @@
+package env
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+func TestConfigServedPortsBindingsJSON(t *testing.T) {
+	servedPortsBindings := ConfigServedPortsBindings{
+		"3000": {
+			Bindings: []*proto.EnvServedPortBinding{
+				{
+					Value:           "app.domain.com",
+					Type:            "domain",
+					RedirectToHttps: true,
+					HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+						Cors: &proto.EnvServedPortBindingCORS{
+							AllowedOrigins: []string{"https://domain.com"},
+							MaxAgeSeconds:  3600,
+						},
+					},
+				},
+			},
+			ProxyProtocol: "v2",
+		},
+	}
+
+	// Configs saved before "protojson" was used
+	legacyJSON, err := json.Marshal(
+		map[string]*proto.EnvServedPortBindings(servedPortsBindings),
+	)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	currentJSON, err := json.Marshal(servedPortsBindings)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	testCases := []struct {
+		test string
+		JSON []byte
+	}{
+		{
+			test: "with current format",
+			JSON: currentJSON,
+		},
+
+		{
+			test: "with legacy format",
+			JSON: legacyJSON,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			var decodedBindings ConfigServedPortsBindings
+			err := json.Unmarshal(tc.JSON, &decodedBindings)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if len(decodedBindings) != len(servedPortsBindings) ||
+				!protobuf.Equal(decodedBindings["3000"], servedPortsBindings["3000"]) {
+
+				t.Fatalf(
+					"expected bindings to equal '%+v', got '%+v'",
+					servedPortsBindings,
+					decodedBindings,
+				)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package env
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/eleven-sh/agent/config"
 	"github.com/eleven-sh/agent/internal/system"
@@ -183,3 +185,73 @@ func getRepoDirPathInWorkspace(
 		entities.BuildSlugForEnv(repoDirName),
 	)
 }
+
+var ErrPathOutsideWorkspace = errors.New("ErrPathOutsideWorkspace")
+
+// ResolveWorkspacePath returns the absolute version
+// of the passed path. Relative paths are resolved from
+// the workspace directory. An error is returned if the
+// path (or its symlinks target) is outside the workspace.
+func ResolveWorkspacePath(path string) (string, error) {
+	resolvedPath := filepath.Clean(path)
+
+	if !filepath.IsAbs(resolvedPath) {
+		resolvedPath = filepath.Join(config.WorkspaceDirPath, resolvedPath)
+	}
+
+	if !isInWorkspace(resolvedPath) {
+		return "", ErrPathOutsideWorkspace
+	}
+
+	// The path may not exist yet (eg: build directory)
+	symlinksTarget, err := filepath.EvalSymlinks(resolvedPath)
+
+	if err == nil && !isInWorkspace(symlinksTarget) {
+		return "", ErrPathOutsideWorkspace
+	}
+
+	return resolvedPath, nil
+}
+
+// ResolveWorkspaceUnixSocket returns the path of the passed
+// Unix socket with its symlinks resolved. It must be called each
+// time the socket is dialed and the returned path must be the one
+// dialed given that the socket could be replaced by a symlink
+// at any time. An error is returned if the socket doesn't exist
+// or if its target is outside the workspace.
+func ResolveWorkspaceUnixSocket(path string) (string, error) {
+	socketPath, err := ResolveWorkspacePath(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return resolveUnixSocketInDir(config.WorkspaceDirPath, socketPath)
+}
+
+func resolveUnixSocketInDir(
+	dirPath string,
+	socketPath string,
+) (string, error) {
+
+	resolvedSocketPath, err := filepath.EvalSymlinks(socketPath)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !isInDir(dirPath, resolvedSocketPath) {
+		return "", ErrPathOutsideWorkspace
+	}
+
+	return resolvedSocketPath, nil
+}
+
+func isInWorkspace(path string) bool {
+	return isInDir(config.WorkspaceDirPath, path)
+}
+
+func isInDir(dirPath string, path string) bool {
+	return path == dirPath ||
+		strings.HasPrefix(path, dirPath+string(filepath.Separator))
+}
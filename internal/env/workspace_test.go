@@ -1,6 +1,8 @@
 package env
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/eleven-sh/agent/proto"
@@ -141,3 +143,170 @@ func TestGetRepoDirPathInWorkspace(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveWorkspacePath(t *testing.T) {
+	testCases := []struct {
+		test          string
+		path          string
+		expectedPath  string
+		expectedError error
+	}{
+		{
+			test:         "with relative path",
+			path:         "website/dist",
+			expectedPath: "/home/eleven/workspace/website/dist",
+		},
+
+		{
+			test:         "with absolute path",
+			path:         "/home/eleven/workspace/website/dist/",
+			expectedPath: "/home/eleven/workspace/website/dist",
+		},
+
+		{
+			test:         "with workspace path",
+			path:         ".",
+			expectedPath: "/home/eleven/workspace",
+		},
+
+		{
+			test:          "with relative path outside workspace",
+			path:          "../.ssh",
+			expectedError: ErrPathOutsideWorkspace,
+		},
+
+		{
+			test:          "with absolute path outside workspace",
+			path:          "/home/eleven/workspace-backup",
+			expectedError: ErrPathOutsideWorkspace,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			path, err := ResolveWorkspacePath(tc.path)
+
+			if err != tc.expectedError {
+				t.Fatalf(
+					"expected error to equal '%+v', got '%+v'",
+					tc.expectedError,
+					err,
+				)
+			}
+
+			if tc.expectedPath != path {
+				t.Fatalf(
+					"expected path to equal '%s', got '%s'",
+					tc.expectedPath,
+					path,
+				)
+			}
+		})
+	}
+}
+
+func TestResolveUnixSocketInDir(t *testing.T) {
+	rootPath, err := filepath.EvalSymlinks(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	workspacePath := filepath.Join(rootPath, "workspace")
+	outsidePath := filepath.Join(rootPath, "outside")
+
+	for _, dirPath := range []string{workspacePath, outsidePath} {
+		if err := os.Mkdir(dirPath, 0700); err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+	}
+
+	files := []string{
+		filepath.Join(workspacePath, "app.sock"),
+		filepath.Join(outsidePath, "docker.sock"),
+	}
+
+	for _, filePath := range files {
+		if err := os.WriteFile(filePath, nil, 0600); err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+	}
+
+	symlinks := map[string]string{
+		filepath.Join(workspacePath, "link.sock"):    filepath.Join(workspacePath, "app.sock"),
+		filepath.Join(workspacePath, "docker.sock"):  filepath.Join(outsidePath, "docker.sock"),
+		filepath.Join(workspacePath, "outside"):      outsidePath,
+		filepath.Join(workspacePath, "missing.sock"): filepath.Join(workspacePath, "deleted.sock"),
+	}
+
+	for symlinkPath, targetPath := range symlinks {
+		if err := os.Symlink(targetPath, symlinkPath); err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+	}
+
+	testCases := []struct {
+		test          string
+		socketPath    string
+		expectedPath  string
+		expectedError bool
+	}{
+		{
+			test:         "with socket",
+			socketPath:   filepath.Join(workspacePath, "app.sock"),
+			expectedPath: filepath.Join(workspacePath, "app.sock"),
+		},
+
+		{
+			test:         "with symlink to socket in directory",
+			socketPath:   filepath.Join(workspacePath, "link.sock"),
+			expectedPath: filepath.Join(workspacePath, "app.sock"),
+		},
+
+		{
+			test:          "with symlink to socket outside directory",
+			socketPath:    filepath.Join(workspacePath, "docker.sock"),
+			expectedError: true,
+		},
+
+		{
+			test:          "with symlinked parent directory outside directory",
+			socketPath:    filepath.Join(workspacePath, "outside", "docker.sock"),
+			expectedError: true,
+		},
+
+		{
+			test:          "with not existing socket",
+			socketPath:    filepath.Join(workspacePath, "php-fpm.sock"),
+			expectedError: true,
+		},
+
+		{
+			test:          "with broken symlink",
+			socketPath:    filepath.Join(workspacePath, "missing.sock"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			path, err := resolveUnixSocketInDir(workspacePath, tc.socketPath)
+
+			if tc.expectedError && err == nil {
+				t.Fatalf("expected error, got nothing")
+			}
+
+			if !tc.expectedError && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if tc.expectedPath != path {
+				t.Fatalf(
+					"expected path to equal '%s', got '%s'",
+					tc.expectedPath,
+					path,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package errorpage
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/internal/state"
+)
+
+// handleOnDemandTLSAsk allows Caddy to obtain a certificate only
+// for the hosts of the listening ports served on the preview domain
+func handleOnDemandTLSAsk(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get(OnDemandTLSAskDomainParam)
+
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	if err != nil {
+		log.Printf(
+			"[Error page] Error when loading agent config: %v",
+			err,
+		)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if agentConfig == nil || agentConfig.PreviewDomain == nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	listeningPorts, err := state.GetListeningPorts()
+
+	if err != nil {
+		log.Printf(
+			"[Error page] Error when listing listening ports: %v",
+			err,
+		)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	isServed := proxy.IsPreviewDomainHostServed(
+		agentConfig.PreviewDomain,
+		listeningPorts,
+		host,
+	)
+
+	if !isServed {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
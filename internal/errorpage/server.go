@@ -0,0 +1,91 @@
+package errorpage
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/state"
+)
+
+//go:embed upstream_error.html
+var upstreamErrorPageTemplateContent string
+
+var upstreamErrorPageTemplate = template.Must(
+	template.New("upstream_error").Parse(upstreamErrorPageTemplateContent),
+)
+
+type upstreamErrorPageData struct {
+	Port           string
+	RefreshSeconds int
+	Process        *state.LongRunningProcessStatus
+}
+
+// ListenAndServe starts the server that renders the page
+// displayed by Caddy when the upstream of a served port is down.
+// Caddy also asks it before obtaining preview domain certificates.
+func ListenAndServe(serverAddr string) error {
+	serverMux := http.NewServeMux()
+	serverMux.HandleFunc(UpstreamErrorPagePath, handleUpstreamErrorPage)
+	serverMux.HandleFunc(OnDemandTLSAskPath, handleOnDemandTLSAsk)
+
+	return http.ListenAndServe(serverAddr, serverMux)
+}
+
+func handleUpstreamErrorPage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	port := query.Get(UpstreamErrorPagePortParam)
+	refreshSeconds, _ := strconv.Atoi(query.Get(UpstreamErrorPageRefreshParam))
+
+	pageData := upstreamErrorPageData{
+		Port:           port,
+		RefreshSeconds: refreshSeconds,
+	}
+
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	if err != nil {
+		log.Printf(
+			"[Error page] Error when loading agent config: %v",
+			err,
+		)
+	}
+
+	if agentConfig != nil {
+		pageData.Process = state.GetLongRunningProcessStatusForPort(
+			agentConfig,
+			port,
+		)
+	}
+
+	var page bytes.Buffer
+	err = upstreamErrorPageTemplate.Execute(&page, pageData)
+
+	if err != nil {
+		log.Printf(
+			"[Error page] Error when rendering upstream error page: %v",
+			err,
+		)
+
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if refreshSeconds > 0 {
+		w.Header().Set("Refresh", strconv.Itoa(refreshSeconds))
+	}
+
+	w.WriteHeader(http.StatusBadGateway)
+	w.Write(page.Bytes())
+}
@@ -0,0 +1,33 @@
+package errorpage
+
+import (
+	"net/url"
+	"strconv"
+)
+
+const (
+	UpstreamErrorPagePath         = "/upstream-error"
+	UpstreamErrorPagePortParam    = "port"
+	UpstreamErrorPageRefreshParam = "refresh"
+
+	// Requested by Caddy before obtaining a certificate
+	// for a host of the preview domain (on-demand TLS)
+	OnDemandTLSAskPath        = "/on-demand-tls-ask"
+	OnDemandTLSAskDomainParam = "domain"
+)
+
+// BuildUpstreamErrorPageURI returns the URI that the proxy
+// must request when the upstream of the passed port is down.
+func BuildUpstreamErrorPageURI(port string, refreshSeconds int32) string {
+	query := url.Values{}
+	query.Set(UpstreamErrorPagePortParam, port)
+
+	if refreshSeconds > 0 {
+		query.Set(
+			UpstreamErrorPageRefreshParam,
+			strconv.Itoa(int(refreshSeconds)),
+		)
+	}
+
+	return UpstreamErrorPagePath + "?" + query.Encode()
+}
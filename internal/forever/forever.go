@@ -122,6 +122,11 @@ func runStopAction(cmdWD string) error {
 		env.ConfigLongRunningProcessWD(cmdWD),
 	)
 
+	delete(
+		agentConfig.LongRunningProcessesPorts,
+		env.ConfigLongRunningProcessWD(cmdWD),
+	)
+
 	return env.SaveConfigAsFile(
 		config.ElevenAgentConfigFilePath,
 		agentConfig,
@@ -1,24 +1,22 @@
 package grpcserver
 
 import (
-	"github.com/eleven-sh/agent/internal/caddy"
+	"github.com/eleven-sh/agent/internal/proxy"
 	"github.com/eleven-sh/agent/proto"
 )
 
-func (*agentServer) CheckDomainReachability(
+func (s *agentServer) CheckDomainReachability(
 	req *proto.CheckDomainReachabilityRequest,
 	stream proto.Agent_CheckDomainReachabilityServer,
 ) error {
 
-	caddyConfig := caddy.CreateConfigFromServedPorts(req.ServedPorts)
-
-	caddy.UpdateConfigToCheckDomainReachability(
-		caddyConfig,
-		req.Domain,
-		req.UniqueId,
+	return updateProxyServedPorts(
+		s.proxy,
+		req.ServedPorts,
+		req.PreviewDomain,
+		&proxy.DomainReachabilityCheck{
+			Domain:   req.Domain,
+			UniqueID: req.UniqueId,
+		},
 	)
-
-	caddyAPI := caddy.NewAPI()
-
-	return caddyAPI.Load(caddyConfig)
 }
@@ -0,0 +1,64 @@
+package grpcserver
+
+import (
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// GetServedPortsState returns the bindings served by the proxy
+// or the persisted ones if the proxy state is not restored yet.
+func (s *agentServer) GetServedPortsState(
+	req *proto.GetServedPortsStateRequest,
+	stream proto.Agent_GetServedPortsStateServer,
+) error {
+
+	proxyState, err := s.proxy.Current()
+
+	if err != nil {
+		return err
+	}
+
+	if proxyState != nil {
+		return stream.Send(&proto.GetServedPortsStateReply{
+			ServedPorts:   proxyState.ServedPorts,
+			PreviewDomain: getProtoPreviewDomainFromConfig(proxyState.PreviewDomain),
+			PreviewPorts:  proxyState.PreviewPorts,
+		})
+	}
+
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	reply := &proto.GetServedPortsStateReply{
+		ServedPorts:  map[string]*proto.EnvServedPortBindings{},
+		PreviewPorts: []string{},
+	}
+
+	if agentConfig != nil {
+		reply.ServedPorts = agentConfig.ServedPortsBindings
+		reply.PreviewDomain = getProtoPreviewDomainFromConfig(agentConfig.PreviewDomain)
+	}
+
+	return stream.Send(reply)
+}
+
+func getProtoPreviewDomainFromConfig(
+	previewDomain *env.ConfigPreviewDomain,
+) *proto.EnvPreviewDomain {
+
+	if previewDomain == nil {
+		return nil
+	}
+
+	return &proto.EnvPreviewDomain{
+		Domain:       previewDomain.Domain,
+		AllowedPorts: previewDomain.AllowedPorts,
+		DeniedPorts:  previewDomain.DeniedPorts,
+	}
+}
@@ -52,6 +52,7 @@ sudo apt-get --assume-yes --quiet --quiet install \
   jq \
   locales \
   lsb-release \
+  openssh-sftp-server \
   software-properties-common \
   tzdata \
   wget
@@ -80,6 +81,9 @@ fi
 sudo apt-get --assume-yes --quiet --quiet update
 sudo apt-get --assume-yes --quiet --quiet install caddy
 
+# Let Caddy read the workspace directories served by directory bindings
+sudo usermod --append --groups eleven caddy
+
 sudo systemctl disable --now caddy
 sudo systemctl enable --now caddy-api
 
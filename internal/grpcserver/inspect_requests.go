@@ -0,0 +1,133 @@
+package grpcserver
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *agentServer) ListInspectedRequests(
+	req *proto.ListInspectedRequestsRequest,
+	stream proto.Agent_ListInspectedRequestsServer,
+) error {
+
+	records := s.inspector.Records(req.Ports)
+	inspectedRequests := []*proto.InspectedRequest{}
+
+	for _, record := range records {
+		inspectedRequests = append(
+			inspectedRequests,
+			buildProtoInspectedRequest(record),
+		)
+	}
+
+	return stream.Send(&proto.ListInspectedRequestsReply{
+		Requests: inspectedRequests,
+	})
+}
+
+func (s *agentServer) StreamInspectedRequests(
+	req *proto.StreamInspectedRequestsRequest,
+	stream proto.Agent_StreamInspectedRequestsServer,
+) error {
+
+	records, unsubscribe := s.inspector.Subscribe(req.Ports)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case record := <-records:
+			err := stream.Send(&proto.StreamInspectedRequestsReply{
+				Request: buildProtoInspectedRequest(record),
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *agentServer) ReplayRequest(
+	req *proto.ReplayRequestRequest,
+	stream proto.Agent_ReplayRequestServer,
+) error {
+
+	record := s.inspector.Record(req.Id)
+
+	if record == nil {
+		return status.Errorf(
+			codes.NotFound,
+			"no inspected request found with ID %d",
+			req.Id,
+		)
+	}
+
+	replayRecord, err := s.inspector.Replay(stream.Context(), record)
+
+	if errors.Is(err, inspector.ErrTruncatedRequestBody) {
+		return status.Errorf(
+			codes.FailedPrecondition,
+			"the body of the request %d was truncated and cannot be replayed",
+			req.Id,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return stream.Send(&proto.ReplayRequestReply{
+		Request: buildProtoInspectedRequest(replayRecord),
+	})
+}
+
+func buildProtoInspectedRequest(record *inspector.Record) *proto.InspectedRequest {
+	return &proto.InspectedRequest{
+		Id:                    record.ID,
+		Port:                  record.Port,
+		TimestampMs:           record.Timestamp.UnixNano() / 1e6,
+		DurationSeconds:       record.Duration.Seconds(),
+		RemoteAddr:            record.RemoteAddr,
+		Proto:                 record.Proto,
+		Method:                record.Method,
+		Host:                  record.Host,
+		Uri:                   record.URI,
+		RequestHeaders:        buildProtoInspectedHeaders(record.RequestHeaders),
+		RequestBody:           record.RequestBody,
+		RequestBodyTruncated:  record.RequestBodyTruncated,
+		Status:                int32(record.Status),
+		ResponseHeaders:       buildProtoInspectedHeaders(record.ResponseHeaders),
+		ResponseBody:          record.ResponseBody,
+		ResponseBodyTruncated: record.ResponseBodyTruncated,
+		Error:                 record.Error,
+	}
+}
+
+func buildProtoInspectedHeaders(headers http.Header) []*proto.InspectedHeader {
+	// Sorted to return the same
+	// headers order for the same record
+	sortedNames := []string{}
+	for name := range headers {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	inspectedHeaders := []*proto.InspectedHeader{}
+
+	for _, name := range sortedNames {
+		inspectedHeaders = append(inspectedHeaders, &proto.InspectedHeader{
+			Name:   name,
+			Values: headers[name],
+		})
+	}
+
+	return inspectedHeaders
+}
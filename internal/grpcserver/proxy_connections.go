@@ -0,0 +1,74 @@
+package grpcserver
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/state"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListProxyConnections returns the counters and the active
+// connections of the proxies that expose the ports
+// listened on localhost.
+func (s *agentServer) ListProxyConnections(
+	req *proto.ListProxyConnectionsRequest,
+	stream proto.Agent_ListProxyConnectionsServer,
+) error {
+
+	now := time.Now()
+	proxies := []*proto.LocalhostProxy{}
+
+	for _, proxyStats := range state.ListLocalhostProxies(req.Ports) {
+		connections := []*proto.ProxyConnection{}
+
+		for _, conn := range proxyStats.Conns {
+			connections = append(connections, &proto.ProxyConnection{
+				Id:              conn.ID,
+				RemoteAddr:      conn.RemoteAddr,
+				StartedAtMs:     conn.StartedAt.UnixNano() / 1e6,
+				DurationSeconds: now.Sub(conn.StartedAt).Seconds(),
+				BytesIn:         conn.BytesIn,
+				BytesOut:        conn.BytesOut,
+			})
+		}
+
+		proxies = append(proxies, &proto.LocalhostProxy{
+			BindAddr:          proxyStats.BindAddr,
+			Port:              proxyStats.Port,
+			TargetAddr:        proxyStats.TargetAddr,
+			ActiveConnections: proxyStats.ActiveConns,
+			TotalConnections:  proxyStats.TotalConns,
+			BytesIn:           proxyStats.BytesIn,
+			BytesOut:          proxyStats.BytesOut,
+			Errors:            proxyStats.Errors,
+			Connections:       connections,
+		})
+	}
+
+	return stream.Send(&proto.ListProxyConnectionsReply{
+		Proxies: proxies,
+	})
+}
+
+// CloseProxyConnection forcibly closes the
+// proxy connection with the passed ID.
+func (s *agentServer) CloseProxyConnection(
+	req *proto.CloseProxyConnectionRequest,
+	stream proto.Agent_CloseProxyConnectionServer,
+) error {
+
+	err := state.CloseLocalhostProxyConn(req.Id)
+
+	if errors.Is(err, state.ErrLocalhostProxyConnNotFound) {
+		return status.Errorf(
+			codes.NotFound,
+			"proxy connection %d not found",
+			req.Id,
+		)
+	}
+
+	return err
+}
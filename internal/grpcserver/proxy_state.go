@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/internal/state"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// updateProxyServedPorts validates the passed
+// served ports and preview domain and applies them to the proxy.
+func updateProxyServedPorts(
+	p proxy.Proxy,
+	servedPorts map[string]*proto.EnvServedPortBindings,
+	previewDomain *proto.EnvPreviewDomain,
+	domainReachabilityCheck *proxy.DomainReachabilityCheck,
+) error {
+
+	err := validateServedPorts(servedPorts)
+
+	if err != nil {
+		return err
+	}
+
+	err = validatePreviewDomain(previewDomain)
+
+	if err != nil {
+		return err
+	}
+
+	configPreviewDomain := getConfigPreviewDomainFromProto(previewDomain)
+	previewPorts := []string{}
+
+	if configPreviewDomain != nil {
+		listeningPorts, err := state.GetListeningPorts()
+
+		if err != nil {
+			return err
+		}
+
+		previewPorts = proxy.GetPreviewPorts(
+			configPreviewDomain,
+			listeningPorts,
+		)
+	}
+
+	return proxy.Update(p, func(proxyState *proxy.State) error {
+		proxyState.ServedPorts = servedPorts
+		proxyState.PreviewDomain = configPreviewDomain
+		proxyState.PreviewPorts = previewPorts
+		proxyState.DomainReachabilityCheck = domainReachabilityCheck
+
+		return nil
+	})
+}
+
+func getConfigPreviewDomainFromProto(
+	previewDomain *proto.EnvPreviewDomain,
+) *env.ConfigPreviewDomain {
+
+	if previewDomain == nil || len(previewDomain.Domain) == 0 {
+		return nil
+	}
+
+	configPreviewDomain := &env.ConfigPreviewDomain{
+		Domain:       previewDomain.Domain,
+		AllowedPorts: []string{},
+		DeniedPorts:  []string{},
+	}
+
+	configPreviewDomain.AllowedPorts = append(
+		configPreviewDomain.AllowedPorts,
+		previewDomain.AllowedPorts...,
+	)
+
+	configPreviewDomain.DeniedPorts = append(
+		configPreviewDomain.DeniedPorts,
+		previewDomain.DeniedPorts...,
+	)
+
+	return configPreviewDomain
+}
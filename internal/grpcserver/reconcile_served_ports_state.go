@@ -1,40 +1,41 @@
 package grpcserver
 
 import (
+	"sort"
+
 	"github.com/eleven-sh/agent/config"
-	"github.com/eleven-sh/agent/internal/caddy"
 	"github.com/eleven-sh/agent/internal/env"
 	"github.com/eleven-sh/agent/proto"
 	"github.com/eleven-sh/eleven/entities"
 )
 
-func (*agentServer) ReconcileServedPortsState(
+func (s *agentServer) ReconcileServedPortsState(
 	req *proto.ReconcileServedPortsStateRequest,
 	stream proto.Agent_ReconcileServedPortsStateServer,
 ) error {
 
-	caddyConfig := caddy.CreateConfigFromServedPorts(req.ServedPorts)
-	caddyAPI := caddy.NewAPI()
-
-	err := caddyAPI.Load(caddyConfig)
-
-	if err != nil {
-		return err
-	}
-
-	agentConfig, err := env.LoadConfig(
-		config.ElevenAgentConfigFilePath,
+	err := updateProxyServedPorts(
+		s.proxy,
+		req.ServedPorts,
+		req.PreviewDomain,
+		nil,
 	)
 
 	if err != nil {
 		return err
 	}
 
-	agentConfig.ServedPorts = getConfigServedPortsFromProto(req.ServedPorts)
-
-	return env.SaveConfigAsFile(
+	return env.UpdateConfig(
 		config.ElevenAgentConfigFilePath,
-		agentConfig,
+		func(agentConfig *env.Config) error {
+			agentConfig.ServedPorts = getConfigServedPortsFromProto(req.ServedPorts)
+			agentConfig.ServedPortsBindings = req.ServedPorts
+			agentConfig.ForwardedPorts = getConfigForwardedPortsFromProto(req.ServedPorts)
+			agentConfig.ProxyProtocols = getConfigProxyProtocolsFromProto(req.ServedPorts)
+			agentConfig.PreviewDomain = getConfigPreviewDomainFromProto(req.PreviewDomain)
+
+			return nil
+		},
 	)
 }
 
@@ -58,3 +59,68 @@ func getConfigServedPortsFromProto(
 
 	return configServedPorts
 }
+
+func getConfigForwardedPortsFromProto(
+	servedPorts map[string]*proto.EnvServedPortBindings,
+) env.ConfigForwardedPorts {
+
+	// Sorted to have a stable config file content
+	sortedPorts := []string{}
+	for port := range servedPorts {
+		sortedPorts = append(sortedPorts, port)
+	}
+	sort.Strings(sortedPorts)
+
+	configForwardedPorts := env.ConfigForwardedPorts{}
+
+	for _, port := range sortedPorts {
+		bindings := servedPorts[port].Bindings
+
+		for _, binding := range bindings {
+			protocol := env.GetServedPortBindingProtocol(binding)
+
+			if protocol == env.ConfigServedPortProtocolHTTP {
+				continue
+			}
+
+			// Port already bound by user application.
+			// TCP ports are exposed by the localhost proxies.
+			// UDP applications need to listen on all interfaces.
+			if binding.Value == port {
+				continue
+			}
+
+			configForwardedPorts = append(
+				configForwardedPorts,
+				env.ConfigForwardedPort{
+					Protocol:         protocol,
+					ListenPort:       binding.Value,
+					TargetPort:       port,
+					TargetUnixSocket: env.GetServedPortBindingUnixSocketPath(binding),
+					ProxyProtocol:    env.ConfigProxyProtocolVersion(servedPorts[port].ProxyProtocol),
+				},
+			)
+		}
+	}
+
+	return configForwardedPorts
+}
+
+func getConfigProxyProtocolsFromProto(
+	servedPorts map[string]*proto.EnvServedPortBindings,
+) env.ConfigProxyProtocols {
+
+	configProxyProtocols := env.ConfigProxyProtocols{}
+
+	for port, portBindings := range servedPorts {
+		if len(portBindings.ProxyProtocol) == 0 {
+			continue
+		}
+
+		configProxyProtocols[env.ConfigServedPort(port)] = env.ConfigProxyProtocolVersion(
+			portBindings.ProxyProtocol,
+		)
+	}
+
+	return configProxyProtocols
+}
@@ -137,3 +137,164 @@ func TestGetConfigServedPortsFromProto(t *testing.T) {
 		})
 	}
 }
+
+func TestGetConfigForwardedPortsFromProto(t *testing.T) {
+	testCases := []struct {
+		test           string
+		servedPorts    map[string]*proto.EnvServedPortBindings
+		expectedConfig env.ConfigForwardedPorts
+	}{
+		{
+			test:           "with no served ports",
+			servedPorts:    map[string]*proto.EnvServedPortBindings{},
+			expectedConfig: env.ConfigForwardedPorts{},
+		},
+
+		{
+			test: "with only HTTP bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+
+						{
+							Value:    "9000",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolHTTP),
+						},
+
+						{
+							Value:    "api.domain.com",
+							Type:     string(entities.EnvServedPortBindingTypeDomain),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+					},
+				},
+			},
+			expectedConfig: env.ConfigForwardedPorts{},
+		},
+
+		{
+			test: "with TCP and UDP bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"5432": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "5432",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+
+						{
+							Value:    "15432",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+					},
+				},
+
+				"3478": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "13478",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolUDP),
+						},
+					},
+				},
+			},
+			expectedConfig: env.ConfigForwardedPorts{
+				{
+					Protocol:   env.ConfigServedPortProtocolUDP,
+					ListenPort: "13478",
+					TargetPort: "3478",
+				},
+
+				{
+					Protocol:   env.ConfigServedPortProtocolTCP,
+					ListenPort: "15432",
+					TargetPort: "5432",
+				},
+			},
+		},
+
+		{
+			test: "with Unix socket binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"php-fpm": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:      "9000",
+							Type:       string(entities.EnvServedPortBindingTypePort),
+							Protocol:   string(env.ConfigServedPortProtocolTCP),
+							UnixSocket: "run/php-fpm.sock",
+						},
+					},
+				},
+			},
+			expectedConfig: env.ConfigForwardedPorts{
+				{
+					Protocol:         env.ConfigServedPortProtocolTCP,
+					ListenPort:       "9000",
+					TargetPort:       "php-fpm",
+					TargetUnixSocket: "/home/eleven/workspace/run/php-fpm.sock",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			forwardedPortsCfg := getConfigForwardedPortsFromProto(tc.servedPorts)
+
+			if !reflect.DeepEqual(forwardedPortsCfg, tc.expectedConfig) {
+				t.Fatalf(
+					"expected config to equal '%+v', got '%+v'",
+					tc.expectedConfig,
+					forwardedPortsCfg,
+				)
+			}
+		})
+	}
+}
+
+func TestGetConfigProxyProtocolsFromProto(t *testing.T) {
+	servedPorts := map[string]*proto.EnvServedPortBindings{
+		"5432": {
+			Bindings: []*proto.EnvServedPortBinding{
+				{
+					Value:    "5432",
+					Type:     string(entities.EnvServedPortBindingTypePort),
+					Protocol: string(env.ConfigServedPortProtocolTCP),
+				},
+			},
+			ProxyProtocol: string(env.ConfigProxyProtocolV2),
+		},
+
+		"8080": {
+			Bindings: []*proto.EnvServedPortBinding{
+				{
+					Value: "8080",
+					Type:  string(entities.EnvServedPortBindingTypePort),
+				},
+			},
+		},
+	}
+
+	expectedConfig := env.ConfigProxyProtocols{
+		"5432": env.ConfigProxyProtocolV2,
+	}
+
+	proxyProtocolsCfg := getConfigProxyProtocolsFromProto(servedPorts)
+
+	if !reflect.DeepEqual(proxyProtocolsCfg, expectedConfig) {
+		t.Fatalf(
+			"expected config to equal '%+v', got '%+v'",
+			expectedConfig,
+			proxyProtocolsCfg,
+		)
+	}
+}
@@ -6,15 +6,24 @@ import (
 	"net"
 	"os"
 
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/internal/proxy"
 	"github.com/eleven-sh/agent/proto"
 	"google.golang.org/grpc"
 )
 
 type agentServer struct {
 	proto.UnimplementedAgentServer
+	proxy     proxy.Proxy
+	inspector *inspector.Inspector
 }
 
-func ListenAndServe(serverAddrProtocol, serverAddr string) error {
+func ListenAndServe(
+	serverAddrProtocol string,
+	serverAddr string,
+	proxy proxy.Proxy,
+	inspector *inspector.Inspector,
+) error {
 
 	if serverAddrProtocol == "unix" {
 		// Prevent "bind: address already in use" error
@@ -37,7 +46,10 @@ func ListenAndServe(serverAddrProtocol, serverAddr string) error {
 
 	grpcServer := grpc.NewServer()
 
-	proto.RegisterAgentServer(grpcServer, &agentServer{})
+	proto.RegisterAgentServer(grpcServer, &agentServer{
+		proxy:     proxy,
+		inspector: inspector,
+	})
 
 	return grpcServer.Serve(tcpServer)
 }
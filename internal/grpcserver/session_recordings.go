@@ -0,0 +1,111 @@
+package grpcserver
+
+import (
+	"errors"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/recorder"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListSessionRecordings returns the recorded
+// SSH sessions ordered by start time.
+func (s *agentServer) ListSessionRecordings(
+	req *proto.ListSessionRecordingsRequest,
+	stream proto.Agent_ListSessionRecordingsServer,
+) error {
+
+	recordings, err := recorder.New(config.SessionRecordingsDirPath).List()
+
+	if err != nil {
+		return err
+	}
+
+	protoRecordings := []*proto.SessionRecording{}
+
+	for _, recording := range recordings {
+		protoRecording := &proto.SessionRecording{
+			Id:             recording.ID,
+			Type:           string(recording.Type),
+			User:           recording.User,
+			KeyFingerprint: recording.KeyFingerprint,
+			Command:        recording.Command,
+			StartedAtMs:    recording.StartedAt.UnixNano() / 1e6,
+			ExitStatus:     int32(recording.ExitStatus),
+			Interrupted:    recording.Interrupted,
+		}
+
+		if !recording.InProgress() {
+			protoRecording.EndedAtMs = recording.EndedAt.UnixNano() / 1e6
+		}
+
+		protoRecordings = append(protoRecordings, protoRecording)
+	}
+
+	return stream.Send(&proto.ListSessionRecordingsReply{
+		Recordings: protoRecordings,
+	})
+}
+
+// StreamSessionRecording sends the asciicast
+// of the recorded PTY session with the passed ID.
+func (s *agentServer) StreamSessionRecording(
+	req *proto.StreamSessionRecordingRequest,
+	stream proto.Agent_StreamSessionRecordingServer,
+) error {
+
+	err := recorder.New(config.SessionRecordingsDirPath).StreamCast(
+		stream.Context(),
+		req.Id,
+		req.Follow,
+		func(data []byte) error {
+			return stream.Send(&proto.StreamSessionRecordingReply{
+				Data: data,
+			})
+		},
+	)
+
+	return buildSessionRecordingError(req.Id, err)
+}
+
+// DeleteSessionRecording removes the ended
+// recording with the passed ID.
+func (s *agentServer) DeleteSessionRecording(
+	req *proto.DeleteSessionRecordingRequest,
+	stream proto.Agent_DeleteSessionRecordingServer,
+) error {
+
+	err := recorder.New(config.SessionRecordingsDirPath).Delete(req.Id)
+
+	return buildSessionRecordingError(req.Id, err)
+}
+
+func buildSessionRecordingError(ID string, err error) error {
+	if errors.Is(err, recorder.ErrRecordingNotFound) {
+		return status.Errorf(
+			codes.NotFound,
+			"session recording \"%s\" not found",
+			ID,
+		)
+	}
+
+	if errors.Is(err, recorder.ErrRecordingInProgress) {
+		return status.Errorf(
+			codes.FailedPrecondition,
+			"session recording \"%s\" is in progress",
+			ID,
+		)
+	}
+
+	if errors.Is(err, recorder.ErrRecordingHasNoCast) {
+		return status.Errorf(
+			codes.FailedPrecondition,
+			"session recording \"%s\" is an \"exec\" session without output",
+			ID,
+		)
+	}
+
+	return err
+}
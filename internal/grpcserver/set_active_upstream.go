@@ -0,0 +1,72 @@
+package grpcserver
+
+import (
+	"errors"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetActiveUpstream atomically moves the passed domain to the passed
+// upstream port. The change is persisted in the agent config
+// so that it survives agent restarts.
+func (s *agentServer) SetActiveUpstream(
+	req *proto.SetActiveUpstreamRequest,
+	stream proto.Agent_SetActiveUpstreamServer,
+) error {
+
+	err := proxy.Update(s.proxy, func(proxyState *proxy.State) error {
+		return proxy.SetActiveUpstream(proxyState, req.Domain, req.Port)
+	})
+
+	if errors.Is(err, proxy.ErrUpstreamsNotFound) {
+		return status.Errorf(
+			codes.NotFound,
+			"no domain binding with multiple upstreams found for \"%s\"",
+			req.Domain,
+		)
+	}
+
+	if errors.Is(err, proxy.ErrUpstreamNotFound) {
+		return status.Errorf(
+			codes.InvalidArgument,
+			"port %s is not an upstream of \"%s\"",
+			req.Port,
+			req.Domain,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return env.UpdateConfig(
+		config.ElevenAgentConfigFilePath,
+		func(agentConfig *env.Config) error {
+			err := proxy.SetActiveUpstream(
+				&proxy.State{
+					ServedPorts: agentConfig.ServedPortsBindings,
+				},
+				req.Domain,
+				req.Port,
+			)
+
+			// Bindings updated since the proxy state was applied.
+			// The change would be reverted by the next reconciliation.
+			if err != nil {
+				return status.Errorf(
+					codes.FailedPrecondition,
+					"the bindings of \"%s\" have changed, the active upstream could not be saved: %v",
+					req.Domain,
+					err,
+				)
+			}
+
+			return nil
+		},
+	)
+}
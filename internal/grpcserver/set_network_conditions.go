@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"errors"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetNetworkConditions sets (or removes) the network conditions
+// simulated for the passed binding without restarting its upstream.
+// The change is persisted in the agent config so that
+// it survives agent restarts.
+func (s *agentServer) SetNetworkConditions(
+	req *proto.SetNetworkConditionsRequest,
+	stream proto.Agent_SetNetworkConditionsServer,
+) error {
+
+	err := validateServedPortBindingNetworkConditions(req.Conditions)
+
+	if err != nil {
+		return newInvalidFieldError(
+			"conditions",
+			"invalid network conditions",
+			err,
+		)
+	}
+
+	err = proxy.Update(s.proxy, func(proxyState *proxy.State) error {
+		return proxy.SetNetworkConditions(proxyState, req.Binding, req.Conditions)
+	})
+
+	if errors.Is(err, proxy.ErrBindingNotFound) {
+		return status.Errorf(
+			codes.NotFound,
+			"no HTTP binding found for \"%s\"",
+			req.Binding,
+		)
+	}
+
+	if errors.Is(err, proxy.ErrNetworkConditionsNotSupported) {
+		return status.Errorf(
+			codes.InvalidArgument,
+			"network conditions are not supported for \"%s\" (only HTTP bindings proxied to their port are)",
+			req.Binding,
+		)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return env.UpdateConfig(
+		config.ElevenAgentConfigFilePath,
+		func(agentConfig *env.Config) error {
+			err := proxy.SetNetworkConditions(
+				&proxy.State{
+					ServedPorts: agentConfig.ServedPortsBindings,
+				},
+				req.Binding,
+				req.Conditions,
+			)
+
+			// Bindings updated since the proxy state was applied.
+			// The change would be reverted by the next reconciliation.
+			if err != nil {
+				return status.Errorf(
+					codes.FailedPrecondition,
+					"the bindings of \"%s\" have changed, the network conditions could not be saved: %v",
+					req.Binding,
+					err,
+				)
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,85 @@
+package grpcserver
+
+import (
+	"net"
+	"strings"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/caddy"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (*agentServer) StreamAccessLogs(
+	req *proto.StreamAccessLogsRequest,
+	stream proto.Agent_StreamAccessLogsServer,
+) error {
+
+	for _, port := range req.Ports {
+		if !isValidServedPortKey(port) {
+			return status.Errorf(
+				codes.InvalidArgument,
+				"invalid port \"%s\"",
+				port,
+			)
+		}
+	}
+
+	return caddy.TailAccessLogs(
+		stream.Context(),
+		config.CaddyAccessLogsDirPath,
+		req.Ports,
+		int(req.Tail),
+		func(entry *caddy.AccessLogEntry) error {
+			if !doesAccessLogEntryMatchRequest(entry, req) {
+				return nil
+			}
+
+			return stream.Send(&proto.StreamAccessLogsReply{
+				Port:            entry.Port,
+				TimestampMs:     entry.Timestamp.UnixNano() / 1e6,
+				RemoteAddr:      entry.RemoteAddr,
+				Proto:           entry.Proto,
+				Method:          entry.Method,
+				Host:            entry.Host,
+				Uri:             entry.URI,
+				Status:          int32(entry.Status),
+				Size:            entry.Size,
+				DurationSeconds: entry.Duration.Seconds(),
+			})
+		},
+	)
+}
+
+func doesAccessLogEntryMatchRequest(
+	entry *caddy.AccessLogEntry,
+	req *proto.StreamAccessLogsRequest,
+) bool {
+
+	if req.MinStatus > 0 && int32(entry.Status) < req.MinStatus {
+		return false
+	}
+
+	if req.MaxStatus > 0 && int32(entry.Status) > req.MaxStatus {
+		return false
+	}
+
+	if len(req.Domains) == 0 {
+		return true
+	}
+
+	entryDomain := entry.Host
+
+	if host, _, err := net.SplitHostPort(entry.Host); err == nil {
+		entryDomain = host
+	}
+
+	for _, domain := range req.Domains {
+		if strings.EqualFold(domain, entryDomain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,44 @@
+package grpcserver
+
+import (
+	"github.com/eleven-sh/agent/internal/sshserver"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// ValidateAuthorizedKeys returns the valid keys and the invalid
+// lines of the passed "authorized_keys" content. Invalid lines
+// are skipped by the SSH server so that they do not prevent
+// the other keys from being used.
+func (s *agentServer) ValidateAuthorizedKeys(
+	req *proto.ValidateAuthorizedKeysRequest,
+	stream proto.Agent_ValidateAuthorizedKeysServer,
+) error {
+
+	validKeys, lineErrors := sshserver.ValidateAuthorizedKeys(
+		[]byte(req.Content),
+	)
+
+	protoValidKeys := []*proto.ValidAuthorizedKey{}
+
+	for _, validKey := range validKeys {
+		protoValidKeys = append(protoValidKeys, &proto.ValidAuthorizedKey{
+			Line:        int32(validKey.Line),
+			Fingerprint: validKey.Fingerprint,
+			Comment:     validKey.Comment,
+		})
+	}
+
+	protoInvalidLines := []*proto.InvalidAuthorizedKeysLine{}
+
+	for _, lineError := range lineErrors {
+		protoInvalidLines = append(protoInvalidLines, &proto.InvalidAuthorizedKeysLine{
+			Line:  int32(lineError.Line),
+			Error: lineError.Err.Error(),
+		})
+	}
+
+	return stream.Send(&proto.ValidateAuthorizedKeysReply{
+		ValidKeys:    protoValidKeys,
+		InvalidLines: protoInvalidLines,
+	})
+}
@@ -0,0 +1,631 @@
+package grpcserver
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/caddy"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/netsim"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	inspectorMaxBodyBytes = 10 * 1024 * 1024 // 10MB
+	inspectorMaxRecords   = 1000
+
+	networkConditionsMaxDelayMs = 60 * 1000 // 1min
+)
+
+var hostnameLabelRegexp = regexp.MustCompile(`(?i)^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// validateServedPorts returns an "InvalidArgument"
+// gRPC error if one of the passed bindings is invalid
+// or conflicts with another one. The invalid binding
+// is named in a "BadRequest" error detail.
+func validateServedPorts(
+	servedPorts map[string]*proto.EnvServedPortBindings,
+) error {
+
+	// Sorted to return the same error for the same request
+	sortedPorts := []string{}
+	for port := range servedPorts {
+		sortedPorts = append(sortedPorts, port)
+	}
+	sort.Strings(sortedPorts)
+
+	// Ports listened on by the user applications
+	applicationPorts := map[string]bool{}
+
+	for port, portBindings := range servedPorts {
+		for _, binding := range portBindings.Bindings {
+			if isStaticServedPortBinding(binding) || len(binding.UnixSocket) > 0 {
+				continue
+			}
+
+			applicationPorts[port] = true
+		}
+	}
+
+	// Ports dialed by the HTTP proxy that
+	// doesn't send the PROXY protocol header
+	httpProxiedPorts := buildHTTPProxiedPorts(servedPorts)
+
+	// Bound ports and domains
+	// mapped to the binding field
+	boundAddrs := map[string]string{}
+
+	for _, port := range sortedPorts {
+		if !isValidServedPortKey(port) {
+			return newInvalidFieldError(
+				fmt.Sprintf("served_ports[%s]", port),
+				fmt.Sprintf("invalid served port %s", port),
+				errors.New("must be a port or a name made of letters, digits, \"-\" and \"_\""),
+			)
+		}
+
+		err := validateServedPortInspector(servedPorts[port].Inspector)
+
+		if err != nil {
+			return newInvalidFieldError(
+				fmt.Sprintf("served_ports[%s].inspector", port),
+				fmt.Sprintf("invalid inspector for port %s", port),
+				err,
+			)
+		}
+
+		switch env.ConfigProxyProtocolVersion(servedPorts[port].ProxyProtocol) {
+		case "",
+			env.ConfigProxyProtocolV1,
+			env.ConfigProxyProtocolV2:
+		default:
+			return newInvalidFieldError(
+				fmt.Sprintf("served_ports[%s].proxy_protocol", port),
+				fmt.Sprintf("invalid PROXY protocol for port %s", port),
+				fmt.Errorf(
+					"unsupported version \"%s\" (must be \"v1\" or \"v2\")",
+					servedPorts[port].ProxyProtocol,
+				),
+			)
+		}
+
+		if len(servedPorts[port].ProxyProtocol) > 0 && httpProxiedPorts[port] {
+			return newInvalidFieldError(
+				fmt.Sprintf("served_ports[%s].proxy_protocol", port),
+				fmt.Sprintf("invalid PROXY protocol for port %s", port),
+				errors.New("not supported for ports proxied by HTTP bindings"),
+			)
+		}
+
+		for bindingIndex, binding := range servedPorts[port].Bindings {
+			err := validateServedPortBinding(port, binding)
+
+			if err != nil {
+				return newInvalidBindingError(port, bindingIndex, binding, err)
+			}
+
+			boundAddr := buildServedPortBindingAddr(binding)
+			conflictingBindingField, isBound := boundAddrs[boundAddr]
+
+			if isBound {
+				return newInvalidBindingError(
+					port,
+					bindingIndex,
+					binding,
+					fmt.Errorf(
+						"\"%s\" is already bound by %s",
+						binding.Value,
+						conflictingBindingField,
+					),
+				)
+			}
+
+			isPortBinding := !env.IsDomainServedPortBinding(binding)
+
+			if isPortBinding && binding.Value != port && applicationPorts[binding.Value] {
+				return newInvalidBindingError(
+					port,
+					bindingIndex,
+					binding,
+					fmt.Errorf(
+						"port %s is used by a served application",
+						binding.Value,
+					),
+				)
+			}
+
+			boundAddrs[boundAddr] = buildServedPortBindingField(port, bindingIndex)
+		}
+	}
+
+	return nil
+}
+
+func validateServedPortBinding(
+	port string,
+	binding *proto.EnvServedPortBinding,
+) error {
+
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case entities.EnvServedPortBindingTypePort,
+		entities.EnvServedPortBindingTypeDomain,
+		env.EnvServedPortBindingTypeDirectory,
+		env.EnvServedPortBindingTypeRedirect:
+	default:
+		return fmt.Errorf("unsupported binding type \"%s\"", binding.Type)
+	}
+
+	switch env.ConfigServedPortProtocol(binding.Protocol) {
+	case "",
+		env.ConfigServedPortProtocolHTTP,
+		env.ConfigServedPortProtocolTCP,
+		env.ConfigServedPortProtocolUDP:
+	default:
+		return fmt.Errorf("unsupported protocol \"%s\"", binding.Protocol)
+	}
+
+	// Static and Unix socket bindings are not backed by a local port
+	if !isStaticServedPortBinding(binding) &&
+		len(binding.UnixSocket) == 0 &&
+		!isValidPort(port) {
+
+		return fmt.Errorf("invalid port \"%s\"", port)
+	}
+
+	if env.IsDomainServedPortBinding(binding) {
+		if !isValidHostname(binding.Value) {
+			return fmt.Errorf("invalid domain \"%s\"", binding.Value)
+		}
+	} else {
+		if !isValidPort(binding.Value) {
+			return fmt.Errorf("invalid port \"%s\"", binding.Value)
+		}
+
+		if isReservedPort(binding.Value) {
+			return fmt.Errorf("port %s is reserved by the agent", binding.Value)
+		}
+	}
+
+	if httpOptions := binding.HttpOptions; httpOptions != nil &&
+		httpOptions.Cors != nil &&
+		httpOptions.Cors.AllowCredentials &&
+		!caddy.HasExplicitCORSOrigins(httpOptions.Cors) {
+
+		return errors.New("CORS credentials require an explicit list of allowed origins (without \"*\")")
+	}
+
+	if len(binding.Upstreams) > 0 || binding.HealthCheck != nil {
+		if binding.Type != string(entities.EnvServedPortBindingTypeDomain) {
+			return errors.New("upstreams are only supported on domain bindings")
+		}
+
+		err := validateServedPortBindingUpstreams(binding)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(binding.UnixSocket) > 0 {
+		err := validateServedPortBindingUnixSocket(port, binding)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if netsim.IsEnabled(binding) {
+		if !netsim.IsSupported(port, binding) {
+			return errors.New("network conditions are only supported on HTTP bindings proxied to their port")
+		}
+
+		err := validateServedPortBindingNetworkConditions(binding.NetworkConditions)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case env.EnvServedPortBindingTypeDirectory:
+		if binding.Directory == nil || len(binding.Directory.Path) == 0 {
+			return errors.New("directory path is required")
+		}
+
+		_, err := env.ResolveWorkspacePath(binding.Directory.Path)
+
+		if err != nil {
+			return fmt.Errorf(
+				"directory \"%s\" is outside the workspace",
+				binding.Directory.Path,
+			)
+		}
+	case env.EnvServedPortBindingTypeRedirect:
+		if binding.Redirect == nil || len(binding.Redirect.Url) == 0 {
+			return errors.New("redirect URL is required")
+		}
+
+		redirectURL, err := url.Parse(binding.Redirect.Url)
+
+		if err != nil ||
+			(redirectURL.Scheme != "http" && redirectURL.Scheme != "https") ||
+			len(redirectURL.Host) == 0 {
+
+			return fmt.Errorf(
+				"redirect URL \"%s\" must be an absolute HTTP(S) URL",
+				binding.Redirect.Url,
+			)
+		}
+	}
+
+	return nil
+}
+
+func validateServedPortInspector(inspector *proto.EnvServedPortInspector) error {
+	if inspector == nil {
+		return nil
+	}
+
+	if inspector.MaxBodyBytes < 0 || inspector.MaxBodyBytes > inspectorMaxBodyBytes {
+		return fmt.Errorf(
+			"max body bytes must be between 0 and %d",
+			inspectorMaxBodyBytes,
+		)
+	}
+
+	if inspector.MaxRecords < 0 || inspector.MaxRecords > inspectorMaxRecords {
+		return fmt.Errorf(
+			"max records must be between 0 and %d",
+			inspectorMaxRecords,
+		)
+	}
+
+	return nil
+}
+
+func validateServedPortBindingUnixSocket(
+	port string,
+	binding *proto.EnvServedPortBinding,
+) error {
+
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case entities.EnvServedPortBindingTypePort,
+		entities.EnvServedPortBindingTypeDomain:
+	default:
+		return errors.New("Unix sockets are only supported on port and domain bindings")
+	}
+
+	if env.GetServedPortBindingProtocol(binding) == env.ConfigServedPortProtocolUDP {
+		return errors.New("Unix sockets are not supported on UDP bindings")
+	}
+
+	if len(binding.Upstreams) > 0 {
+		return errors.New("Unix sockets could not be used with upstreams")
+	}
+
+	// Skipped like ports already bound by user applications
+	if !env.IsDomainServedPortBinding(binding) && binding.Value == port {
+		return fmt.Errorf(
+			"port %s could not be bound to a Unix socket and served by an application",
+			binding.Value,
+		)
+	}
+
+	_, err := env.ResolveWorkspacePath(binding.UnixSocket)
+
+	if err != nil {
+		return fmt.Errorf(
+			"Unix socket \"%s\" is outside the workspace",
+			binding.UnixSocket,
+		)
+	}
+
+	return nil
+}
+
+func validateServedPortBindingNetworkConditions(
+	conditions *proto.EnvServedPortBindingNetworkConditions,
+) error {
+
+	if conditions == nil {
+		return nil
+	}
+
+	if conditions.LatencyMs < 0 || conditions.LatencyMs > networkConditionsMaxDelayMs {
+		return fmt.Errorf(
+			"latency must be between 0 and %dms",
+			networkConditionsMaxDelayMs,
+		)
+	}
+
+	if conditions.JitterMs < 0 || conditions.JitterMs > networkConditionsMaxDelayMs {
+		return fmt.Errorf(
+			"jitter must be between 0 and %dms",
+			networkConditionsMaxDelayMs,
+		)
+	}
+
+	if conditions.BandwidthBytesPerSecond < 0 {
+		return errors.New("bandwidth must be positive")
+	}
+
+	if conditions.DropRate < 0 || conditions.DropRate > 1 {
+		return errors.New("drop rate must be between 0 and 1")
+	}
+
+	if conditions.ErrorRate < 0 || conditions.ErrorRate > 1 {
+		return errors.New("error rate must be between 0 and 1")
+	}
+
+	if conditions.ErrorStatus != 0 &&
+		(conditions.ErrorStatus < 400 || conditions.ErrorStatus > 599) {
+
+		return fmt.Errorf("invalid error status %d", conditions.ErrorStatus)
+	}
+
+	return nil
+}
+
+func newInvalidBindingError(
+	port string,
+	bindingIndex int,
+	binding *proto.EnvServedPortBinding,
+	err error,
+) error {
+
+	return newInvalidFieldError(
+		buildServedPortBindingField(port, bindingIndex),
+		fmt.Sprintf("invalid binding \"%s\" for port %s", binding.Value, port),
+		err,
+	)
+}
+
+// newInvalidFieldError returns an "InvalidArgument" gRPC error
+// with a "BadRequest" detail naming the passed field.
+func newInvalidFieldError(
+	field string,
+	message string,
+	err error,
+) error {
+
+	invalidFieldStatus := status.Newf(
+		codes.InvalidArgument,
+		"%s: %v",
+		message,
+		err,
+	)
+
+	detailedStatus, detailsErr := invalidFieldStatus.WithDetails(
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{
+					Field:       field,
+					Description: err.Error(),
+				},
+			},
+		},
+	)
+
+	if detailsErr != nil {
+		return invalidFieldStatus.Err()
+	}
+
+	return detailedStatus.Err()
+}
+
+func buildServedPortBindingField(port string, bindingIndex int) string {
+	return fmt.Sprintf("served_ports[%s].bindings[%d]", port, bindingIndex)
+}
+
+// buildServedPortBindingAddr returns the address listened on
+// by the proxy for the passed binding. HTTP and TCP bindings
+// share the same address given that both listen on TCP.
+func buildServedPortBindingAddr(binding *proto.EnvServedPortBinding) string {
+	if env.IsDomainServedPortBinding(binding) {
+		return "domain/" + strings.ToLower(binding.Value)
+	}
+
+	if env.GetServedPortBindingProtocol(binding) == env.ConfigServedPortProtocolUDP {
+		return "udp/" + binding.Value
+	}
+
+	return "tcp/" + binding.Value
+}
+
+// isStaticServedPortBinding returns true if the passed
+// binding is not backed by a local port. Its served port
+// key is only used to group bindings.
+// buildHTTPProxiedPorts returns the ports dialed by the
+// HTTP proxy (ie: the ports of the HTTP bindings that are
+// not bound on themselves and the upstreams ports)
+func buildHTTPProxiedPorts(
+	servedPorts map[string]*proto.EnvServedPortBindings,
+) map[string]bool {
+
+	httpProxiedPorts := map[string]bool{}
+
+	for port, portBindings := range servedPorts {
+		for _, binding := range portBindings.Bindings {
+			if env.GetServedPortBindingProtocol(binding) != env.ConfigServedPortProtocolHTTP ||
+				isStaticServedPortBinding(binding) ||
+				len(binding.UnixSocket) > 0 {
+
+				continue
+			}
+
+			isDomainBinding := env.IsDomainServedPortBinding(binding)
+
+			// Exposed by the localhost proxies
+			if !isDomainBinding && binding.Value == port {
+				continue
+			}
+
+			if isDomainBinding && len(binding.Upstreams) > 0 {
+				for _, upstream := range binding.Upstreams {
+					httpProxiedPorts[upstream.Port] = true
+				}
+
+				continue
+			}
+
+			httpProxiedPorts[port] = true
+		}
+	}
+
+	return httpProxiedPorts
+}
+
+func isStaticServedPortBinding(binding *proto.EnvServedPortBinding) bool {
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case env.EnvServedPortBindingTypeDirectory,
+		env.EnvServedPortBindingTypeRedirect:
+		return true
+	}
+
+	return false
+}
+
+func isReservedPort(port string) bool {
+	for _, reservedPort := range config.EnvReservedPorts {
+		if port == reservedPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateServedPortBindingUpstreams(binding *proto.EnvServedPortBinding) error {
+	hasActiveUpstream := false
+	upstreamPorts := map[string]bool{}
+
+	for _, upstream := range binding.Upstreams {
+		if !isValidPort(upstream.Port) {
+			return fmt.Errorf("invalid upstream port \"%s\"", upstream.Port)
+		}
+
+		if upstreamPorts[upstream.Port] {
+			return fmt.Errorf("duplicate upstream port \"%s\"", upstream.Port)
+		}
+
+		if upstream.Weight < 0 {
+			return fmt.Errorf("invalid weight for upstream port \"%s\"", upstream.Port)
+		}
+
+		upstreamPorts[upstream.Port] = true
+		hasActiveUpstream = hasActiveUpstream || !upstream.Standby
+	}
+
+	if len(binding.Upstreams) > 0 && !hasActiveUpstream {
+		return errors.New("at least one upstream must not be on standby")
+	}
+
+	healthCheck := binding.HealthCheck
+
+	if healthCheck == nil {
+		return nil
+	}
+
+	if len(binding.Upstreams) == 0 {
+		return errors.New("health checks require upstreams")
+	}
+
+	if !strings.HasPrefix(healthCheck.Path, "/") {
+		return fmt.Errorf("health check path \"%s\" must start with \"/\"", healthCheck.Path)
+	}
+
+	if healthCheck.ExpectedStatus != 0 &&
+		(healthCheck.ExpectedStatus < 100 || healthCheck.ExpectedStatus > 599) {
+
+		return fmt.Errorf("invalid health check status %d", healthCheck.ExpectedStatus)
+	}
+
+	return nil
+}
+
+// validatePreviewDomain returns an "InvalidArgument"
+// gRPC error if the passed preview domain is invalid.
+func validatePreviewDomain(previewDomain *proto.EnvPreviewDomain) error {
+	if previewDomain == nil || len(previewDomain.Domain) == 0 {
+		return nil
+	}
+
+	if !isValidHostname(previewDomain.Domain) {
+		return status.Errorf(
+			codes.InvalidArgument,
+			"invalid preview domain \"%s\"",
+			previewDomain.Domain,
+		)
+	}
+
+	ports := append(
+		append([]string{}, previewDomain.AllowedPorts...),
+		previewDomain.DeniedPorts...,
+	)
+
+	for _, port := range ports {
+		if !isValidPort(port) {
+			return status.Errorf(
+				codes.InvalidArgument,
+				"invalid preview domain port \"%s\"",
+				port,
+			)
+		}
+	}
+
+	return nil
+}
+
+func isValidHostname(hostname string) bool {
+	if len(hostname) == 0 || len(hostname) > 253 {
+		return false
+	}
+
+	labels := strings.Split(hostname, ".")
+
+	// At least a domain name and a TLD
+	if len(labels) < 2 {
+		return false
+	}
+
+	for _, label := range labels {
+		if !hostnameLabelRegexp.MatchString(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValidServedPortKey returns true for ports and for the
+// names (eg: "docs") of the served ports that are not backed
+// by a local port
+func isValidServedPortKey(key string) bool {
+	if _, err := strconv.Atoi(key); err == nil {
+		return isValidPort(key)
+	}
+
+	return env.IsValidServedPortName(key)
+}
+
+// isValidPort rejects non canonical values (eg: "0022", "+22")
+// given that ports are compared as strings once validated
+func isValidPort(port string) bool {
+	portAsInt, err := strconv.Atoi(port)
+
+	if err != nil || strconv.Itoa(portAsInt) != port {
+		return false
+	}
+
+	return portAsInt > 0 && portAsInt <= 65535
+}
@@ -0,0 +1,858 @@
+package grpcserver
+
+import (
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateServedPorts(t *testing.T) {
+	testCases := []struct {
+		test          string
+		servedPorts   map[string]*proto.EnvServedPortBindings
+		expectError   bool
+		expectedField string
+	}{
+		{
+			test: "with valid bindings",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8080",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+
+				"docs": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "docs.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeDirectory),
+							Directory: &proto.EnvServedPortBindingDirectory{
+								Path:        "website/dist",
+								SpaFallback: true,
+							},
+						},
+
+						{
+							Value: "www.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeRedirect),
+							Redirect: &proto.EnvServedPortBindingRedirect{
+								Url: "https://domain.com",
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			test: "with directory outside workspace",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"docs": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "docs.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeDirectory),
+							Directory: &proto.EnvServedPortBindingDirectory{
+								Path: "/etc",
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+
+		{
+			test: "with directory without path",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"docs": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "docs.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeDirectory),
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+
+		{
+			test: "with relative redirect URL",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"www": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "www.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeRedirect),
+							Redirect: &proto.EnvServedPortBindingRedirect{
+								Url: "/home",
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+
+		{
+			test: "with only standby upstreams",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+							Upstreams: []*proto.EnvServedPortBindingUpstream{
+								{
+									Port:    "3000",
+									Standby: true,
+								},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+
+		{
+			test: "with upstreams on port binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+							Upstreams: []*proto.EnvServedPortBindingUpstream{
+								{
+									Port: "3001",
+								},
+							},
+						},
+					},
+				},
+			},
+			expectError: true,
+		},
+
+		{
+			test: "with reserved port",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "2019",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[8080].bindings[0]",
+		},
+
+		{
+			test: "with served port name that is not a file name",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"../docs": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "www.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeRedirect),
+							Redirect: &proto.EnvServedPortBindingRedirect{
+								Url: "https://domain.com",
+							},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[../docs]",
+		},
+
+		{
+			test: "with reserved port with leading zeros",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "0022",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[8080].bindings[0]",
+		},
+
+		{
+			test: "with reserved port with sign",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"8080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "+2200",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[8080].bindings[0]",
+		},
+
+		{
+			test: "with served port with leading zeros",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"08080": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8080",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[08080]",
+		},
+
+		{
+			test: "with duplicate external port with leading zeros",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "8000",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+
+						{
+							Value:    "08000",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].bindings[1]",
+		},
+
+		{
+			test: "with duplicate external port",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+
+				"4000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "4000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[4000].bindings[1]",
+		},
+
+		{
+			test: "with same external port for TCP and UDP",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"5353": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "53",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: "tcp",
+						},
+
+						{
+							Value:    "53",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: "udp",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			test: "with external port used by an application",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+				},
+
+				"8000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].bindings[0]",
+		},
+
+		{
+			test: "with duplicate domain",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+						},
+					},
+				},
+
+				"docs": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "API.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeRedirect),
+							Redirect: &proto.EnvServedPortBindingRedirect{
+								Url: "https://domain.com",
+							},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[docs].bindings[0]",
+		},
+
+		{
+			test: "with malformed domain",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api_domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].bindings[0]",
+		},
+
+		{
+			test: "with invalid served port",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"api": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[api].bindings[0]",
+		},
+
+		{
+			test: "with invalid inspector",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+						},
+					},
+					Inspector: &proto.EnvServedPortInspector{
+						Enabled:    true,
+						MaxRecords: -1,
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].inspector",
+		},
+
+		{
+			test: "with invalid network conditions",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+							NetworkConditions: &proto.EnvServedPortBindingNetworkConditions{
+								DropRate: 1.5,
+							},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].bindings[0]",
+		},
+
+		{
+			test: "with network conditions on redirect binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"www": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "www.domain.com",
+							Type:  string(env.EnvServedPortBindingTypeRedirect),
+							Redirect: &proto.EnvServedPortBindingRedirect{
+								Url: "https://domain.com",
+							},
+							NetworkConditions: &proto.EnvServedPortBindingNetworkConditions{
+								LatencyMs: 200,
+							},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[www].bindings[0]",
+		},
+
+		{
+			test: "with Unix socket binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"gunicorn": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:      "api.domain.com",
+							Type:       string(entities.EnvServedPortBindingTypeDomain),
+							UnixSocket: "api/gunicorn.sock",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			test: "with Unix socket outside workspace",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"php-fpm": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:      "9000",
+							Type:       string(entities.EnvServedPortBindingTypePort),
+							UnixSocket: "/run/php/php-fpm.sock",
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[php-fpm].bindings[0]",
+		},
+
+		{
+			test: "with UDP Unix socket binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"daemon": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:      "9000",
+							Type:       string(entities.EnvServedPortBindingTypePort),
+							Protocol:   string(env.ConfigServedPortProtocolUDP),
+							UnixSocket: "daemon.sock",
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[daemon].bindings[0]",
+		},
+
+		{
+			test: "with CORS credentials and explicit origins",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+							HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+								Cors: &proto.EnvServedPortBindingCORS{
+									AllowedOrigins:   []string{"https://app.domain.com"},
+									AllowCredentials: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			test: "with CORS credentials without allowed origins",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+							HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+								Cors: &proto.EnvServedPortBindingCORS{
+									AllowCredentials: true,
+								},
+							},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].bindings[0]",
+		},
+
+		{
+			test: "with CORS credentials and wildcard origin",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+							HttpOptions: &proto.EnvServedPortBindingHTTPOptions{
+								Cors: &proto.EnvServedPortBindingCORS{
+									AllowedOrigins:   []string{"https://app.domain.com", "*"},
+									AllowCredentials: true,
+								},
+							},
+						},
+					},
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].bindings[0]",
+		},
+
+		{
+			test: "with invalid PROXY protocol version",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"5432": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "5432",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+					},
+					ProxyProtocol: "v3",
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[5432].proxy_protocol",
+		},
+
+		{
+			test: "with PROXY protocol on port bound on itself",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "3000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+					ProxyProtocol: "v2",
+				},
+			},
+		},
+
+		{
+			test: "with PROXY protocol on port with domain binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "3000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+						},
+					},
+					ProxyProtocol: "v1",
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].proxy_protocol",
+		},
+
+		{
+			test: "with PROXY protocol on port with HTTP port binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+					ProxyProtocol: "v2",
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3000].proxy_protocol",
+		},
+
+		{
+			test: "with PROXY protocol on upstream port",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "api.domain.com",
+							Type:  string(entities.EnvServedPortBindingTypeDomain),
+							Upstreams: []*proto.EnvServedPortBindingUpstream{
+								{
+									Port: "3001",
+								},
+							},
+						},
+					},
+				},
+
+				"3001": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "3001",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+						},
+					},
+					ProxyProtocol: "v2",
+				},
+			},
+			expectError:   true,
+			expectedField: "served_ports[3001].proxy_protocol",
+		},
+
+		{
+			test: "with PROXY protocol on port with TCP binding",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"5432": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value:    "15432",
+							Type:     string(entities.EnvServedPortBindingTypePort),
+							Protocol: string(env.ConfigServedPortProtocolTCP),
+						},
+					},
+					ProxyProtocol: "v2",
+				},
+			},
+		},
+
+		{
+			test: "with valid network conditions",
+			servedPorts: map[string]*proto.EnvServedPortBindings{
+				"3000": {
+					Bindings: []*proto.EnvServedPortBinding{
+						{
+							Value: "8000",
+							Type:  string(entities.EnvServedPortBindingTypePort),
+							NetworkConditions: &proto.EnvServedPortBindingNetworkConditions{
+								LatencyMs:               200,
+								JitterMs:                50,
+								BandwidthBytesPerSecond: 64 * 1024,
+								DropRate:                0.1,
+								ErrorRate:               0.05,
+								ErrorStatus:             503,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			err := validateServedPorts(tc.servedPorts)
+
+			if !tc.expectError && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if tc.expectError && status.Code(err) != codes.InvalidArgument {
+				t.Fatalf(
+					"expected error code to equal '%+v', got '%+v'",
+					codes.InvalidArgument,
+					status.Code(err),
+				)
+			}
+
+			if len(tc.expectedField) == 0 {
+				return
+			}
+
+			field := ""
+
+			for _, detail := range status.Convert(err).Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					field = badRequest.FieldViolations[0].Field
+				}
+			}
+
+			if field != tc.expectedField {
+				t.Fatalf(
+					"expected field to equal '%s', got '%s'",
+					tc.expectedField,
+					field,
+				)
+			}
+		})
+	}
+}
+
+func TestValidatePreviewDomain(t *testing.T) {
+	testCases := []struct {
+		test          string
+		previewDomain *proto.EnvPreviewDomain
+		expectError   bool
+	}{
+		{
+			test:          "with no preview domain",
+			previewDomain: nil,
+		},
+
+		{
+			test: "with valid preview domain",
+			previewDomain: &proto.EnvPreviewDomain{
+				Domain:       "myenv.dev.domain.com",
+				AllowedPorts: []string{"3000", "8080"},
+				DeniedPorts:  []string{"5432"},
+			},
+		},
+
+		{
+			test: "with wildcard preview domain",
+			previewDomain: &proto.EnvPreviewDomain{
+				Domain: "*.myenv.dev.domain.com",
+			},
+			expectError: true,
+		},
+
+		{
+			test: "with invalid port",
+			previewDomain: &proto.EnvPreviewDomain{
+				Domain:      "myenv.dev.domain.com",
+				DeniedPorts: []string{"70000"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			err := validatePreviewDomain(tc.previewDomain)
+
+			if !tc.expectError && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if tc.expectError && status.Code(err) != codes.InvalidArgument {
+				t.Fatalf(
+					"expected error code to equal '%+v', got '%+v'",
+					codes.InvalidArgument,
+					status.Code(err),
+				)
+			}
+		})
+	}
+}
+
+func TestIsValidServedPortKey(t *testing.T) {
+	testCases := []struct {
+		test          string
+		key           string
+		expectedValid bool
+	}{
+		{
+			test:          "with port",
+			key:           "8080",
+			expectedValid: true,
+		},
+
+		{
+			test:          "with name",
+			key:           "api-docs_v2",
+			expectedValid: true,
+		},
+
+		{
+			test:          "with port with leading zeros",
+			key:           "08080",
+			expectedValid: false,
+		},
+
+		{
+			test:          "with port with sign",
+			key:           "+8080",
+			expectedValid: false,
+		},
+
+		{
+			test:          "with relative path",
+			key:           "../8080",
+			expectedValid: false,
+		},
+
+		{
+			test:          "with rotated access logs file name",
+			key:           "8080-2022-09-26T17-16-39.371",
+			expectedValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			valid := isValidServedPortKey(tc.key)
+
+			if valid != tc.expectedValid {
+				t.Fatalf(
+					"expected valid to equal '%v', got '%v'",
+					tc.expectedValid,
+					valid,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package grpcserver
+
+import (
+	"github.com/eleven-sh/agent/internal/state"
+	"github.com/eleven-sh/agent/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// WatchListeningPorts sends an event each time a TCP port
+// is listened on or closed in the instance, starting
+// with the ports currently listened on.
+func (s *agentServer) WatchListeningPorts(
+	req *proto.WatchListeningPortsRequest,
+	stream proto.Agent_WatchListeningPortsServer,
+) error {
+
+	events, unsubscribe := state.SubscribeToListeningPorts(req.Ports)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, subscribed := <-events:
+			if !subscribed {
+				return status.Error(
+					codes.Aborted,
+					"events were sent faster than received, watch again to get the ports listened on",
+				)
+			}
+
+			err := stream.Send(&proto.WatchListeningPortsReply{
+				Type: string(event.Type),
+				Port: &proto.ListeningPort{
+					Addr:   event.Port.Addr,
+					Port:   event.Port.Port,
+					Pid:    int32(event.Port.PID),
+					Served: event.Port.Served,
+				},
+				TimestampMs: event.Timestamp.UnixNano() / 1e6,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package httpproxy
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/caddy"
+)
+
+// accessLogger writes the access logs of a served port
+// using the Caddy format so that they could be streamed
+// the same way, whatever the proxy used.
+type accessLogger struct {
+	port string
+	file *os.File
+	lock sync.Mutex
+}
+
+// newAccessLogger returns nil if the access logs
+// file could not be opened (requests are still served).
+func newAccessLogger(logsDirPath, port string) *accessLogger {
+	err := os.MkdirAll(logsDirPath, os.FileMode(0750))
+
+	if err != nil {
+		log.Printf("[HTTP proxy] Error when creating access logs dir: %v", err)
+		return nil
+	}
+
+	file, err := os.OpenFile(
+		caddy.GetAccessLogsFilePath(logsDirPath, port),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		os.FileMode(0640),
+	)
+
+	if err != nil {
+		log.Printf("[HTTP proxy] Error when opening access logs file: %v", err)
+		return nil
+	}
+
+	return &accessLogger{
+		port: port,
+		file: file,
+	}
+}
+
+func (a *accessLogger) wrap(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startedAt := time.Now()
+		recorder := &statusRecorder{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		next.ServeHTTP(recorder, r)
+
+		a.log(&caddy.AccessLogEntry{
+			Port:       a.port,
+			Timestamp:  startedAt,
+			RemoteAddr: r.RemoteAddr,
+			Proto:      r.Proto,
+			Method:     r.Method,
+			Host:       r.Host,
+			URI:        r.RequestURI,
+			Status:     recorder.status,
+			Size:       recorder.size,
+			Duration:   time.Since(startedAt),
+		})
+	})
+}
+
+func (a *accessLogger) log(entry *caddy.AccessLogEntry) {
+	line, err := caddy.FormatAccessLogLine(entry)
+
+	if err != nil {
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.file.Write(append(line, '\n'))
+}
+
+func (a *accessLogger) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.file.Close()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status        int
+	size          int64
+	headerWritten bool
+}
+
+func (s *statusRecorder) WriteHeader(statusCode int) {
+	if !s.headerWritten {
+		s.status = statusCode
+		s.headerWritten = true
+	}
+
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	s.headerWritten = true
+
+	n, err := s.ResponseWriter.Write(data)
+	s.size += int64(n)
+
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is used by the reverse proxy to upgrade the
+// connection (eg: WebSockets). The "101" response is
+// written directly to the hijacked connection.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, readWriter, err := hijacker.Hijack()
+
+	if err == nil && !s.headerWritten {
+		s.status = http.StatusSwitchingProtocols
+		s.headerWritten = true
+	}
+
+	return conn, readWriter, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,38 @@
+package httpproxy
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// withForwardedHeaders sets the "Forwarded" and "X-Forwarded-*"
+// headers like Caddy does so that upstreams could recover the
+// address of the clients. Headers sent by clients are ignored.
+func withForwardedHeaders(next http.Handler, isHTTPS bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme := "http"
+
+		if isHTTPS {
+			scheme = "https"
+		}
+
+		// Set by "httputil.ReverseProxy" from the client address
+		r.Header.Del("X-Forwarded-For")
+
+		r.Header.Set("X-Forwarded-Proto", scheme)
+		r.Header.Set("X-Forwarded-Host", r.Host)
+		r.Header.Set("Forwarded", buildForwardedHeaderValue(r, scheme))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Ref: https://www.rfc-editor.org/rfc/rfc7239
+func buildForwardedHeaderValue(r *http.Request, scheme string) string {
+	return fmt.Sprintf(
+		`for="%s";host="%s";proto=%s`,
+		r.RemoteAddr,
+		r.Host,
+		scheme,
+	)
+}
@@ -0,0 +1,244 @@
+package httpproxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eleven-sh/agent/internal/caddy"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// withHTTPOptions applies the HTTP options of a binding
+// around the passed handler. Encodings are not supported
+// and are ignored (responses are sent as returned by the upstream).
+func withHTTPOptions(
+	next http.Handler,
+	httpOptions *proto.EnvServedPortBindingHTTPOptions,
+	isHTTPS bool,
+) http.Handler {
+
+	if httpOptions == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		applyHeadersOps(r.Header, httpOptions.RequestHeaders)
+
+		responseHeadersOps := &headersOps{
+			add: http.Header{},
+			set: http.Header{},
+		}
+
+		if httpOptions.ResponseHeaders != nil {
+			for headerName, headerValue := range httpOptions.ResponseHeaders.Add {
+				responseHeadersOps.add.Add(headerName, headerValue)
+			}
+
+			responseHeadersOps.remove = httpOptions.ResponseHeaders.Remove
+		}
+
+		// HSTS headers are ignored by browsers
+		// when sent over plain HTTP
+		if isHTTPS && httpOptions.Hsts != nil {
+			responseHeadersOps.set.Set(
+				"Strict-Transport-Security",
+				caddy.BuildHSTSHeaderValue(httpOptions.Hsts),
+			)
+		}
+
+		cors := httpOptions.Cors
+
+		if cors != nil && isAllowedOrigin(cors, r.Header.Get("Origin")) {
+			for headerName, headerValues := range buildCORSHeaders(cors, r) {
+				responseHeadersOps.set[headerName] = headerValues
+			}
+
+			isPreflight := r.Method == http.MethodOptions &&
+				len(r.Header.Get("Access-Control-Request-Method")) > 0
+
+			// Preflight requests are not sent to the upstream
+			if isPreflight {
+				responseHeadersOps.applyTo(w.Header())
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		if responseHeadersOps.isEmpty() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&headersResponseWriter{
+			ResponseWriter: w,
+			headersOps:     responseHeadersOps,
+		}, r)
+	})
+}
+
+type headersOps struct {
+	add    http.Header
+	set    http.Header
+	remove []string
+}
+
+func (h *headersOps) isEmpty() bool {
+	return len(h.add) == 0 && len(h.set) == 0 && len(h.remove) == 0
+}
+
+func (h *headersOps) applyTo(headers http.Header) {
+	for headerName, headerValues := range h.add {
+		for _, headerValue := range headerValues {
+			headers.Add(headerName, headerValue)
+		}
+	}
+
+	for headerName, headerValues := range h.set {
+		headers.Del(headerName)
+
+		for _, headerValue := range headerValues {
+			headers.Add(headerName, headerValue)
+		}
+	}
+
+	for _, headerName := range h.remove {
+		headers.Del(headerName)
+	}
+}
+
+// headersResponseWriter applies the response headers
+// after the upstream has written its own otherwise they
+// could be duplicated or not removed
+type headersResponseWriter struct {
+	http.ResponseWriter
+	headersOps       *headersOps
+	headersWereFixed bool
+}
+
+func (h *headersResponseWriter) WriteHeader(statusCode int) {
+	h.fixHeaders()
+	h.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (h *headersResponseWriter) Write(data []byte) (int, error) {
+	h.fixHeaders()
+	return h.ResponseWriter.Write(data)
+}
+
+func (h *headersResponseWriter) Flush() {
+	h.fixHeaders()
+
+	if flusher, ok := h.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is used by the reverse proxy to upgrade the connection
+// (eg: WebSockets). The headers are applied before the upstream
+// ones are copied given that the "101" response is written
+// directly to the hijacked connection.
+func (h *headersResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := h.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	h.fixHeaders()
+
+	return hijacker.Hijack()
+}
+
+func (h *headersResponseWriter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+func (h *headersResponseWriter) fixHeaders() {
+	if h.headersWereFixed {
+		return
+	}
+
+	h.headersWereFixed = true
+	h.headersOps.applyTo(h.ResponseWriter.Header())
+}
+
+func applyHeadersOps(
+	headers http.Header,
+	headersOps *proto.EnvServedPortBindingHTTPHeaders,
+) {
+
+	if headersOps == nil {
+		return
+	}
+
+	for headerName, headerValue := range headersOps.Add {
+		headers.Add(headerName, headerValue)
+	}
+
+	for _, headerName := range headersOps.Remove {
+		headers.Del(headerName)
+	}
+}
+
+func isAllowedOrigin(cors *proto.EnvServedPortBindingCORS, origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+
+	if len(cors.AllowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowedOrigin := range cors.AllowedOrigins {
+		if allowedOrigin == "*" || allowedOrigin == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
+func buildCORSHeaders(
+	cors *proto.EnvServedPortBindingCORS,
+	r *http.Request,
+) http.Header {
+
+	allowedMethods := caddy.CORSDefaultAllowedMethods
+
+	if len(cors.AllowedMethods) > 0 {
+		allowedMethods = strings.Join(cors.AllowedMethods, ", ")
+	}
+
+	// Allow all headers requested during preflight by default
+	allowedHeaders := r.Header.Get("Access-Control-Request-Headers")
+
+	if len(cors.AllowedHeaders) > 0 {
+		allowedHeaders = strings.Join(cors.AllowedHeaders, ", ")
+	}
+
+	corsHeaders := http.Header{}
+
+	// The origin is echoed instead of using "*"
+	// given that "*" is not allowed with credentials
+	corsHeaders.Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+	corsHeaders.Set("Access-Control-Allow-Methods", allowedMethods)
+	corsHeaders.Set("Access-Control-Allow-Headers", allowedHeaders)
+	corsHeaders.Set("Vary", "Origin")
+
+	// See "caddy.HasExplicitCORSOrigins()"
+	if cors.AllowCredentials && caddy.HasExplicitCORSOrigins(cors) {
+		corsHeaders.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if cors.MaxAgeSeconds > 0 {
+		corsHeaders.Set(
+			"Access-Control-Max-Age",
+			strconv.FormatInt(cors.MaxAgeSeconds, 10),
+		)
+	}
+
+	return corsHeaders
+}
@@ -0,0 +1,215 @@
+package httpproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/caddy"
+	"github.com/eleven-sh/agent/internal/testutil"
+	"github.com/eleven-sh/agent/proto"
+)
+
+func TestWithHTTPOptions(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "upstream")
+		w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	httpOptions := &proto.EnvServedPortBindingHTTPOptions{
+		RequestHeaders: &proto.EnvServedPortBindingHTTPHeaders{
+			Add: map[string]string{
+				"X-Request-Id": "id",
+			},
+		},
+		ResponseHeaders: &proto.EnvServedPortBindingHTTPHeaders{
+			Remove: []string{"Server"},
+		},
+		Cors: &proto.EnvServedPortBindingCORS{
+			AllowedOrigins: []string{"https://app.domain.com"},
+			AllowedMethods: []string{"GET"},
+		},
+		Hsts: &proto.EnvServedPortBindingHSTS{},
+	}
+
+	testCases := []struct {
+		test            string
+		method          string
+		requestHeaders  map[string]string
+		isHTTPS         bool
+		expectedStatus  int
+		expectedHeaders http.Header
+	}{
+		{
+			test:           "with plain HTTP request",
+			method:         "GET",
+			expectedStatus: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Request-Id": {"id"},
+			},
+		},
+
+		{
+			test:           "with HTTPS request",
+			method:         "GET",
+			isHTTPS:        true,
+			expectedStatus: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Request-Id":              {"id"},
+				"Strict-Transport-Security": {"max-age=31536000"},
+			},
+		},
+
+		{
+			test:   "with preflight request",
+			method: "OPTIONS",
+			requestHeaders: map[string]string{
+				"Origin":                         "https://app.domain.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Authorization",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: http.Header{
+				"Access-Control-Allow-Origin":  {"https://app.domain.com"},
+				"Access-Control-Allow-Methods": {"GET"},
+				"Access-Control-Allow-Headers": {"Authorization"},
+				"Vary":                         {"Origin"},
+			},
+		},
+
+		{
+			test:   "with not allowed origin",
+			method: "GET",
+			requestHeaders: map[string]string{
+				"Origin": "https://www.domain.com",
+			},
+			expectedStatus: http.StatusOK,
+			expectedHeaders: http.Header{
+				"X-Request-Id": {"id"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			handler := withHTTPOptions(upstream, httpOptions, tc.isHTTPS)
+
+			request := httptest.NewRequest(tc.method, "/", nil)
+
+			for headerName, headerValue := range tc.requestHeaders {
+				request.Header.Set(headerName, headerValue)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Fatalf(
+					"expected status to equal '%d', got '%d'",
+					tc.expectedStatus,
+					recorder.Code,
+				)
+			}
+
+			if !reflect.DeepEqual(recorder.Header(), tc.expectedHeaders) {
+				t.Fatalf(
+					"expected headers to equal '%+v', got '%+v'",
+					tc.expectedHeaders,
+					recorder.Header(),
+				)
+			}
+		})
+	}
+}
+
+func TestHTTPOptionsAndAccessLogsWithUpgrade(t *testing.T) {
+	upstream := httptest.NewServer(testutil.NewUpgradeEchoHandler(t))
+	defer upstream.Close()
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+	logsDirPath := t.TempDir()
+
+	accessLogger := newAccessLogger(logsDirPath, upstreamURL.Port())
+	defer accessLogger.Close()
+
+	handler := accessLogger.wrap(
+		withHTTPOptions(
+			httputil.NewSingleHostReverseProxy(upstreamURL),
+			&proto.EnvServedPortBindingHTTPOptions{
+				ResponseHeaders: &proto.EnvServedPortBindingHTTPHeaders{
+					Add: map[string]string{
+						"X-Proxy": "eleven",
+					},
+				},
+			},
+			false,
+		),
+	)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+
+	connReader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(connReader, nil)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("X-Proxy") != "eleven" {
+
+		t.Fatalf("unexpected response '%+v'", resp)
+	}
+
+	fmt.Fprintf(conn, "ping\n")
+
+	echoedLine, err := connReader.ReadString('\n')
+
+	if err != nil || echoedLine != "ping\n" {
+		t.Fatalf("expected echoed line to equal 'ping', got '%s' (%v)", echoedLine, err)
+	}
+
+	conn.Close()
+
+	// Logged once the upgraded connection is closed
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		accessLogs, _ := os.ReadFile(
+			caddy.GetAccessLogsFilePath(logsDirPath, upstreamURL.Port()),
+		)
+
+		if strings.Contains(string(accessLogs), `"status":101`) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected upgrade to be logged with status 101, got '%s'", accessLogs)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
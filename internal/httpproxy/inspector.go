@@ -0,0 +1,70 @@
+package httpproxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/internal/netsim"
+	"github.com/eleven-sh/agent/proto"
+)
+
+var errDroppedConnection = errors.New("ErrDroppedConnection")
+
+// newInspectedPortHandler sends the requests to the agent
+// inspector server that simulates the passed network conditions
+// (if any) and records them (if the inspector is enabled)
+// before sending them to the upstream listening on the passed port.
+func newInspectedPortHandler(
+	inspectorServerAddr string,
+	port string,
+	networkConditions *proto.EnvServedPortBindingNetworkConditions,
+	onError http.Handler,
+) http.Handler {
+
+	inspectorProxy := httputil.NewSingleHostReverseProxy(&url.URL{
+		Scheme: "http",
+		Host:   inspectorServerAddr,
+	})
+
+	inspectorDirector := inspectorProxy.Director
+	inspectorProxy.Director = func(r *http.Request) {
+		inspectorDirector(r)
+		r.Header.Set(inspector.InspectedPortHeader, port)
+
+		// Only set by the agent
+		r.Header.Del(netsim.ConditionsHeader)
+
+		if networkConditions != nil {
+			r.Header.Set(
+				netsim.ConditionsHeader,
+				netsim.BuildHeaderValue(networkConditions),
+			)
+		}
+	}
+
+	// Streaming responses (eg: throttled ones)
+	inspectorProxy.FlushInterval = -1
+
+	inspectorProxy.ModifyResponse = func(resp *http.Response) error {
+		if resp.Header.Get(netsim.DroppedConnectionHeader) == "true" {
+			return errDroppedConnection
+		}
+
+		return nil
+	}
+
+	// The inspector server closes the connection
+	// when the upstream could not be reached
+	inspectorProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, errDroppedConnection) {
+			panic(http.ErrAbortHandler)
+		}
+
+		onError.ServeHTTP(w, r)
+	}
+
+	return inspectorProxy
+}
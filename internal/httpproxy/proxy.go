@@ -0,0 +1,339 @@
+package httpproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	httpListenAddr  = ":" + config.HTTPServerListenPort
+	httpsListenAddr = ":" + config.HTTPSServerListenPort
+
+	serverShutdownTimeout = 5 * time.Second
+)
+
+// Proxy is a pure Go implementation of "proxy.Proxy"
+// used on instances where Caddy is not installed.
+// TLS certificates are obtained using "autocert".
+type Proxy struct {
+	accessLogsDirPath   string
+	errorPageServerAddr string
+	inspectorServerAddr string
+	certManager         *autocert.Manager
+
+	routes       *routes
+	routesLock   sync.RWMutex
+	servers      map[string]*proxyServer
+	serveErrors  map[string]error
+	serversLock  sync.Mutex
+	appliedState *proxy.State
+	lock         sync.Mutex
+}
+
+type proxyServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+func NewProxy(
+	certsDirPath string,
+	accessLogsDirPath string,
+	errorPageServerAddr string,
+	inspectorServerAddr string,
+) *Proxy {
+
+	p := &Proxy{
+		accessLogsDirPath:   accessLogsDirPath,
+		errorPageServerAddr: errorPageServerAddr,
+		inspectorServerAddr: inspectorServerAddr,
+		routes:              newRoutes(),
+		servers:             map[string]*proxyServer{},
+		serveErrors:         map[string]error{},
+	}
+
+	p.certManager = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(certsDirPath),
+		HostPolicy: p.hostPolicy,
+	}
+
+	return p
+}
+
+func (p *Proxy) Apply(state *proxy.State) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	newRoutes := buildRoutes(
+		state,
+		p.accessLogsDirPath,
+		p.errorPageServerAddr,
+		p.inspectorServerAddr,
+	)
+
+	err := p.reconcileServers(newRoutes)
+
+	if err != nil {
+		newRoutes.close()
+		return err
+	}
+
+	p.routesLock.Lock()
+	oldRoutes := p.routes
+	p.routes = newRoutes
+	p.routesLock.Unlock()
+
+	oldRoutes.close()
+
+	p.appliedState = state.Clone()
+
+	return nil
+}
+
+func (p *Proxy) Current() (*proxy.State, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.appliedState == nil {
+		return nil, nil
+	}
+
+	return p.appliedState.Clone(), nil
+}
+
+// Health returns "proxy.ErrStateDrifted" if one of the
+// servers stopped so that it could be restarted on re-apply.
+func (p *Proxy) Health() error {
+	p.serversLock.Lock()
+	defer p.serversLock.Unlock()
+
+	if len(p.serveErrors) > 0 {
+		return proxy.ErrStateDrifted
+	}
+
+	return nil
+}
+
+// reconcileServers starts the servers required by the
+// passed routes and stops the ones that are not needed anymore.
+// Nothing is stopped if one of the servers could not be started.
+func (p *Proxy) reconcileServers(newRoutes *routes) error {
+	p.serversLock.Lock()
+	defer p.serversLock.Unlock()
+
+	listenAddrs := map[string]bool{}
+
+	if len(newRoutes.httpsHosts) > 0 || len(newRoutes.httpHosts) > 0 {
+		listenAddrs[httpListenAddr] = true
+		listenAddrs[httpsListenAddr] = true
+	}
+
+	for port := range newRoutes.ports {
+		listenAddrs[":"+port] = true
+	}
+
+	startedServers := map[string]*proxyServer{}
+
+	for listenAddr := range listenAddrs {
+		if _, serverExists := p.servers[listenAddr]; serverExists {
+			continue
+		}
+
+		server, err := p.startServer(listenAddr)
+
+		if err != nil {
+			for _, startedServer := range startedServers {
+				startedServer.server.Close()
+			}
+
+			return err
+		}
+
+		startedServers[listenAddr] = server
+	}
+
+	for listenAddr, server := range startedServers {
+		p.servers[listenAddr] = server
+		delete(p.serveErrors, listenAddr)
+	}
+
+	for listenAddr, server := range p.servers {
+		if listenAddrs[listenAddr] {
+			continue
+		}
+
+		go shutdownServer(server.server)
+
+		delete(p.servers, listenAddr)
+		delete(p.serveErrors, listenAddr)
+	}
+
+	return nil
+}
+
+func (p *Proxy) startServer(listenAddr string) (*proxyServer, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Addr: listenAddr,
+	}
+
+	switch listenAddr {
+	case httpListenAddr:
+		// Handles ACME "http-01" challenges
+		server.Handler = p.certManager.HTTPHandler(
+			http.HandlerFunc(p.serveHTTPDomains),
+		)
+	case httpsListenAddr:
+		server.Handler = http.HandlerFunc(p.serveHTTPSDomains)
+		server.TLSConfig = p.certManager.TLSConfig()
+	default:
+		_, port, _ := net.SplitHostPort(listenAddr)
+
+		server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p.servePort(port, w, r)
+		})
+	}
+
+	startedServer := &proxyServer{
+		server:   server,
+		listener: listener,
+	}
+
+	go func() {
+		var err error
+
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+
+		log.Printf(
+			"[HTTP proxy] Server listening at %s stopped: %v",
+			listenAddr,
+			err,
+		)
+
+		p.serversLock.Lock()
+		defer p.serversLock.Unlock()
+
+		// Already removed or replaced
+		if p.servers[listenAddr] != startedServer {
+			return
+		}
+
+		// Restarted when the state is re-applied
+		delete(p.servers, listenAddr)
+		p.serveErrors[listenAddr] = err
+	}()
+
+	return startedServer, nil
+}
+
+func (p *Proxy) serveHTTPDomains(w http.ResponseWriter, r *http.Request) {
+	currentRoutes := p.getRoutes()
+	host := getRequestHost(r)
+
+	if handler, hasHandler := currentRoutes.httpHosts[host]; hasHandler {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if _, hasHandler := currentRoutes.httpsHosts[host]; hasHandler {
+		http.Redirect(
+			w,
+			r,
+			"https://"+host+r.URL.RequestURI(),
+			http.StatusPermanentRedirect,
+		)
+
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func (p *Proxy) serveHTTPSDomains(w http.ResponseWriter, r *http.Request) {
+	currentRoutes := p.getRoutes()
+
+	if handler, hasHandler := currentRoutes.httpsHosts[getRequestHost(r)]; hasHandler {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func (p *Proxy) servePort(port string, w http.ResponseWriter, r *http.Request) {
+	currentRoutes := p.getRoutes()
+
+	if handler, hasHandler := currentRoutes.ports[port]; hasHandler {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// hostPolicy prevents "autocert" from requesting
+// certificates for hosts that are not served.
+func (p *Proxy) hostPolicy(ctx context.Context, host string) error {
+	if _, hasHandler := p.getRoutes().httpsHosts[strings.ToLower(host)]; !hasHandler {
+		return fmt.Errorf("host \"%s\" is not served", host)
+	}
+
+	return nil
+}
+
+func (p *Proxy) getRoutes() *routes {
+	p.routesLock.RLock()
+	defer p.routesLock.RUnlock()
+
+	return p.routes
+}
+
+// getRequestHost returns the lowercased request host
+// given that domains are matched case-insensitively
+func getRequestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+
+	if err != nil {
+		// No port in host
+		host = r.Host
+	}
+
+	return strings.ToLower(host)
+}
+
+func shutdownServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		serverShutdownTimeout,
+	)
+
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
+}
@@ -0,0 +1,125 @@
+package httpproxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestProxyRestartsStoppedServers(t *testing.T) {
+	freeListener, err := net.Listen("tcp", ":0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	_, port, _ := net.SplitHostPort(freeListener.Addr().String())
+	freeListener.Close()
+
+	p := NewProxy(t.TempDir(), t.TempDir(), "127.0.0.1:2020", "127.0.0.1:2021")
+
+	state := proxy.NewState()
+	state.ServedPorts["3000"] = &proto.EnvServedPortBindings{
+		Bindings: []*proto.EnvServedPortBinding{
+			{
+				Value: port,
+				Type:  string(entities.EnvServedPortBindingTypePort),
+			},
+		},
+	}
+
+	if err := p.Apply(state); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer p.Apply(proxy.NewState())
+
+	p.serversLock.Lock()
+	p.servers[":"+port].listener.Close()
+	p.serversLock.Unlock()
+
+	healthErr := p.Health()
+
+	for i := 0; i < 100 && healthErr == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		healthErr = p.Health()
+	}
+
+	if !errors.Is(healthErr, proxy.ErrStateDrifted) {
+		t.Fatalf(
+			"expected health error to equal '%+v', got '%+v'",
+			proxy.ErrStateDrifted,
+			healthErr,
+		)
+	}
+
+	err = proxy.Restore(p, func() (*proxy.State, error) {
+		return nil, errors.New("unexpected call")
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if healthErr := p.Health(); healthErr != nil {
+		t.Fatalf("expected no health error, got '%+v'", healthErr)
+	}
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	conn.Close()
+}
+
+func TestGetRequestHost(t *testing.T) {
+	testCases := []struct {
+		test         string
+		host         string
+		expectedHost string
+	}{
+		{
+			test:         "with lowercase host",
+			host:         "api.domain.com",
+			expectedHost: "api.domain.com",
+		},
+
+		{
+			test:         "with mixed case host",
+			host:         "API.Domain.com",
+			expectedHost: "api.domain.com",
+		},
+
+		{
+			test:         "with port",
+			host:         "API.domain.com:443",
+			expectedHost: "api.domain.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tc.host
+
+			host := getRequestHost(req)
+
+			if host != tc.expectedHost {
+				t.Fatalf(
+					"expected host to equal '%+v', got '%+v'",
+					tc.expectedHost,
+					host,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package httpproxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/internal/netsim"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+// routes maps the served hosts and ports to their handler
+type routes struct {
+	httpsHosts map[string]http.Handler
+	httpHosts  map[string]http.Handler
+	ports      map[string]http.Handler
+	// Access logs files
+	closers []io.Closer
+	// Stops the active health checks
+	cancel context.CancelFunc
+}
+
+func newRoutes() *routes {
+	return &routes{
+		httpsHosts: map[string]http.Handler{},
+		httpHosts:  map[string]http.Handler{},
+		ports:      map[string]http.Handler{},
+		closers:    []io.Closer{},
+		cancel:     func() {},
+	}
+}
+
+func (r *routes) close() {
+	r.cancel()
+
+	for _, closer := range r.closers {
+		closer.Close()
+	}
+}
+
+func buildRoutes(
+	state *proxy.State,
+	accessLogsDirPath string,
+	errorPageServerAddr string,
+	inspectorServerAddr string,
+) *routes {
+
+	newRoutes := newRoutes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	newRoutes.cancel = cancel
+
+	// Sorted to open the access logs
+	// files always in the same order
+	sortedPorts := []string{}
+	for port := range state.ServedPorts {
+		sortedPorts = append(sortedPorts, port)
+	}
+	sort.Strings(sortedPorts)
+
+	for _, port := range sortedPorts {
+		var logger *accessLogger
+
+		for _, binding := range state.ServedPorts[port].Bindings {
+			// Non-HTTP bindings are served by the agent
+			if env.GetServedPortBindingProtocol(binding) != env.ConfigServedPortProtocolHTTP {
+				continue
+			}
+
+			isDomainBinding := env.IsDomainServedPortBinding(binding)
+
+			// Port already bound by user application
+			if !isDomainBinding && binding.Value == port {
+				continue
+			}
+
+			if logger == nil {
+				logger = newAccessLogger(accessLogsDirPath, port)
+
+				if logger != nil {
+					newRoutes.closers = append(newRoutes.closers, logger)
+				}
+			}
+
+			// Like with Caddy, bindings with multiple
+			// upstreams or a Unix socket are not inspected
+			isInspected := inspector.IsEnabled(state.ServedPorts[port]) &&
+				len(binding.Upstreams) == 0 &&
+				len(binding.UnixSocket) == 0 &&
+				!isStaticBinding(binding)
+
+			var networkConditions *proto.EnvServedPortBindingNetworkConditions
+
+			if netsim.IsSupported(port, binding) {
+				networkConditions = netsim.GetConditions(binding)
+			}
+
+			var targetHandler http.Handler
+
+			if isInspected || networkConditions != nil {
+				targetHandler = newInspectedPortHandler(
+					inspectorServerAddr,
+					port,
+					networkConditions,
+					newUpstreamErrorHandler(
+						errorPageServerAddr,
+						port,
+						getErrorPageRefreshSeconds(binding),
+					),
+				)
+			} else {
+				targetHandler = buildTargetHandler(
+					ctx,
+					port,
+					binding,
+					errorPageServerAddr,
+				)
+			}
+
+			buildHandler := func(isHTTPS bool) http.Handler {
+				return logger.wrap(
+					withHTTPOptions(
+						withForwardedHeaders(targetHandler, isHTTPS),
+						binding.HttpOptions,
+						isHTTPS,
+					),
+				)
+			}
+
+			if !isDomainBinding {
+				newRoutes.ports[binding.Value] = buildHandler(false)
+				continue
+			}
+
+			// Request hosts are lowercased before lookup
+			host := strings.ToLower(binding.Value)
+
+			newRoutes.httpsHosts[host] = buildHandler(true)
+
+			if !binding.RedirectToHttps {
+				newRoutes.httpHosts[host] = buildHandler(false)
+			}
+		}
+	}
+
+	if state.PreviewDomain != nil {
+		for _, port := range state.PreviewPorts {
+			host := strings.ToLower(
+				proxy.BuildPreviewDomainHost(state.PreviewDomain.Domain, port),
+			)
+
+			newRoutes.httpsHosts[host] = withForwardedHeaders(
+				newUpstreamsHandler(
+					ctx,
+					[]*proto.EnvServedPortBindingUpstream{
+						{
+							Port: port,
+						},
+					},
+					nil,
+					nil,
+				),
+				true,
+			)
+		}
+	}
+
+	check := state.DomainReachabilityCheck
+
+	// Bound domains take precedence
+	// like with the Caddy proxy
+	if check != nil {
+		checkHost := strings.ToLower(check.Domain)
+
+		if _, hasHandler := newRoutes.httpHosts[checkHost]; !hasHandler {
+			newRoutes.httpHosts[checkHost] = http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					io.WriteString(w, check.UniqueID)
+				},
+			)
+		}
+	}
+
+	return newRoutes
+}
+
+func buildTargetHandler(
+	ctx context.Context,
+	port string,
+	binding *proto.EnvServedPortBinding,
+	errorPageServerAddr string,
+) http.Handler {
+
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case env.EnvServedPortBindingTypeDirectory:
+		directory := binding.Directory
+
+		if directory == nil {
+			directory = &proto.EnvServedPortBindingDirectory{}
+		}
+
+		// Paths are validated before the state is applied
+		rootPath, _ := env.ResolveWorkspacePath(directory.Path)
+
+		return newDirectoryHandler(rootPath, directory.SpaFallback)
+	case env.EnvServedPortBindingTypeRedirect:
+		redirect := binding.Redirect
+
+		if redirect == nil {
+			redirect = &proto.EnvServedPortBindingRedirect{}
+		}
+
+		return newRedirectHandler(redirect)
+	}
+
+	if socketPath := env.GetServedPortBindingUnixSocketPath(binding); len(socketPath) > 0 {
+		return newUnixSocketHandler(
+			socketPath,
+			newUpstreamErrorHandler(
+				errorPageServerAddr,
+				port,
+				getErrorPageRefreshSeconds(binding),
+			),
+		)
+	}
+
+	upstreams := binding.Upstreams
+	healthCheck := binding.HealthCheck
+
+	if len(upstreams) == 0 ||
+		binding.Type != string(entities.EnvServedPortBindingTypeDomain) {
+
+		upstreams = []*proto.EnvServedPortBindingUpstream{
+			{
+				Port: port,
+			},
+		}
+
+		healthCheck = nil
+	}
+
+	return newUpstreamsHandler(
+		ctx,
+		upstreams,
+		healthCheck,
+		newUpstreamErrorHandler(
+			errorPageServerAddr,
+			port,
+			getErrorPageRefreshSeconds(binding),
+		),
+	)
+}
+
+func getErrorPageRefreshSeconds(binding *proto.EnvServedPortBinding) int32 {
+	if binding.HttpOptions == nil {
+		return 0
+	}
+
+	return binding.HttpOptions.ErrorPageRefreshSeconds
+}
+
+func isStaticBinding(binding *proto.EnvServedPortBinding) bool {
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case env.EnvServedPortBindingTypeDirectory,
+		env.EnvServedPortBindingTypeRedirect:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,215 @@
+package httpproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestBuildRoutes(t *testing.T) {
+	state := proxy.NewState()
+
+	state.ServedPorts["3000"] = &proto.EnvServedPortBindings{
+		Bindings: []*proto.EnvServedPortBinding{
+			{
+				Value: "3000",
+				Type:  string(entities.EnvServedPortBindingTypePort),
+			},
+
+			{
+				Value: "8000",
+				Type:  string(entities.EnvServedPortBindingTypePort),
+			},
+
+			{
+				Value:           "api.domain.com",
+				Type:            string(entities.EnvServedPortBindingTypeDomain),
+				RedirectToHttps: true,
+			},
+
+			{
+				Value: "WWW.domain.com",
+				Type:  string(entities.EnvServedPortBindingTypeDomain),
+			},
+		},
+	}
+
+	state.PreviewDomain = &env.ConfigPreviewDomain{
+		Domain: "myenv.domain.com",
+	}
+	state.PreviewPorts = []string{"4000"}
+
+	state.DomainReachabilityCheck = &proxy.DomainReachabilityCheck{
+		Domain:   "check.domain.com",
+		UniqueID: "unique-id",
+	}
+
+	routes := buildRoutes(state, t.TempDir(), "127.0.0.1:2020", "127.0.0.1:2021")
+	defer routes.close()
+
+	testCases := []struct {
+		test          string
+		handlers      map[string]http.Handler
+		expectedHosts []string
+	}{
+		{
+			test:     "HTTPS hosts",
+			handlers: routes.httpsHosts,
+			expectedHosts: []string{
+				"4000.myenv.domain.com",
+				"api.domain.com",
+				"www.domain.com",
+			},
+		},
+
+		{
+			test:     "HTTP hosts",
+			handlers: routes.httpHosts,
+			expectedHosts: []string{
+				"check.domain.com",
+				"www.domain.com",
+			},
+		},
+
+		{
+			test:          "ports",
+			handlers:      routes.ports,
+			expectedHosts: []string{"8000"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			hosts := []string{}
+
+			for host := range tc.handlers {
+				hosts = append(hosts, host)
+			}
+
+			sort.Strings(hosts)
+
+			if !reflect.DeepEqual(hosts, tc.expectedHosts) {
+				t.Fatalf(
+					"expected hosts to equal '%+v', got '%+v'",
+					tc.expectedHosts,
+					hosts,
+				)
+			}
+		})
+	}
+}
+
+func TestDirectoryHandler(t *testing.T) {
+	rootPath := t.TempDir()
+
+	os.WriteFile(filepath.Join(rootPath, "index.html"), []byte("index"), 0644)
+	os.WriteFile(filepath.Join(rootPath, "app.js"), []byte("app"), 0644)
+	os.Mkdir(filepath.Join(rootPath, "assets"), 0755)
+
+	outsidePath := t.TempDir()
+
+	os.WriteFile(filepath.Join(outsidePath, "secret"), []byte("secret"), 0644)
+	os.Symlink(filepath.Join(outsidePath, "secret"), filepath.Join(rootPath, "secret"))
+	os.Symlink(outsidePath, filepath.Join(rootPath, "outside"))
+	os.Symlink(filepath.Join(rootPath, "app.js"), filepath.Join(rootPath, "main.js"))
+
+	testCases := []struct {
+		test           string
+		spaFallback    bool
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			test:           "with existing file",
+			path:           "/app.js",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "app",
+		},
+
+		{
+			test:           "with unknown path",
+			path:           "/users/1",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+
+		{
+			test:           "with directory without index",
+			path:           "/assets/",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+
+		{
+			test:           "with symbolic link inside root",
+			path:           "/main.js",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "app",
+		},
+
+		{
+			test:           "with symbolic link to file outside root",
+			path:           "/secret",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+
+		{
+			test:           "with symbolic link to directory outside root",
+			path:           "/outside/secret",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+
+		{
+			test:           "with symbolic link outside root and SPA fallback",
+			spaFallback:    true,
+			path:           "/secret",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "index",
+		},
+
+		{
+			test:           "with unknown path and SPA fallback",
+			spaFallback:    true,
+			path:           "/users/1",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "index",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			handler := newDirectoryHandler(rootPath, tc.spaFallback)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", tc.path, nil))
+
+			if recorder.Code != tc.expectedStatus {
+				t.Fatalf(
+					"expected status to equal '%d', got '%d'",
+					tc.expectedStatus,
+					recorder.Code,
+				)
+			}
+
+			if recorder.Body.String() != tc.expectedBody {
+				t.Fatalf(
+					"expected body to equal '%s', got '%s'",
+					tc.expectedBody,
+					recorder.Body.String(),
+				)
+			}
+		})
+	}
+}
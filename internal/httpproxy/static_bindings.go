@@ -0,0 +1,133 @@
+package httpproxy
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+const directoryIndexFile = "index.html"
+
+// newDirectoryHandler serves the files located in the passed
+// root directory. When "spaFallback" is set, unknown paths
+// are served using the "index.html" file of the root directory.
+func newDirectoryHandler(rootPath string, spaFallback bool) http.Handler {
+	fileSystem := noListingFileSystem{
+		fileSystem: confinedFileSystem{
+			rootPath: rootPath,
+		},
+	}
+
+	fileServer := http.FileServer(fileSystem)
+
+	if !spaFallback {
+		return fileServer
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := fileSystem.Open(path.Clean("/" + r.URL.Path))
+
+		if err == nil {
+			file.Close()
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		fallbackRequest := r.Clone(r.Context())
+		// Requesting "/index.html" directly
+		// would redirect to "/"
+		fallbackRequest.URL.Path = "/"
+		fallbackRequest.URL.RawPath = ""
+
+		fileServer.ServeHTTP(w, fallbackRequest)
+	})
+}
+
+// confinedFileSystem serves the files of the root directory
+// like "http.Dir" but without following the symbolic links
+// that lead outside of it. Required given that the proxy runs
+// as root and that the served files are owned by the user.
+type confinedFileSystem struct {
+	rootPath string
+}
+
+func (c confinedFileSystem) Open(name string) (http.File, error) {
+	// The root could be a symbolic link too
+	resolvedRootPath, err := filepath.EvalSymlinks(c.rootPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(
+		filepath.Join(resolvedRootPath, filepath.FromSlash(path.Clean("/"+name))),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resolvedPath != resolvedRootPath &&
+		!strings.HasPrefix(resolvedPath, resolvedRootPath+string(filepath.Separator)) {
+
+		return nil, os.ErrNotExist
+	}
+
+	// The resolved path is opened so that the
+	// file could not be swapped by a link once checked
+	return os.Open(resolvedPath)
+}
+
+// noListingFileSystem prevents directories
+// without index file from being listed
+// (like with the Caddy "file_server" handler)
+type noListingFileSystem struct {
+	fileSystem http.FileSystem
+}
+
+func (n noListingFileSystem) Open(name string) (http.File, error) {
+	file, err := n.fileSystem.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if !fileInfo.IsDir() {
+		return file, nil
+	}
+
+	index, err := n.fileSystem.Open(path.Join(name, directoryIndexFile))
+
+	if err != nil {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+
+	index.Close()
+
+	return file, nil
+}
+
+func newRedirectHandler(redirect *proto.EnvServedPortBindingRedirect) http.Handler {
+	statusCode := http.StatusFound
+
+	if redirect.Permanent {
+		statusCode = http.StatusMovedPermanently
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", redirect.Url)
+		w.WriteHeader(statusCode)
+	})
+}
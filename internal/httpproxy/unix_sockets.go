@@ -0,0 +1,45 @@
+package httpproxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/eleven-sh/agent/internal/env"
+)
+
+// newUnixSocketHandler proxies the requests
+// to the passed Unix socket path. The socket is
+// resolved on each dial (see "env.ResolveWorkspaceUnixSocket").
+func newUnixSocketHandler(
+	socketPath string,
+	onError http.Handler,
+) http.Handler {
+
+	return &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "http"
+			// Only used to build the request line.
+			// The "Host" header is left unchanged.
+			r.URL.Host = "localhost"
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				resolvedSocketPath, err := env.ResolveWorkspaceUnixSocket(socketPath)
+
+				if err != nil {
+					return nil, err
+				}
+
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", resolvedSocketPath)
+			},
+		},
+		// Streaming responses (eg: SSE)
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			onError.ServeHTTP(w, r)
+		},
+	}
+}
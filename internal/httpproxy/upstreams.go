@@ -0,0 +1,345 @@
+package httpproxy
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/errorpage"
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	// Mirrors the Caddy proxy behavior
+	upstreamsPassiveFailDuration = 10 * time.Second
+
+	healthCheckDefaultInterval = 5 * time.Second
+	healthCheckDefaultTimeout  = 2 * time.Second
+)
+
+type upstreamsSelectionPolicy int
+
+const (
+	upstreamsFirstPolicy upstreamsSelectionPolicy = iota
+	upstreamsRoundRobinPolicy
+	upstreamsWeightedPolicy
+)
+
+type upstream struct {
+	port   string
+	weight int32
+	proxy  *httputil.ReverseProxy
+
+	lock          sync.Mutex
+	failedUntil   time.Time
+	activeHealthy bool
+}
+
+func (u *upstream) isAvailable() bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	return u.activeHealthy && time.Now().After(u.failedUntil)
+}
+
+func (u *upstream) markAsFailed() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.failedUntil = time.Now().Add(upstreamsPassiveFailDuration)
+}
+
+func (u *upstream) setActiveHealthy(healthy bool) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.activeHealthy = healthy
+}
+
+// upstreamsHandler load balances requests between upstreams.
+// Like with Caddy, active upstreams are tried before standby
+// ones and failed upstreams are skipped for a while.
+type upstreamsHandler struct {
+	upstreams []*upstream
+	policy    upstreamsSelectionPolicy
+	// Only when there are many upstreams or health checks
+	passiveHealthChecks bool
+	onError             http.Handler
+
+	lock            sync.Mutex
+	roundRobinIndex int
+}
+
+type upstreamErrorKey struct{}
+
+func newUpstreamsHandler(
+	ctx context.Context,
+	bindingUpstreams []*proto.EnvServedPortBindingUpstream,
+	healthCheck *proto.EnvServedPortBindingHealthCheck,
+	onError http.Handler,
+) *upstreamsHandler {
+
+	handler := &upstreamsHandler{
+		upstreams:           []*upstream{},
+		policy:              upstreamsRoundRobinPolicy,
+		passiveHealthChecks: len(bindingUpstreams) > 1 || healthCheck != nil,
+		onError:             onError,
+	}
+
+	hasStandby := false
+	hasWeights := false
+
+	// Actives first then standbys
+	for _, standby := range []bool{false, true} {
+		for _, bindingUpstream := range bindingUpstreams {
+			if bindingUpstream.Standby != standby {
+				continue
+			}
+
+			hasStandby = hasStandby || standby
+			hasWeights = hasWeights || bindingUpstream.Weight > 0
+
+			handler.upstreams = append(
+				handler.upstreams,
+				newUpstream(bindingUpstream),
+			)
+		}
+	}
+
+	if hasStandby {
+		handler.policy = upstreamsFirstPolicy
+	} else if hasWeights {
+		handler.policy = upstreamsWeightedPolicy
+	}
+
+	if healthCheck != nil && len(healthCheck.Path) > 0 {
+		for _, upstream := range handler.upstreams {
+			go runActiveHealthChecks(ctx, upstream, healthCheck)
+		}
+	}
+
+	return handler
+}
+
+func newUpstream(bindingUpstream *proto.EnvServedPortBindingUpstream) *upstream {
+	weight := bindingUpstream.Weight
+
+	if weight <= 0 {
+		weight = 1
+	}
+
+	upstream := &upstream{
+		port:          bindingUpstream.Port,
+		weight:        weight,
+		activeHealthy: true,
+	}
+
+	upstream.proxy = httputil.NewSingleHostReverseProxy(&url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort("127.0.0.1", bindingUpstream.Port),
+	})
+
+	// Errors are handled by the upstreams handler
+	// to let it try the next upstream
+	upstream.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if upstreamErr, ok := r.Context().Value(upstreamErrorKey{}).(*error); ok {
+			*upstreamErr = err
+		}
+	}
+
+	return upstream
+}
+
+func (u *upstreamsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var upstreamErr error
+	ctx := context.WithValue(r.Context(), upstreamErrorKey{}, &upstreamErr)
+
+	for _, upstream := range u.selectUpstreams() {
+		upstreamErr = nil
+		upstream.proxy.ServeHTTP(w, r.WithContext(ctx))
+
+		if upstreamErr == nil {
+			return
+		}
+
+		if u.passiveHealthChecks {
+			upstream.markAsFailed()
+		}
+
+		// Request canceled by client
+		if r.Context().Err() != nil {
+			return
+		}
+	}
+
+	if u.onError == nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	u.onError.ServeHTTP(w, r)
+}
+
+// selectUpstreams returns the available
+// upstreams in the order they need to be tried
+func (u *upstreamsHandler) selectUpstreams() []*upstream {
+	if !u.passiveHealthChecks {
+		return u.upstreams
+	}
+
+	availableUpstreams := []*upstream{}
+
+	for _, upstream := range u.upstreams {
+		if upstream.isAvailable() {
+			availableUpstreams = append(availableUpstreams, upstream)
+		}
+	}
+
+	if len(availableUpstreams) <= 1 || u.policy == upstreamsFirstPolicy {
+		return availableUpstreams
+	}
+
+	firstUpstreamIndex := 0
+
+	if u.policy == upstreamsWeightedPolicy {
+		firstUpstreamIndex = selectWeightedUpstream(availableUpstreams)
+	} else {
+		u.lock.Lock()
+		firstUpstreamIndex = u.roundRobinIndex % len(availableUpstreams)
+		u.roundRobinIndex++
+		u.lock.Unlock()
+	}
+
+	return append(
+		availableUpstreams[firstUpstreamIndex:],
+		availableUpstreams[:firstUpstreamIndex]...,
+	)
+}
+
+func selectWeightedUpstream(upstreams []*upstream) int {
+	totalWeight := int32(0)
+
+	for _, upstream := range upstreams {
+		totalWeight += upstream.weight
+	}
+
+	randomWeight := rand.Int31n(totalWeight)
+
+	for upstreamIndex, upstream := range upstreams {
+		randomWeight -= upstream.weight
+
+		if randomWeight < 0 {
+			return upstreamIndex
+		}
+	}
+
+	return 0
+}
+
+func runActiveHealthChecks(
+	ctx context.Context,
+	upstream *upstream,
+	healthCheck *proto.EnvServedPortBindingHealthCheck,
+) {
+
+	interval := healthCheckDefaultInterval
+
+	if healthCheck.IntervalSeconds > 0 {
+		interval = time.Duration(healthCheck.IntervalSeconds) * time.Second
+	}
+
+	timeout := healthCheckDefaultTimeout
+
+	if healthCheck.TimeoutSeconds > 0 {
+		timeout = time.Duration(healthCheck.TimeoutSeconds) * time.Second
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+	}
+
+	healthCheckURL := "http://" +
+		net.JoinHostPort("127.0.0.1", upstream.port) +
+		healthCheck.Path
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		upstream.setActiveHealthy(
+			isUpstreamHealthy(ctx, client, healthCheckURL, healthCheck.ExpectedStatus),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func isUpstreamHealthy(
+	ctx context.Context,
+	client *http.Client,
+	healthCheckURL string,
+	expectedStatus int32,
+) bool {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthCheckURL, nil)
+
+	if err != nil {
+		return false
+	}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return false
+	}
+
+	defer resp.Body.Close()
+
+	if expectedStatus > 0 {
+		return resp.StatusCode == int(expectedStatus)
+	}
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+// newUpstreamErrorHandler serves the page returned by
+// the error page server when all the upstreams are down.
+func newUpstreamErrorHandler(
+	errorPageServerAddr string,
+	port string,
+	refreshSeconds int32,
+) http.Handler {
+
+	errorPageURL := "http://" + errorPageServerAddr +
+		errorpage.BuildUpstreamErrorPageURI(port, refreshSeconds)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := http.Get(errorPageURL)
+
+		if err != nil {
+			log.Printf("[HTTP proxy] Error when getting error page: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		defer resp.Body.Close()
+
+		for headerName, headerValues := range resp.Header {
+			w.Header()[headerName] = headerValues
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+		io.Copy(w, resp.Body)
+	})
+}
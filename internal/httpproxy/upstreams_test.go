@@ -0,0 +1,140 @@
+package httpproxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+func startTestUpstream(t *testing.T, body string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	serverURL, _ := url.Parse(server.URL)
+
+	return serverURL.Port()
+}
+
+func getClosedPort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	return port
+}
+
+func TestUpstreamsHandler(t *testing.T) {
+	bluePort := startTestUpstream(t, "blue")
+	greenPort := startTestUpstream(t, "green")
+	closedPort := getClosedPort(t)
+
+	testCases := []struct {
+		test           string
+		upstreams      []*proto.EnvServedPortBindingUpstream
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			test: "with single upstream",
+			upstreams: []*proto.EnvServedPortBindingUpstream{
+				{
+					Port: bluePort,
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "blue",
+		},
+
+		{
+			test: "with standby upstream",
+			upstreams: []*proto.EnvServedPortBindingUpstream{
+				{
+					Port:    bluePort,
+					Standby: true,
+				},
+
+				{
+					Port: greenPort,
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "green",
+		},
+
+		{
+			test: "with down active upstream",
+			upstreams: []*proto.EnvServedPortBindingUpstream{
+				{
+					Port: closedPort,
+				},
+
+				{
+					Port:    greenPort,
+					Standby: true,
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "green",
+		},
+
+		{
+			test: "with all upstreams down",
+			upstreams: []*proto.EnvServedPortBindingUpstream{
+				{
+					Port: closedPort,
+				},
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "error page",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			onError := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				io.WriteString(w, "error page")
+			})
+
+			handler := newUpstreamsHandler(
+				context.Background(),
+				tc.upstreams,
+				nil,
+				onError,
+			)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+			if recorder.Code != tc.expectedStatus {
+				t.Fatalf(
+					"expected status to equal '%d', got '%d'",
+					tc.expectedStatus,
+					recorder.Code,
+				)
+			}
+
+			if recorder.Body.String() != tc.expectedBody {
+				t.Fatalf(
+					"expected body to equal '%s', got '%s'",
+					tc.expectedBody,
+					recorder.Body.String(),
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package inspector
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+const (
+	defaultMaxBodyBytes = 64 * 1024 // 64KB
+	defaultMaxRecords   = 100
+
+	// Prevents slow subscribers from
+	// blocking the inspected requests
+	subscriberBufferSize = 64
+)
+
+// Record represents a request/response pair
+// captured for an inspected served port.
+type Record struct {
+	ID                    uint64
+	Port                  string
+	Timestamp             time.Time
+	Duration              time.Duration
+	RemoteAddr            string
+	Proto                 string
+	Method                string
+	Host                  string
+	URI                   string
+	RequestHeaders        http.Header
+	RequestBody           []byte
+	RequestBodyTruncated  bool
+	Status                int
+	ResponseHeaders       http.Header
+	ResponseBody          []byte
+	ResponseBodyTruncated bool
+	// Set when the upstream could not be reached
+	Error string
+}
+
+// Inspector records the requests sent to the served ports
+// that have their inspector enabled in ring buffers.
+type Inspector struct {
+	lock         sync.Mutex
+	ports        map[string]*inspectedPort
+	lastRecordID uint64
+	subscribers  map[*subscriber]bool
+}
+
+type inspectedPort struct {
+	maxBodyBytes int64
+	maxRecords   int
+	records      []*Record
+}
+
+type subscriber struct {
+	ports   map[string]bool
+	records chan *Record
+}
+
+func New() *Inspector {
+	return &Inspector{
+		ports:       map[string]*inspectedPort{},
+		subscribers: map[*subscriber]bool{},
+	}
+}
+
+// Configure enables the inspector for the passed served ports
+// and disables it (dropping the records) for the other ones.
+func (i *Inspector) Configure(servedPorts map[string]*proto.EnvServedPortBindings) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	ports := map[string]*inspectedPort{}
+
+	for port, portBindings := range servedPorts {
+		if !IsEnabled(portBindings) {
+			continue
+		}
+
+		maxBodyBytes := portBindings.Inspector.MaxBodyBytes
+
+		if maxBodyBytes <= 0 {
+			maxBodyBytes = defaultMaxBodyBytes
+		}
+
+		maxRecords := int(portBindings.Inspector.MaxRecords)
+
+		if maxRecords <= 0 {
+			maxRecords = defaultMaxRecords
+		}
+
+		records := []*Record{}
+
+		if currentPort, hasPort := i.ports[port]; hasPort {
+			records = currentPort.records
+		}
+
+		if len(records) > maxRecords {
+			records = records[len(records)-maxRecords:]
+		}
+
+		ports[port] = &inspectedPort{
+			maxBodyBytes: maxBodyBytes,
+			maxRecords:   maxRecords,
+			records:      records,
+		}
+	}
+
+	i.ports = ports
+}
+
+// IsEnabled returns true if the inspector
+// is enabled for the passed served port.
+func IsEnabled(portBindings *proto.EnvServedPortBindings) bool {
+	return portBindings != nil &&
+		portBindings.Inspector != nil &&
+		portBindings.Inspector.Enabled
+}
+
+// Records returns the records of the passed
+// ports (or of all ports if none are passed)
+// ordered from the oldest to the newest.
+func (i *Inspector) Records(ports []string) []*Record {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	portsSet := buildPortsSet(ports)
+	records := []*Record{}
+
+	for port, inspectedPort := range i.ports {
+		if len(portsSet) > 0 && !portsSet[port] {
+			continue
+		}
+
+		records = append(records, inspectedPort.records...)
+	}
+
+	sortRecords(records)
+
+	return records
+}
+
+// Record returns the record with the passed ID
+// or nil if it was not found (or was dropped).
+func (i *Inspector) Record(ID uint64) *Record {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, inspectedPort := range i.ports {
+		for _, record := range inspectedPort.records {
+			if record.ID == ID {
+				return record
+			}
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel that receives the new records
+// of the passed ports (or of all ports if none are passed).
+// The returned function must be called to unsubscribe.
+func (i *Inspector) Subscribe(ports []string) (<-chan *Record, func()) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	sub := &subscriber{
+		ports:   buildPortsSet(ports),
+		records: make(chan *Record, subscriberBufferSize),
+	}
+
+	i.subscribers[sub] = true
+
+	return sub.records, func() {
+		i.lock.Lock()
+		defer i.lock.Unlock()
+
+		delete(i.subscribers, sub)
+	}
+}
+
+// getMaxBodyBytes returns false if the
+// passed port is not inspected.
+func (i *Inspector) getMaxBodyBytes(port string) (int64, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	inspectedPort, hasPort := i.ports[port]
+
+	if !hasPort {
+		return 0, false
+	}
+
+	return inspectedPort.maxBodyBytes, true
+}
+
+func (i *Inspector) addRecord(record *Record) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	inspectedPort, hasPort := i.ports[record.Port]
+
+	// Inspector disabled while
+	// the request was processed
+	if !hasPort {
+		return
+	}
+
+	i.lastRecordID++
+	record.ID = i.lastRecordID
+
+	inspectedPort.records = append(inspectedPort.records, record)
+
+	if len(inspectedPort.records) > inspectedPort.maxRecords {
+		inspectedPort.records = inspectedPort.records[1:]
+	}
+
+	for sub := range i.subscribers {
+		if len(sub.ports) > 0 && !sub.ports[record.Port] {
+			continue
+		}
+
+		select {
+		case sub.records <- record:
+		default: // Subscriber too slow. Record dropped.
+		}
+	}
+}
+
+func buildPortsSet(ports []string) map[string]bool {
+	portsSet := map[string]bool{}
+
+	for _, port := range ports {
+		portsSet[port] = true
+	}
+
+	return portsSet
+}
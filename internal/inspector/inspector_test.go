@@ -0,0 +1,87 @@
+package inspector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+func TestInspectorRecords(t *testing.T) {
+	inspector := New()
+	inspector.Configure(map[string]*proto.EnvServedPortBindings{
+		"3000": {
+			Inspector: &proto.EnvServedPortInspector{
+				Enabled:    true,
+				MaxRecords: 2,
+			},
+		},
+
+		"4000": {
+			Inspector: &proto.EnvServedPortInspector{
+				Enabled: true,
+			},
+		},
+
+		"5000": {},
+	})
+
+	records, unsubscribe := inspector.Subscribe([]string{"3000"})
+	defer unsubscribe()
+
+	for _, port := range []string{"3000", "4000", "3000", "3000", "5000"} {
+		inspector.addRecord(&Record{
+			Port: port,
+		})
+	}
+
+	testCases := []struct {
+		test        string
+		ports       []string
+		expectedIDs []uint64
+	}{
+		{
+			test:        "with one port",
+			ports:       []string{"3000"},
+			expectedIDs: []uint64{3, 4},
+		},
+
+		{
+			test:        "with all ports",
+			ports:       nil,
+			expectedIDs: []uint64{2, 3, 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			IDs := []uint64{}
+
+			for _, record := range inspector.Records(tc.ports) {
+				IDs = append(IDs, record.ID)
+			}
+
+			if !reflect.DeepEqual(IDs, tc.expectedIDs) {
+				t.Fatalf(
+					"expected IDs to equal '%+v', got '%+v'",
+					tc.expectedIDs,
+					IDs,
+				)
+			}
+		})
+	}
+
+	subscribedIDs := []uint64{}
+
+	for len(records) > 0 {
+		subscribedIDs = append(subscribedIDs, (<-records).ID)
+	}
+
+	if !reflect.DeepEqual(subscribedIDs, []uint64{1, 3, 4}) {
+		t.Fatalf(
+			"expected subscribed IDs to equal '%+v', got '%+v'",
+			[]uint64{1, 3, 4},
+			subscribedIDs,
+		)
+	}
+}
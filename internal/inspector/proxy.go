@@ -0,0 +1,31 @@
+package inspector
+
+import (
+	"github.com/eleven-sh/agent/internal/proxy"
+)
+
+// Proxy configures the inspector each time
+// a state is applied to the wrapped proxy.
+type Proxy struct {
+	proxy.Proxy
+	inspector *Inspector
+}
+
+func NewProxy(p proxy.Proxy, inspector *Inspector) *Proxy {
+	return &Proxy{
+		Proxy:     p,
+		inspector: inspector,
+	}
+}
+
+func (p *Proxy) Apply(state *proxy.State) error {
+	err := p.Proxy.Apply(state)
+
+	if err != nil {
+		return err
+	}
+
+	p.inspector.Configure(state.ServedPorts)
+
+	return nil
+}
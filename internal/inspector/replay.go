@@ -0,0 +1,100 @@
+package inspector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const replayTimeout = 30 * time.Second
+
+var ErrTruncatedRequestBody = errors.New("ErrTruncatedRequestBody")
+
+// Replay sends the request of the passed record to the local
+// upstream of its port (bypassing the proxy) and returns
+// a new record containing the response. The returned record
+// is not added to the records of the inspector.
+func (i *Inspector) Replay(ctx context.Context, record *Record) (*Record, error) {
+	if record.RequestBodyTruncated {
+		return nil, ErrTruncatedRequestBody
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		record.Method,
+		"http://"+net.JoinHostPort("127.0.0.1", record.Port)+record.URI,
+		bytes.NewReader(record.RequestBody),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = record.RequestHeaders.Clone()
+	req.Host = record.Host
+
+	// Set by Go given that the body is
+	// not the one of the original request
+	req.Header.Del("Content-Length")
+	req.Header.Del("Transfer-Encoding")
+
+	maxBodyBytes, isInspected := i.getMaxBodyBytes(record.Port)
+
+	if !isInspected {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	replayRecord := &Record{
+		Port:           record.Port,
+		Timestamp:      time.Now(),
+		Proto:          req.Proto,
+		Method:         record.Method,
+		Host:           record.Host,
+		URI:            record.URI,
+		RequestHeaders: req.Header.Clone(),
+		RequestBody:    record.RequestBody,
+	}
+
+	// Redirects are returned as is
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		replayRecord.Duration = time.Since(replayRecord.Timestamp)
+		replayRecord.Error = err.Error()
+
+		return replayRecord, nil
+	}
+
+	defer resp.Body.Close()
+
+	responseBody := &cappedBuffer{
+		maxBytes: maxBodyBytes,
+	}
+
+	_, err = io.Copy(responseBody, resp.Body)
+
+	if err != nil {
+		replayRecord.Error = err.Error()
+	}
+
+	replayRecord.Duration = time.Since(replayRecord.Timestamp)
+	replayRecord.Status = resp.StatusCode
+	replayRecord.ResponseHeaders = resp.Header
+	replayRecord.ResponseBody = responseBody.Bytes()
+	replayRecord.ResponseBodyTruncated = responseBody.truncated
+
+	return replayRecord, nil
+}
@@ -0,0 +1,209 @@
+package inspector
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/netsim"
+)
+
+// InspectedPortHeader is set by the proxy to
+// the served port that the request targets
+const InspectedPortHeader = "X-Eleven-Inspected-Port"
+
+// ListenAndServe starts the server that the proxy sends the
+// requests of the inspected ports and of the bindings with
+// network conditions to. Network conditions are simulated then
+// requests are recorded and sent to the local upstream.
+func ListenAndServe(serverAddr string, inspector *Inspector) error {
+	return http.ListenAndServe(serverAddr, netsim.NewHandler(inspector))
+}
+
+func (i *Inspector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	port := r.Header.Get(InspectedPortHeader)
+	r.Header.Del(InspectedPortHeader)
+
+	if portAsInt, err := strconv.Atoi(port); err != nil || portAsInt <= 0 || portAsInt > 65535 {
+		http.Error(w, "invalid inspected port", http.StatusBadRequest)
+		return
+	}
+
+	maxBodyBytes, isInspected := i.getMaxBodyBytes(port)
+
+	if !isInspected {
+		buildUpstreamProxy(port, func(err error) {}).ServeHTTP(w, r)
+		return
+	}
+
+	record := &Record{
+		Port:           port,
+		Timestamp:      time.Now(),
+		RemoteAddr:     r.RemoteAddr,
+		Proto:          r.Proto,
+		Method:         r.Method,
+		Host:           r.Host,
+		URI:            r.RequestURI,
+		RequestHeaders: r.Header.Clone(),
+	}
+
+	requestBody := &cappedBuffer{
+		maxBytes: maxBodyBytes,
+	}
+
+	r.Body = &teeReadCloser{
+		Reader: io.TeeReader(r.Body, requestBody),
+		Closer: r.Body,
+	}
+
+	recorder := &responseRecorder{
+		ResponseWriter: w,
+		body: &cappedBuffer{
+			maxBytes: maxBodyBytes,
+		},
+	}
+
+	upstreamProxy := buildUpstreamProxy(port, func(err error) {
+		record.Error = err.Error()
+	})
+
+	// Deferred to record the
+	// requests that were aborted
+	defer func() {
+		record.Duration = time.Since(record.Timestamp)
+		record.RequestBody = requestBody.Bytes()
+		record.RequestBodyTruncated = requestBody.truncated
+
+		if len(record.Error) == 0 {
+			record.Status = recorder.status
+			record.ResponseHeaders = recorder.Header().Clone()
+			record.ResponseBody = recorder.body.Bytes()
+			record.ResponseBodyTruncated = recorder.body.truncated
+		}
+
+		i.addRecord(record)
+	}()
+
+	upstreamProxy.ServeHTTP(recorder, r)
+}
+
+func buildUpstreamProxy(
+	port string,
+	onError func(err error),
+) *httputil.ReverseProxy {
+
+	upstreamHost := net.JoinHostPort("127.0.0.1", port)
+
+	return &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "http"
+			r.URL.Host = upstreamHost
+
+			// Already set by the proxy
+			r.Header["X-Forwarded-For"] = nil
+		},
+		// Streaming responses (eg: SSE)
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			onError(err)
+
+			// Closes the connection without response so that the
+			// proxy handles the error like if the upstream was
+			// reached directly (eg: to display the error page)
+			panic(http.ErrAbortHandler)
+		},
+	}
+}
+
+// cappedBuffer keeps the first "maxBytes" bytes written
+type cappedBuffer struct {
+	bytes.Buffer
+	maxBytes  int64
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(data []byte) (int, error) {
+	dataLen := len(data)
+	remainingBytes := c.maxBytes - int64(c.Len())
+
+	if int64(dataLen) > remainingBytes {
+		c.truncated = true
+		data = data[:remainingBytes]
+	}
+
+	c.Buffer.Write(data)
+
+	// Never fails to not interrupt
+	// the inspected request
+	return dataLen, nil
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   *cappedBuffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is used by the reverse proxy to upgrade the connection
+// (eg: WebSockets). The "101" response is written directly to
+// the hijacked connection that is not recorded.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, readWriter, err := hijacker.Hijack()
+
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, readWriter, err
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func sortRecords(records []*Record) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+}
@@ -0,0 +1,242 @@
+package inspector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/testutil"
+	"github.com/eleven-sh/agent/proto"
+)
+
+func TestInspectorServeHTTP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("X-Upstream", "true")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+
+	defer upstream.Close()
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+	upstreamPort := upstreamURL.Port()
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, closedPort, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	inspector := New()
+	inspector.Configure(map[string]*proto.EnvServedPortBindings{
+		upstreamPort: {
+			Inspector: &proto.EnvServedPortInspector{
+				Enabled:      true,
+				MaxBodyBytes: 5,
+				MaxRecords:   2,
+			},
+		},
+
+		closedPort: {
+			Inspector: &proto.EnvServedPortInspector{
+				Enabled: true,
+			},
+		},
+	})
+
+	server := httptest.NewServer(inspector)
+	defer server.Close()
+
+	testCases := []struct {
+		test                  string
+		port                  string
+		body                  string
+		expectedStatus        int
+		expectedRecordBody    string
+		expectedBodyTruncated bool
+		expectRecordError     bool
+	}{
+		{
+			test:               "with small body",
+			port:               upstreamPort,
+			body:               "hello",
+			expectedStatus:     http.StatusCreated,
+			expectedRecordBody: "hello",
+		},
+
+		{
+			test:                  "with truncated body",
+			port:                  upstreamPort,
+			body:                  "hello world",
+			expectedStatus:        http.StatusCreated,
+			expectedRecordBody:    "hello",
+			expectedBodyTruncated: true,
+		},
+
+		{
+			test:              "with unreachable upstream",
+			port:              closedPort,
+			body:              "hello",
+			expectRecordError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			// Records are added after the
+			// response is sent to the client
+			records, unsubscribe := inspector.Subscribe([]string{tc.port})
+			defer unsubscribe()
+
+			req, _ := http.NewRequest("POST", server.URL+"/webhook", strings.NewReader(tc.body))
+			req.Header.Set(InspectedPortHeader, tc.port)
+
+			resp, err := http.DefaultClient.Do(req)
+
+			if tc.expectRecordError && err == nil {
+				resp.Body.Close()
+				t.Fatalf("expected connection to be closed, got status '%d'", resp.StatusCode)
+			}
+
+			if !tc.expectRecordError {
+				if err != nil {
+					t.Fatalf("expected no error, got '%+v'", err)
+				}
+
+				resp.Body.Close()
+
+				if resp.StatusCode != tc.expectedStatus {
+					t.Fatalf(
+						"expected status to equal '%d', got '%d'",
+						tc.expectedStatus,
+						resp.StatusCode,
+					)
+				}
+			}
+
+			var record *Record
+
+			select {
+			case record = <-records:
+			case <-time.After(time.Second):
+				t.Fatalf("expected request to be recorded, got nothing")
+			}
+
+			if tc.expectRecordError {
+				if len(record.Error) == 0 {
+					t.Fatalf("expected record error, got none")
+				}
+
+				return
+			}
+
+			if string(record.RequestBody) != tc.expectedRecordBody ||
+				string(record.ResponseBody) != tc.expectedRecordBody {
+
+				t.Fatalf(
+					"expected bodies to equal '%s', got '%s' and '%s'",
+					tc.expectedRecordBody,
+					record.RequestBody,
+					record.ResponseBody,
+				)
+			}
+
+			if record.RequestBodyTruncated != tc.expectedBodyTruncated {
+				t.Fatalf(
+					"expected body truncated to equal '%t', got '%t'",
+					tc.expectedBodyTruncated,
+					record.RequestBodyTruncated,
+				)
+			}
+
+			if record.Status != tc.expectedStatus ||
+				record.ResponseHeaders.Get("X-Upstream") != "true" ||
+				len(record.RequestHeaders.Get(InspectedPortHeader)) > 0 {
+
+				t.Fatalf("unexpected record '%+v'", record)
+			}
+		})
+	}
+}
+
+func TestInspectorServeHTTPWithUpgrade(t *testing.T) {
+	upstream := httptest.NewServer(testutil.NewUpgradeEchoHandler(t))
+	defer upstream.Close()
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+	upstreamPort := upstreamURL.Port()
+
+	inspector := New()
+	inspector.Configure(map[string]*proto.EnvServedPortBindings{
+		upstreamPort: {
+			Inspector: &proto.EnvServedPortInspector{
+				Enabled: true,
+			},
+		},
+	})
+
+	server := httptest.NewServer(inspector)
+	defer server.Close()
+
+	records, unsubscribe := inspector.Subscribe([]string{upstreamPort})
+	defer unsubscribe()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	fmt.Fprintf(
+		conn,
+		"GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: echo\r\n%s: %s\r\n\r\n",
+		InspectedPortHeader,
+		upstreamPort,
+	)
+
+	connReader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(connReader, nil)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf(
+			"expected status to equal '%d', got '%d'",
+			http.StatusSwitchingProtocols,
+			resp.StatusCode,
+		)
+	}
+
+	fmt.Fprintf(conn, "ping\n")
+
+	echoedLine, err := connReader.ReadString('\n')
+
+	if err != nil || echoedLine != "ping\n" {
+		t.Fatalf("expected echoed line to equal 'ping', got '%s' (%v)", echoedLine, err)
+	}
+
+	conn.Close()
+
+	var record *Record
+
+	select {
+	case record = <-records:
+	case <-time.After(time.Second):
+		t.Fatalf("expected request to be recorded, got nothing")
+	}
+
+	if record.Status != http.StatusSwitchingProtocols || len(record.Error) > 0 {
+		t.Fatalf("unexpected record '%+v'", record)
+	}
+}
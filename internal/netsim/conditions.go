@@ -0,0 +1,178 @@
+package netsim
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+const (
+	// ConditionsHeader is set by the proxy to the network
+	// conditions of the binding that the request targets
+	ConditionsHeader = "X-Eleven-Network-Conditions"
+
+	// DroppedConnectionHeader is set in the response when the
+	// proxy needs to close the client connection without response
+	DroppedConnectionHeader = "X-Eleven-Dropped-Connection"
+)
+
+const (
+	latencyKey     = "latency_ms"
+	jitterKey      = "jitter_ms"
+	bandwidthKey   = "bandwidth_bytes_per_second"
+	dropRateKey    = "drop_rate"
+	errorRateKey   = "error_rate"
+	errorStatusKey = "error_status"
+)
+
+// IsEnabled returns true if network conditions
+// are set for the passed binding.
+func IsEnabled(binding *proto.EnvServedPortBinding) bool {
+	return GetConditions(binding) != nil
+}
+
+// GetConditions returns nil when no network conditions are set
+// so that bindings without conditions (or with empty ones)
+// are handled the same way.
+func GetConditions(
+	binding *proto.EnvServedPortBinding,
+) *proto.EnvServedPortBindingNetworkConditions {
+
+	if binding.NetworkConditions == nil ||
+		goproto.Size(binding.NetworkConditions) == 0 {
+
+		return nil
+	}
+
+	return binding.NetworkConditions
+}
+
+// IsSupported returns true if network conditions could be
+// simulated for the passed binding. Only HTTP bindings proxied
+// to their served port are supported (not the ones with multiple
+// upstreams, a Unix socket or the directory and redirect ones).
+func IsSupported(port string, binding *proto.EnvServedPortBinding) bool {
+	if env.GetServedPortBindingProtocol(binding) != env.ConfigServedPortProtocolHTTP ||
+		len(binding.UnixSocket) > 0 {
+
+		return false
+	}
+
+	switch entities.EnvServedPortBindingType(binding.Type) {
+	case entities.EnvServedPortBindingTypeDomain:
+		return len(binding.Upstreams) == 0
+	case entities.EnvServedPortBindingTypePort:
+		// Port already bound by user application
+		return binding.Value != port
+	}
+
+	return false
+}
+
+// BuildHeaderValue encodes the passed conditions so that
+// the proxy could send them along with the request.
+func BuildHeaderValue(
+	conditions *proto.EnvServedPortBindingNetworkConditions,
+) string {
+
+	values := url.Values{}
+
+	if conditions.LatencyMs > 0 {
+		values.Set(latencyKey, strconv.Itoa(int(conditions.LatencyMs)))
+	}
+
+	if conditions.JitterMs > 0 {
+		values.Set(jitterKey, strconv.Itoa(int(conditions.JitterMs)))
+	}
+
+	if conditions.BandwidthBytesPerSecond > 0 {
+		values.Set(
+			bandwidthKey,
+			strconv.FormatInt(conditions.BandwidthBytesPerSecond, 10),
+		)
+	}
+
+	if conditions.DropRate > 0 {
+		values.Set(dropRateKey, strconv.FormatFloat(conditions.DropRate, 'f', -1, 64))
+	}
+
+	if conditions.ErrorRate > 0 {
+		values.Set(errorRateKey, strconv.FormatFloat(conditions.ErrorRate, 'f', -1, 64))
+	}
+
+	if conditions.ErrorStatus > 0 {
+		values.Set(errorStatusKey, strconv.Itoa(int(conditions.ErrorStatus)))
+	}
+
+	return values.Encode()
+}
+
+// ParseHeaderValue decodes the conditions
+// encoded using "BuildHeaderValue".
+func ParseHeaderValue(
+	headerValue string,
+) (*proto.EnvServedPortBindingNetworkConditions, error) {
+
+	values, err := url.ParseQuery(headerValue)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := &proto.EnvServedPortBindingNetworkConditions{}
+
+	parseInt32 := func(key string) (int32, error) {
+		if len(values.Get(key)) == 0 {
+			return 0, nil
+		}
+
+		value, err := strconv.ParseInt(values.Get(key), 10, 32)
+		return int32(value), err
+	}
+
+	parseFloat := func(key string) (float64, error) {
+		if len(values.Get(key)) == 0 {
+			return 0, nil
+		}
+
+		return strconv.ParseFloat(values.Get(key), 64)
+	}
+
+	if conditions.LatencyMs, err = parseInt32(latencyKey); err != nil {
+		return nil, err
+	}
+
+	if conditions.JitterMs, err = parseInt32(jitterKey); err != nil {
+		return nil, err
+	}
+
+	if conditions.ErrorStatus, err = parseInt32(errorStatusKey); err != nil {
+		return nil, err
+	}
+
+	if conditions.DropRate, err = parseFloat(dropRateKey); err != nil {
+		return nil, err
+	}
+
+	if conditions.ErrorRate, err = parseFloat(errorRateKey); err != nil {
+		return nil, err
+	}
+
+	if len(values.Get(bandwidthKey)) > 0 {
+		conditions.BandwidthBytesPerSecond, err = strconv.ParseInt(
+			values.Get(bandwidthKey),
+			10,
+			64,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conditions, nil
+}
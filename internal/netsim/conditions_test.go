@@ -0,0 +1,121 @@
+package netsim
+
+import (
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+func TestHeaderValue(t *testing.T) {
+	testCases := []struct {
+		test       string
+		conditions *proto.EnvServedPortBindingNetworkConditions
+	}{
+		{
+			test:       "with empty conditions",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{},
+		},
+
+		{
+			test: "with all conditions",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{
+				LatencyMs:               200,
+				JitterMs:                50,
+				BandwidthBytesPerSecond: 64 * 1024,
+				DropRate:                0.1,
+				ErrorRate:               0.05,
+				ErrorStatus:             504,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			conditions, err := ParseHeaderValue(BuildHeaderValue(tc.conditions))
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !goproto.Equal(conditions, tc.conditions) {
+				t.Fatalf(
+					"expected conditions to equal '%+v', got '%+v'",
+					tc.conditions,
+					conditions,
+				)
+			}
+		})
+	}
+}
+
+func TestIsSupported(t *testing.T) {
+	testCases := []struct {
+		test              string
+		binding           *proto.EnvServedPortBinding
+		expectedSupported bool
+	}{
+		{
+			test: "with domain binding",
+			binding: &proto.EnvServedPortBinding{
+				Value: "api.domain.com",
+				Type:  string(entities.EnvServedPortBindingTypeDomain),
+			},
+			expectedSupported: true,
+		},
+
+		{
+			test: "with domain binding with upstreams",
+			binding: &proto.EnvServedPortBinding{
+				Value: "api.domain.com",
+				Type:  string(entities.EnvServedPortBindingTypeDomain),
+				Upstreams: []*proto.EnvServedPortBindingUpstream{
+					{
+						Port: "3000",
+					},
+				},
+			},
+		},
+
+		{
+			test: "with port binding",
+			binding: &proto.EnvServedPortBinding{
+				Value: "8000",
+				Type:  string(entities.EnvServedPortBindingTypePort),
+			},
+			expectedSupported: true,
+		},
+
+		{
+			test: "with port already bound by application",
+			binding: &proto.EnvServedPortBinding{
+				Value: "3000",
+				Type:  string(entities.EnvServedPortBindingTypePort),
+			},
+		},
+
+		{
+			test: "with TCP port binding",
+			binding: &proto.EnvServedPortBinding{
+				Value:    "8000",
+				Type:     string(entities.EnvServedPortBindingTypePort),
+				Protocol: "tcp",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			isSupported := IsSupported("3000", tc.binding)
+
+			if isSupported != tc.expectedSupported {
+				t.Fatalf(
+					"expected supported to equal '%+v', got '%+v'",
+					tc.expectedSupported,
+					isSupported,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package netsim
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultErrorStatus = http.StatusServiceUnavailable
+
+	// Bandwidth is enforced every
+	// 1/throttleSlices second
+	throttleSlices = 10
+)
+
+// Overridden in tests
+var randFloat64 = rand.Float64
+
+// NewHandler returns a handler that simulates the network
+// conditions sent by the proxy (if any) before calling
+// the passed handler.
+func NewHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerValue := r.Header.Get(ConditionsHeader)
+		r.Header.Del(ConditionsHeader)
+
+		if len(headerValue) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		conditions, err := ParseHeaderValue(headerValue)
+
+		if err != nil {
+			log.Printf("[Network conditions] Error when parsing conditions: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		delay := time.Duration(conditions.LatencyMs) * time.Millisecond
+
+		if conditions.JitterMs > 0 {
+			jitter := time.Duration(conditions.JitterMs) * time.Millisecond
+			delay += time.Duration((randFloat64()*2 - 1) * float64(jitter))
+		}
+
+		if !sleep(r.Context(), delay) {
+			return
+		}
+
+		if conditions.DropRate > 0 && randFloat64() < conditions.DropRate {
+			w.Header().Set(DroppedConnectionHeader, "true")
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		if conditions.ErrorRate > 0 && randFloat64() < conditions.ErrorRate {
+			errorStatus := int(conditions.ErrorStatus)
+
+			if errorStatus == 0 {
+				errorStatus = defaultErrorStatus
+			}
+
+			http.Error(w, http.StatusText(errorStatus), errorStatus)
+			return
+		}
+
+		if conditions.BandwidthBytesPerSecond > 0 {
+			r.Body = &throttledReadCloser{
+				ReadCloser: r.Body,
+				throttler:  newThrottler(r.Context(), conditions.BandwidthBytesPerSecond),
+			}
+
+			w = &throttledResponseWriter{
+				ResponseWriter: w,
+				throttler:      newThrottler(r.Context(), conditions.BandwidthBytesPerSecond),
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sleep returns false if the passed
+// context was canceled while sleeping
+func sleep(ctx context.Context, duration time.Duration) bool {
+	if duration <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// throttler limits the number of bytes
+// transferred per second in one direction
+type throttler struct {
+	ctx              context.Context
+	bytesPerSecond   int64
+	maxBytesPerSlice int
+	start            time.Time
+	transferredBytes int64
+}
+
+func newThrottler(ctx context.Context, bytesPerSecond int64) *throttler {
+	maxBytesPerSlice := int(bytesPerSecond / throttleSlices)
+
+	if maxBytesPerSlice < 1 {
+		maxBytesPerSlice = 1
+	}
+
+	return &throttler{
+		ctx:              ctx,
+		bytesPerSecond:   bytesPerSecond,
+		maxBytesPerSlice: maxBytesPerSlice,
+		start:            time.Now(),
+	}
+}
+
+// wait blocks until the passed number of bytes
+// could be transferred without exceeding the bandwidth
+func (t *throttler) wait(transferredBytes int) bool {
+	t.transferredBytes += int64(transferredBytes)
+
+	expectedDuration := time.Duration(
+		float64(t.transferredBytes) / float64(t.bytesPerSecond) * float64(time.Second),
+	)
+
+	return sleep(t.ctx, expectedDuration-time.Since(t.start))
+}
+
+type throttledReadCloser struct {
+	io.ReadCloser
+	throttler *throttler
+}
+
+func (t *throttledReadCloser) Read(data []byte) (int, error) {
+	if len(data) > t.throttler.maxBytesPerSlice {
+		data = data[:t.throttler.maxBytesPerSlice]
+	}
+
+	n, err := t.ReadCloser.Read(data)
+
+	if !t.throttler.wait(n) {
+		return n, t.throttler.ctx.Err()
+	}
+
+	return n, err
+}
+
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	throttler *throttler
+}
+
+func (t *throttledResponseWriter) Write(data []byte) (int, error) {
+	writtenBytes := 0
+
+	for len(data) > 0 {
+		slice := data
+
+		if len(slice) > t.throttler.maxBytesPerSlice {
+			slice = slice[:t.throttler.maxBytesPerSlice]
+		}
+
+		n, err := t.ResponseWriter.Write(slice)
+		writtenBytes += n
+
+		if err != nil {
+			return writtenBytes, err
+		}
+
+		// Sends the slice before waiting
+		t.Flush()
+
+		if !t.throttler.wait(n) {
+			return writtenBytes, t.throttler.ctx.Err()
+		}
+
+		data = data[n:]
+	}
+
+	return writtenBytes, nil
+}
+
+func (t *throttledResponseWriter) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is used by the reverse proxy to upgrade the connection
+// (eg: WebSockets). The upgraded connection is not throttled.
+func (t *throttledResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := t.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	return hijacker.Hijack()
+}
+
+func (t *throttledResponseWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
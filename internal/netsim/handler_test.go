@@ -0,0 +1,229 @@
+package netsim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+func TestHandler(t *testing.T) {
+	randFloat64 = func() float64 { return 0.5 }
+	defer func() { randFloat64 = rand.Float64 }()
+
+	upstreamBody := strings.Repeat("a", 100)
+
+	server := httptest.NewServer(NewHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.Header.Get(ConditionsHeader)) > 0 {
+				t.Errorf("expected conditions header to be removed")
+			}
+
+			io.WriteString(w, upstreamBody)
+		}),
+	))
+
+	defer server.Close()
+
+	testCases := []struct {
+		test                string
+		conditions          *proto.EnvServedPortBindingNetworkConditions
+		expectedStatus      int
+		expectDroppedHeader bool
+		expectedBody        string
+		expectedMinDuration time.Duration
+	}{
+		{
+			test:           "without conditions",
+			expectedStatus: http.StatusOK,
+			expectedBody:   upstreamBody,
+		},
+
+		{
+			test: "with latency and jitter",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{
+				LatencyMs: 100,
+				JitterMs:  50,
+			},
+			expectedStatus:      http.StatusOK,
+			expectedBody:        upstreamBody,
+			expectedMinDuration: 100 * time.Millisecond,
+		},
+
+		{
+			test: "with bandwidth",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{
+				BandwidthBytesPerSecond: 500,
+			},
+			expectedStatus:      http.StatusOK,
+			expectedBody:        upstreamBody,
+			expectedMinDuration: 200 * time.Millisecond,
+		},
+
+		{
+			test: "with dropped connection",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{
+				DropRate: 0.6,
+			},
+			expectedStatus:      http.StatusBadGateway,
+			expectDroppedHeader: true,
+		},
+
+		{
+			test: "with connection not dropped",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{
+				DropRate: 0.4,
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   upstreamBody,
+		},
+
+		{
+			test: "with injected error",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{
+				ErrorRate:   1,
+				ErrorStatus: http.StatusGatewayTimeout,
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   http.StatusText(http.StatusGatewayTimeout) + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+
+			if tc.conditions != nil {
+				req.Header.Set(ConditionsHeader, BuildHeaderValue(tc.conditions))
+			}
+
+			start := time.Now()
+			resp, err := http.DefaultClient.Do(req)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			duration := time.Since(start)
+
+			if resp.StatusCode != tc.expectedStatus {
+				t.Fatalf(
+					"expected status to equal '%d', got '%d'",
+					tc.expectedStatus,
+					resp.StatusCode,
+				)
+			}
+
+			hasDroppedHeader := resp.Header.Get(DroppedConnectionHeader) == "true"
+
+			if hasDroppedHeader != tc.expectDroppedHeader {
+				t.Fatalf(
+					"expected dropped connection header to equal '%+v', got '%+v'",
+					tc.expectDroppedHeader,
+					hasDroppedHeader,
+				)
+			}
+
+			if !tc.expectDroppedHeader && string(body) != tc.expectedBody {
+				t.Fatalf(
+					"expected body to equal '%s', got '%s'",
+					tc.expectedBody,
+					body,
+				)
+			}
+
+			if duration < tc.expectedMinDuration {
+				t.Fatalf(
+					"expected duration to be at least '%v', got '%v'",
+					tc.expectedMinDuration,
+					duration,
+				)
+			}
+		})
+	}
+}
+
+func TestHandlerWithUpgrade(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, readWriter, err := w.(http.Hijacker).Hijack()
+
+		if err != nil {
+			t.Errorf("expected no error, got '%+v'", err)
+			return
+		}
+
+		defer conn.Close()
+
+		readWriter.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		readWriter.Flush()
+
+		line, _ := readWriter.ReadString('\n')
+		readWriter.WriteString(line)
+		readWriter.Flush()
+	}))
+
+	defer upstream.Close()
+
+	upstreamURL, _ := url.Parse(upstream.URL)
+
+	server := httptest.NewServer(
+		NewHandler(httputil.NewSingleHostReverseProxy(upstreamURL)),
+	)
+
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	fmt.Fprintf(
+		conn,
+		"GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: echo\r\n%s: %s\r\n\r\n",
+		ConditionsHeader,
+		BuildHeaderValue(&proto.EnvServedPortBindingNetworkConditions{
+			BandwidthBytesPerSecond: 64 * 1024,
+		}),
+	)
+
+	connReader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(connReader, nil)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf(
+			"expected status to equal '%d', got '%d'",
+			http.StatusSwitchingProtocols,
+			resp.StatusCode,
+		)
+	}
+
+	fmt.Fprintf(conn, "ping\n")
+
+	echoedLine, err := connReader.ReadString('\n')
+
+	if err != nil || echoedLine != "ping\n" {
+		t.Fatalf("expected echoed line to equal 'ping', got '%s' (%v)", echoedLine, err)
+	}
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"net"
+)
+
+// GetInterfacesIPs returns the non-loopback unicast IPs (IPv4 and IPv6)
+// of the passed network interface or of all the up interfaces if
+// the passed name is empty. IPv6 link-local IPs are skipped
+// given that they could not be used without zone.
+func GetInterfacesIPs(interfaceName string) ([]net.IP, error) {
+	var interfaces []net.Interface
+
+	if len(interfaceName) > 0 {
+		netInterface, err := net.InterfaceByName(interfaceName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		interfaces = []net.Interface{*netInterface}
+	} else {
+		allInterfaces, err := net.Interfaces()
+
+		if err != nil {
+			return nil, err
+		}
+
+		interfaces = allInterfaces
+	}
+
+	IPs := []net.IP{}
+
+	for _, netInterface := range interfaces {
+		if netInterface.Flags&net.FlagUp == 0 ||
+			netInterface.Flags&net.FlagLoopback != 0 {
+
+			continue
+		}
+
+		addrs, err := netInterface.Addrs()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			IPNet, ok := addr.(*net.IPNet)
+
+			if !ok {
+				continue
+			}
+
+			IP := IPNet.IP
+
+			if IP.IsLoopback() ||
+				IP.IsLinkLocalUnicast() ||
+				IP.IsMulticast() {
+
+				continue
+			}
+
+			IPs = append(IPs, IP)
+		}
+	}
+
+	return IPs, nil
+}
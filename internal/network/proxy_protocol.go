@@ -0,0 +1,69 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// Ref: https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt
+var proxyProtocolV2Signature = []byte{
+	0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A,
+}
+
+const (
+	proxyProtocolV2VersionAndProxyCmd = 0x21
+	proxyProtocolV2TCP4Family         = 0x11
+	proxyProtocolV2TCP6Family         = 0x21
+)
+
+// BuildProxyProtocolV1Header returns the human-readable
+// PROXY protocol header describing the passed connection.
+func BuildProxyProtocolV1Header(srcAddr, dstAddr *net.TCPAddr) []byte {
+	if srcAddr.IP.To4() != nil && dstAddr.IP.To4() != nil {
+		return []byte(fmt.Sprintf(
+			"PROXY TCP4 %s %s %d %d\r\n",
+			srcAddr.IP.To4(),
+			dstAddr.IP.To4(),
+			srcAddr.Port,
+			dstAddr.Port,
+		))
+	}
+
+	return []byte(fmt.Sprintf(
+		"PROXY TCP6 %s %s %d %d\r\n",
+		srcAddr.IP.To16(),
+		dstAddr.IP.To16(),
+		srcAddr.Port,
+		dstAddr.Port,
+	))
+}
+
+// BuildProxyProtocolV2Header returns the binary
+// PROXY protocol header describing the passed connection.
+func BuildProxyProtocolV2Header(srcAddr, dstAddr *net.TCPAddr) []byte {
+	family := byte(proxyProtocolV2TCP6Family)
+	srcIP := srcAddr.IP.To16()
+	dstIP := dstAddr.IP.To16()
+
+	if srcAddr.IP.To4() != nil && dstAddr.IP.To4() != nil {
+		family = proxyProtocolV2TCP4Family
+		srcIP = srcAddr.IP.To4()
+		dstIP = dstAddr.IP.To4()
+	}
+
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[0:], uint16(srcAddr.Port))
+	binary.BigEndian.PutUint16(ports[2:], uint16(dstAddr.Port))
+
+	addrsLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(addrsLen, uint16(len(srcIP)+len(dstIP)+len(ports)))
+
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, proxyProtocolV2VersionAndProxyCmd, family)
+	header = append(header, addrsLen...)
+	header = append(header, srcIP...)
+	header = append(header, dstIP...)
+
+	return append(header, ports...)
+}
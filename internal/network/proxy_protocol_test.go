@@ -0,0 +1,68 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestBuildProxyProtocolV1Header(t *testing.T) {
+	testCases := []struct {
+		test           string
+		srcAddr        *net.TCPAddr
+		dstAddr        *net.TCPAddr
+		expectedHeader string
+	}{
+		{
+			test:           "with IPv4 addresses",
+			srcAddr:        &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234},
+			dstAddr:        &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5432},
+			expectedHeader: "PROXY TCP4 203.0.113.7 10.0.0.2 51234 5432\r\n",
+		},
+
+		{
+			test:           "with IPv6 addresses",
+			srcAddr:        &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 51234},
+			dstAddr:        &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 5432},
+			expectedHeader: "PROXY TCP6 2001:db8::7 2001:db8::2 51234 5432\r\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			header := BuildProxyProtocolV1Header(tc.srcAddr, tc.dstAddr)
+
+			if string(header) != tc.expectedHeader {
+				t.Fatalf(
+					"expected header to equal '%q', got '%q'",
+					tc.expectedHeader,
+					header,
+				)
+			}
+		})
+	}
+}
+
+func TestBuildProxyProtocolV2Header(t *testing.T) {
+	header := BuildProxyProtocolV2Header(
+		&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234},
+		&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5432},
+	)
+
+	expectedHeader := append(
+		append([]byte{}, proxyProtocolV2Signature...),
+		0x21, 0x11, 0x00, 0x0C,
+		203, 0, 113, 7,
+		10, 0, 0, 2,
+		0xC8, 0x22,
+		0x15, 0x38,
+	)
+
+	if !bytes.Equal(header, expectedHeader) {
+		t.Fatalf(
+			"expected header to equal '%x', got '%x'",
+			expectedHeader,
+			header,
+		)
+	}
+}
@@ -2,7 +2,6 @@ package network
 
 import (
 	"fmt"
-	"net"
 
 	"github.com/prometheus/procfs"
 )
@@ -33,17 +32,3 @@ func GetOpenedTCPConns() (procfs.NetTCP, error) {
 
 	return append(tcpIPv4, tcpIPv6...), nil
 }
-
-func GetOutboundIP() (net.IP, error) {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer conn.Close()
-
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-
-	return localAddr.IP, nil
-}
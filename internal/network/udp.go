@@ -0,0 +1,26 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/prometheus/procfs"
+)
+
+func GetBoundUDPSockets() (procfs.NetUDP, error) {
+	proc, err := procfs.NewFS("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("could not read /proc: %s", err)
+	}
+
+	udpIPv4, err := proc.NetUDP()
+	if err != nil {
+		return nil, fmt.Errorf("could not read /proc/net/udp: %s", err)
+	}
+
+	udpIPv6, err := proc.NetUDP6()
+	if err != nil {
+		return nil, fmt.Errorf("could not read /proc/net/udp6: %s", err)
+	}
+
+	return append(udpIPv4, udpIPv6...), nil
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/netsim"
+	"github.com/eleven-sh/agent/proto"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrBindingNotFound               = errors.New("ErrBindingNotFound")
+	ErrNetworkConditionsNotSupported = errors.New("ErrNetworkConditionsNotSupported")
+)
+
+// SetNetworkConditions sets the network conditions simulated
+// for the binding with the passed value (a domain or an external port).
+// Conditions are removed when nil or empty conditions are passed.
+func SetNetworkConditions(
+	state *State,
+	bindingValue string,
+	conditions *proto.EnvServedPortBindingNetworkConditions,
+) error {
+
+	if conditions != nil && goproto.Size(conditions) == 0 {
+		conditions = nil
+	}
+
+	for port, portBindings := range state.ServedPorts {
+		for _, binding := range portBindings.Bindings {
+			if env.GetServedPortBindingProtocol(binding) != env.ConfigServedPortProtocolHTTP {
+				continue
+			}
+
+			isDomainBinding := env.IsDomainServedPortBinding(binding)
+
+			if (isDomainBinding && !strings.EqualFold(binding.Value, bindingValue)) ||
+				(!isDomainBinding && binding.Value != bindingValue) {
+
+				continue
+			}
+
+			if conditions != nil && !netsim.IsSupported(port, binding) {
+				return ErrNetworkConditionsNotSupported
+			}
+
+			binding.NetworkConditions = conditions
+
+			return nil
+		}
+	}
+
+	return ErrBindingNotFound
+}
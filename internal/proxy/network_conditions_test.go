@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+func TestSetNetworkConditions(t *testing.T) {
+	conditions := &proto.EnvServedPortBindingNetworkConditions{
+		LatencyMs: 200,
+		DropRate:  0.1,
+	}
+
+	testCases := []struct {
+		test               string
+		binding            string
+		conditions         *proto.EnvServedPortBindingNetworkConditions
+		expectedError      error
+		expectedConditions *proto.EnvServedPortBindingNetworkConditions
+	}{
+		{
+			test:               "with domain binding",
+			binding:            "API.domain.com",
+			conditions:         conditions,
+			expectedConditions: conditions,
+		},
+
+		{
+			test:       "with empty conditions",
+			binding:    "api.domain.com",
+			conditions: &proto.EnvServedPortBindingNetworkConditions{},
+		},
+
+		{
+			test:          "with port already bound by application",
+			binding:       "3000",
+			conditions:    conditions,
+			expectedError: ErrNetworkConditionsNotSupported,
+		},
+
+		{
+			test:          "with unknown binding",
+			binding:       "www.domain.com",
+			conditions:    conditions,
+			expectedError: ErrBindingNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			state := NewState()
+			state.ServedPorts["3000"] = &proto.EnvServedPortBindings{
+				Bindings: []*proto.EnvServedPortBinding{
+					{
+						Value: "api.domain.com",
+						Type:  string(entities.EnvServedPortBindingTypeDomain),
+						NetworkConditions: &proto.EnvServedPortBindingNetworkConditions{
+							ErrorRate: 0.5,
+						},
+					},
+
+					{
+						Value: "3000",
+						Type:  string(entities.EnvServedPortBindingTypePort),
+					},
+				},
+			}
+
+			err := SetNetworkConditions(state, tc.binding, tc.conditions)
+
+			if err != tc.expectedError {
+				t.Fatalf(
+					"expected error to equal '%+v', got '%+v'",
+					tc.expectedError,
+					err,
+				)
+			}
+
+			if err != nil {
+				return
+			}
+
+			bindingConditions := state.ServedPorts["3000"].Bindings[0].NetworkConditions
+
+			if !goproto.Equal(bindingConditions, tc.expectedConditions) {
+				t.Fatalf(
+					"expected conditions to equal '%+v', got '%+v'",
+					tc.expectedConditions,
+					bindingConditions,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+)
+
+// GetPreviewPorts returns the sorted list of
+// listening ports allowed by the preview domain config.
+func GetPreviewPorts(
+	previewDomain *env.ConfigPreviewDomain,
+	listeningPorts []string,
+) []string {
+
+	deniedPorts := map[string]bool{}
+
+	for _, port := range GetPreviewDeniedPorts(previewDomain) {
+		deniedPorts[port] = true
+	}
+
+	allowedPorts := map[string]bool{}
+
+	for _, port := range previewDomain.AllowedPorts {
+		allowedPorts[port] = true
+	}
+
+	portsSet := map[string]bool{}
+
+	for _, port := range listeningPorts {
+		if deniedPorts[port] {
+			continue
+		}
+
+		if len(allowedPorts) > 0 && !allowedPorts[port] {
+			continue
+		}
+
+		portsSet[port] = true
+	}
+
+	ports := []string{}
+
+	for port := range portsSet {
+		ports = append(ports, port)
+	}
+
+	sort.Strings(ports)
+
+	return ports
+}
+
+// GetPreviewDeniedPorts returns the sorted list of the ports
+// denied by the preview domain config and the reserved ones.
+func GetPreviewDeniedPorts(previewDomain *env.ConfigPreviewDomain) []string {
+	portsSet := map[string]bool{}
+
+	for _, port := range config.EnvReservedPorts {
+		portsSet[port] = true
+	}
+
+	for _, port := range previewDomain.DeniedPorts {
+		portsSet[port] = true
+	}
+
+	ports := []string{}
+
+	for port := range portsSet {
+		ports = append(ports, port)
+	}
+
+	sort.Strings(ports)
+
+	return ports
+}
+
+// IsPreviewDomainHostServed returns true if the passed host
+// is the host of a listening port allowed by the preview domain config.
+func IsPreviewDomainHostServed(
+	previewDomain *env.ConfigPreviewDomain,
+	listeningPorts []string,
+	host string,
+) bool {
+
+	port, isPreviewDomainHost := ParsePreviewDomainHost(previewDomain.Domain, host)
+
+	if !isPreviewDomainHost {
+		return false
+	}
+
+	for _, previewPort := range GetPreviewPorts(previewDomain, listeningPorts) {
+		if previewPort == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func BuildPreviewDomainHost(previewDomain string, port string) string {
+	return port + "." + previewDomain
+}
+
+// ParsePreviewDomainHost returns the port of the passed
+// "<port>.<domain>" host. Domains are case-insensitive.
+func ParsePreviewDomainHost(previewDomain string, host string) (string, bool) {
+	domainSuffix := "." + strings.ToLower(previewDomain)
+	lowercasedHost := strings.ToLower(host)
+
+	if !strings.HasSuffix(lowercasedHost, domainSuffix) {
+		return "", false
+	}
+
+	port := strings.TrimSuffix(lowercasedHost, domainSuffix)
+
+	if len(port) == 0 || strings.Contains(port, ".") {
+		return "", false
+	}
+
+	return port, true
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+)
+
+func TestGetPreviewPorts(t *testing.T) {
+	testCases := []struct {
+		test           string
+		previewDomain  *env.ConfigPreviewDomain
+		listeningPorts []string
+		expectedPorts  []string
+	}{
+		{
+			test: "with reserved ports",
+			previewDomain: &env.ConfigPreviewDomain{
+				Domain: "myenv.domain.com",
+			},
+			listeningPorts: []string{"22", "443", "2019", "3000"},
+			expectedPorts:  []string{"3000"},
+		},
+
+		{
+			test: "with allowed ports",
+			previewDomain: &env.ConfigPreviewDomain{
+				Domain:       "myenv.domain.com",
+				AllowedPorts: []string{"3000", "8080", "2019"},
+			},
+			listeningPorts: []string{"8080", "2019", "3000", "4000"},
+			expectedPorts:  []string{"3000", "8080"},
+		},
+
+		{
+			test: "with allowed and denied ports",
+			previewDomain: &env.ConfigPreviewDomain{
+				Domain:       "myenv.domain.com",
+				AllowedPorts: []string{"3000", "8080"},
+				DeniedPorts:  []string{"8080"},
+			},
+			listeningPorts: []string{"3000", "8080"},
+			expectedPorts:  []string{"3000"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			ports := GetPreviewPorts(
+				tc.previewDomain,
+				tc.listeningPorts,
+			)
+
+			if !reflect.DeepEqual(ports, tc.expectedPorts) {
+				t.Fatalf(
+					"expected ports to equal '%+v', got '%+v'",
+					tc.expectedPorts,
+					ports,
+				)
+			}
+		})
+	}
+}
+
+func TestIsPreviewDomainHostServed(t *testing.T) {
+	previewDomain := &env.ConfigPreviewDomain{
+		Domain:      "myenv.domain.com",
+		DeniedPorts: []string{"8080"},
+	}
+
+	listeningPorts := []string{"2019", "3000", "8080"}
+
+	testCases := []struct {
+		test           string
+		host           string
+		expectedServed bool
+	}{
+		{
+			test:           "with listening port",
+			host:           "3000.myenv.domain.com",
+			expectedServed: true,
+		},
+
+		{
+			test:           "with uppercase host",
+			host:           "3000.MyEnv.domain.com",
+			expectedServed: true,
+		},
+
+		{
+			test:           "with port not listened on",
+			host:           "4000.myenv.domain.com",
+			expectedServed: false,
+		},
+
+		{
+			test:           "with denied port",
+			host:           "8080.myenv.domain.com",
+			expectedServed: false,
+		},
+
+		{
+			test:           "with reserved port",
+			host:           "2019.myenv.domain.com",
+			expectedServed: false,
+		},
+
+		{
+			test:           "with non canonical port",
+			host:           "03000.myenv.domain.com",
+			expectedServed: false,
+		},
+
+		{
+			test:           "with nested subdomain",
+			host:           "api.3000.myenv.domain.com",
+			expectedServed: false,
+		},
+
+		{
+			test:           "with preview domain",
+			host:           "myenv.domain.com",
+			expectedServed: false,
+		},
+
+		{
+			test:           "with other domain",
+			host:           "3000.otherenv.domain.com",
+			expectedServed: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			served := IsPreviewDomainHostServed(
+				previewDomain,
+				listeningPorts,
+				tc.host,
+			)
+
+			if served != tc.expectedServed {
+				t.Fatalf(
+					"expected served to equal '%+v', got '%+v'",
+					tc.expectedServed,
+					served,
+				)
+			}
+		})
+	}
+}
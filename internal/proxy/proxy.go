@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/proto"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+// Proxy represents the reverse proxy that serves
+// the bindings of the env (eg: Caddy).
+type Proxy interface {
+	// Apply replaces the state served by the proxy.
+	// The passed state must have been validated.
+	Apply(state *State) error
+	// Current returns the state served by the proxy
+	// or nil if no state has been applied yet.
+	Current() (*State, error)
+	// Health returns an error if the proxy
+	// is not able to serve requests or
+	// "ErrStateDrifted" if the served state
+	// doesn't match the applied one.
+	Health() error
+}
+
+var ErrStateDrifted = errors.New("ErrStateDrifted")
+
+type State struct {
+	ServedPorts   map[string]*proto.EnvServedPortBindings
+	PreviewDomain *env.ConfigPreviewDomain
+	// Listening ports served as "<port>.<preview_domain>"
+	PreviewPorts            []string
+	DomainReachabilityCheck *DomainReachabilityCheck
+}
+
+// DomainReachabilityCheck makes the proxy respond with
+// the passed unique ID to HTTP requests sent to the domain.
+type DomainReachabilityCheck struct {
+	Domain   string
+	UniqueID string
+}
+
+// Prevents concurrent updates from overwriting each other
+var updateLock sync.Mutex
+
+// Update atomically applies the state returned by the passed
+// function to the proxy. The function is called with a copy
+// of the current state (or an empty one if none is applied)
+// and must return an error if the resulting state is invalid.
+// Nothing is applied if the state is unchanged.
+func Update(p Proxy, update func(state *State) error) error {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	currentState, err := p.Current()
+
+	if err != nil {
+		return err
+	}
+
+	if currentState == nil {
+		currentState = NewState()
+	}
+
+	newState := currentState.Clone()
+
+	err = update(newState)
+
+	if err != nil {
+		return err
+	}
+
+	if newState.Equal(currentState) {
+		return nil
+	}
+
+	return p.Apply(newState)
+}
+
+// Restore applies the state returned by the passed function
+// if no state has been applied yet (eg: on agent startup)
+// and re-applies the current state if it has drifted.
+func Restore(p Proxy, initialState func() (*State, error)) error {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	currentState, err := p.Current()
+
+	if err != nil {
+		return err
+	}
+
+	if currentState == nil {
+		stateToApply, err := initialState()
+
+		if err != nil {
+			return err
+		}
+
+		return p.Apply(stateToApply)
+	}
+
+	// Other errors are not returned given that
+	// a proxy that is restarting will be detected
+	// as drifted once restarted
+	if !errors.Is(p.Health(), ErrStateDrifted) {
+		return nil
+	}
+
+	return p.Apply(currentState)
+}
+
+func NewState() *State {
+	return &State{
+		ServedPorts:  map[string]*proto.EnvServedPortBindings{},
+		PreviewPorts: []string{},
+	}
+}
+
+// Clone returns a deep copy of the state so that
+// it could be updated without altering the applied one.
+func (s *State) Clone() *State {
+	clone := NewState()
+
+	for port, bindings := range s.ServedPorts {
+		clone.ServedPorts[port] = goproto.Clone(bindings).(*proto.EnvServedPortBindings)
+	}
+
+	if s.PreviewDomain != nil {
+		clone.PreviewDomain = &env.ConfigPreviewDomain{
+			Domain:       s.PreviewDomain.Domain,
+			AllowedPorts: append([]string{}, s.PreviewDomain.AllowedPorts...),
+			DeniedPorts:  append([]string{}, s.PreviewDomain.DeniedPorts...),
+		}
+	}
+
+	clone.PreviewPorts = append(clone.PreviewPorts, s.PreviewPorts...)
+
+	if s.DomainReachabilityCheck != nil {
+		check := *s.DomainReachabilityCheck
+		clone.DomainReachabilityCheck = &check
+	}
+
+	return clone
+}
+
+func (s *State) Equal(other *State) bool {
+	if len(s.ServedPorts) != len(other.ServedPorts) {
+		return false
+	}
+
+	for port, bindings := range s.ServedPorts {
+		otherBindings, hasPort := other.ServedPorts[port]
+
+		if !hasPort || !goproto.Equal(bindings, otherBindings) {
+			return false
+		}
+	}
+
+	return reflect.DeepEqual(s.PreviewDomain, other.PreviewDomain) &&
+		reflect.DeepEqual(s.PreviewPorts, other.PreviewPorts) &&
+		reflect.DeepEqual(s.DomainReachabilityCheck, other.DomainReachabilityCheck)
+}
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+)
+
+type testProxy struct {
+	appliedState *State
+	applyCalls   int
+	healthErr    error
+}
+
+func (t *testProxy) Apply(state *State) error {
+	t.applyCalls++
+	t.appliedState = state
+	return nil
+}
+
+func (t *testProxy) Current() (*State, error) {
+	if t.appliedState == nil {
+		return nil, nil
+	}
+
+	return t.appliedState.Clone(), nil
+}
+
+func (t *testProxy) Health() error {
+	return t.healthErr
+}
+
+func TestUpdate(t *testing.T) {
+	p := &testProxy{}
+	updateErr := errors.New("invalid state")
+
+	addPort := func(state *State) error {
+		state.ServedPorts["3000"] = &proto.EnvServedPortBindings{
+			Bindings: []*proto.EnvServedPortBinding{
+				{
+					Value: "8000",
+					Type:  "port",
+				},
+			},
+		}
+
+		return nil
+	}
+
+	testCases := []struct {
+		test               string
+		update             func(state *State) error
+		expectedError      error
+		expectedApplyCalls int
+	}{
+		{
+			test:               "with changed state",
+			update:             addPort,
+			expectedApplyCalls: 1,
+		},
+
+		{
+			test:               "with unchanged state",
+			update:             addPort,
+			expectedApplyCalls: 1,
+		},
+
+		{
+			test: "with update error",
+			update: func(state *State) error {
+				delete(state.ServedPorts, "3000")
+				return updateErr
+			},
+			expectedError:      updateErr,
+			expectedApplyCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			err := Update(p, tc.update)
+
+			if err != tc.expectedError {
+				t.Fatalf(
+					"expected error to equal '%+v', got '%+v'",
+					tc.expectedError,
+					err,
+				)
+			}
+
+			if p.applyCalls != tc.expectedApplyCalls {
+				t.Fatalf(
+					"expected apply calls to equal '%d', got '%d'",
+					tc.expectedApplyCalls,
+					p.applyCalls,
+				)
+			}
+
+			if _, hasPort := p.appliedState.ServedPorts["3000"]; !hasPort {
+				t.Fatalf("expected applied state to serve port '3000'")
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	initialState := func() (*State, error) {
+		state := NewState()
+		state.PreviewPorts = []string{"3000"}
+
+		return state, nil
+	}
+
+	appliedState := NewState()
+	appliedState.PreviewPorts = []string{"8080"}
+
+	testCases := []struct {
+		test               string
+		proxy              *testProxy
+		expectedApplyCalls int
+		expectedPorts      []string
+	}{
+		{
+			test:               "with no applied state",
+			proxy:              &testProxy{},
+			expectedApplyCalls: 1,
+			expectedPorts:      []string{"3000"},
+		},
+
+		{
+			test: "with healthy proxy",
+			proxy: &testProxy{
+				appliedState: appliedState,
+			},
+			expectedApplyCalls: 0,
+			expectedPorts:      []string{"8080"},
+		},
+
+		{
+			test: "with unreachable proxy",
+			proxy: &testProxy{
+				appliedState: appliedState,
+				healthErr:    errors.New("connection refused"),
+			},
+			expectedApplyCalls: 0,
+			expectedPorts:      []string{"8080"},
+		},
+
+		{
+			test: "with drifted proxy",
+			proxy: &testProxy{
+				appliedState: appliedState,
+				healthErr:    ErrStateDrifted,
+			},
+			expectedApplyCalls: 1,
+			expectedPorts:      []string{"8080"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			err := Restore(tc.proxy, initialState)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if tc.proxy.applyCalls != tc.expectedApplyCalls {
+				t.Fatalf(
+					"expected apply calls to equal '%d', got '%d'",
+					tc.expectedApplyCalls,
+					tc.proxy.applyCalls,
+				)
+			}
+
+			if !reflect.DeepEqual(tc.proxy.appliedState.PreviewPorts, tc.expectedPorts) {
+				t.Fatalf(
+					"expected preview ports to equal '%+v', got '%+v'",
+					tc.expectedPorts,
+					tc.proxy.appliedState.PreviewPorts,
+				)
+			}
+		})
+	}
+}
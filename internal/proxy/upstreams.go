@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"errors"
+
+	"github.com/eleven-sh/eleven/entities"
+)
+
+var (
+	ErrUpstreamsNotFound = errors.New("ErrUpstreamsNotFound")
+	ErrUpstreamNotFound  = errors.New("ErrUpstreamNotFound")
+)
+
+// SetActiveUpstream makes the passed port the active upstream
+// of the passed domain. Other upstreams become standby ones.
+func SetActiveUpstream(state *State, domain string, port string) error {
+	for _, portBindings := range state.ServedPorts {
+		for _, binding := range portBindings.Bindings {
+			if binding.Type != string(entities.EnvServedPortBindingTypeDomain) ||
+				binding.Value != domain ||
+				len(binding.Upstreams) == 0 {
+
+				continue
+			}
+
+			hasUpstream := false
+
+			for _, upstream := range binding.Upstreams {
+				if upstream.Port == port {
+					hasUpstream = true
+					break
+				}
+			}
+
+			if !hasUpstream {
+				return ErrUpstreamNotFound
+			}
+
+			for _, upstream := range binding.Upstreams {
+				upstream.Standby = upstream.Port != port
+			}
+
+			return nil
+		}
+	}
+
+	return ErrUpstreamsNotFound
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/proto"
+	"github.com/eleven-sh/eleven/entities"
+)
+
+func TestSetActiveUpstream(t *testing.T) {
+	testCases := []struct {
+		test                 string
+		domain               string
+		port                 string
+		expectedError        error
+		expectedStandbyPorts []string
+	}{
+		{
+			test:                 "with standby upstream",
+			domain:               "api.domain.com",
+			port:                 "3001",
+			expectedStandbyPorts: []string{"3000"},
+		},
+
+		{
+			test:                 "with active upstream",
+			domain:               "api.domain.com",
+			port:                 "3000",
+			expectedStandbyPorts: []string{"3001"},
+		},
+
+		{
+			test:                 "with unknown port",
+			domain:               "api.domain.com",
+			port:                 "3002",
+			expectedError:        ErrUpstreamNotFound,
+			expectedStandbyPorts: []string{"3001"},
+		},
+
+		{
+			test:                 "with unknown domain",
+			domain:               "www.domain.com",
+			port:                 "3001",
+			expectedError:        ErrUpstreamsNotFound,
+			expectedStandbyPorts: []string{"3001"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			state := NewState()
+			state.ServedPorts["3000"] = &proto.EnvServedPortBindings{
+				Bindings: []*proto.EnvServedPortBinding{
+					{
+						Value: "api.domain.com",
+						Type:  string(entities.EnvServedPortBindingTypeDomain),
+						Upstreams: []*proto.EnvServedPortBindingUpstream{
+							{
+								Port: "3000",
+							},
+
+							{
+								Port:    "3001",
+								Standby: true,
+							},
+						},
+					},
+				},
+			}
+
+			err := SetActiveUpstream(state, tc.domain, tc.port)
+
+			if err != tc.expectedError {
+				t.Fatalf(
+					"expected error to equal '%+v', got '%+v'",
+					tc.expectedError,
+					err,
+				)
+			}
+
+			standbyPorts := []string{}
+
+			for _, upstream := range state.ServedPorts["3000"].Bindings[0].Upstreams {
+				if upstream.Standby {
+					standbyPorts = append(standbyPorts, upstream.Port)
+				}
+			}
+
+			if !reflect.DeepEqual(standbyPorts, tc.expectedStandbyPorts) {
+				t.Fatalf(
+					"expected standby ports to equal '%+v', got '%+v'",
+					tc.expectedStandbyPorts,
+					standbyPorts,
+				)
+			}
+		})
+	}
+}
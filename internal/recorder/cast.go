@@ -0,0 +1,162 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Ref: https://docs.asciinema.org/manual/asciicast/v2/
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type castEventType string
+
+const (
+	castEventTypeOutput castEventType = "o"
+	castEventTypeResize castEventType = "r"
+)
+
+// Cast writes the output of a PTY session
+// as an asciicast v2 file. It is safe for
+// concurrent use.
+type Cast struct {
+	lock      sync.Mutex
+	file      *os.File
+	startedAt time.Time
+	// Bytes of an UTF-8 character
+	// split between two writes
+	pendingOutput []byte
+	err           error
+}
+
+func newCast(
+	file *os.File,
+	startedAt time.Time,
+	width int,
+	height int,
+	env map[string]string,
+) (*Cast, error) {
+
+	header, err := json.Marshal(castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: startedAt.Unix(),
+		Env:       env,
+	})
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Cast{
+		file:      file,
+		startedAt: startedAt,
+	}, nil
+}
+
+// Write records the passed output. Errors are logged and never
+// returned so that the session is not interrupted when the
+// recording fails (see "io.MultiWriter").
+func (c *Cast) Write(data []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	output := append(c.pendingOutput, data...)
+	completeOutputLen := getCompleteUTF8Len(output)
+
+	c.pendingOutput = append([]byte{}, output[completeOutputLen:]...)
+
+	if completeOutputLen > 0 {
+		c.writeEvent(castEventTypeOutput, string(output[:completeOutputLen]))
+	}
+
+	return len(data), nil
+}
+
+func (c *Cast) Resize(width, height int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.writeEvent(castEventTypeResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+func (c *Cast) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.pendingOutput) > 0 {
+		c.writeEvent(castEventTypeOutput, string(c.pendingOutput))
+		c.pendingOutput = nil
+	}
+
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+
+	return c.err
+}
+
+func (c *Cast) writeEvent(eventType castEventType, data string) {
+	// Nothing is recorded after the first error
+	if c.err != nil {
+		return
+	}
+
+	event, err := json.Marshal([]interface{}{
+		time.Since(c.startedAt).Seconds(),
+		eventType,
+		data,
+	})
+
+	if err == nil {
+		_, err = c.file.Write(append(event, '\n'))
+	}
+
+	if err != nil {
+		c.err = err
+
+		log.Printf(
+			"[Session recorder] Error when writing to %s: %v",
+			c.file.Name(),
+			err,
+		)
+	}
+}
+
+// getCompleteUTF8Len returns the length of the passed
+// data without the last UTF-8 character if it is incomplete
+func getCompleteUTF8Len(data []byte) int {
+	// UTF-8 characters are at most 4 bytes long
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		lastCharStart := len(data) - i
+
+		if !utf8.RuneStart(data[lastCharStart]) {
+			continue
+		}
+
+		if utf8.FullRune(data[lastCharStart:]) {
+			return len(data)
+		}
+
+		return lastCharStart
+	}
+
+	return len(data)
+}
@@ -0,0 +1,374 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RecordingType string
+
+const (
+	RecordingTypePTY  RecordingType = "pty"
+	RecordingTypeExec RecordingType = "exec"
+)
+
+const (
+	metadataFileExt = ".json"
+	castFileExt     = ".cast"
+
+	// Interval between two reads of the casts in progress
+	followPollInterval = 250 * time.Millisecond
+)
+
+var (
+	ErrRecordingNotFound   = errors.New("ErrRecordingNotFound")
+	ErrRecordingInProgress = errors.New("ErrRecordingInProgress")
+	ErrRecordingHasNoCast  = errors.New("ErrRecordingHasNoCast")
+)
+
+// IDs are used as file names
+var recordingIDRegExp = regexp.MustCompile(`^[0-9]+$`)
+
+// Only the command is recorded for "exec" sessions
+type Recording struct {
+	ID             string        `json:"id"`
+	Type           RecordingType `json:"type"`
+	User           string        `json:"user"`
+	KeyFingerprint string        `json:"key_fingerprint"`
+	Command        string        `json:"command,omitempty"`
+	StartedAt      time.Time     `json:"started_at"`
+	// Zero while the session is in progress
+	EndedAt    time.Time `json:"ended_at"`
+	ExitStatus int       `json:"exit_status"`
+	// Set when the agent stopped before the session ended
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+func (r *Recording) InProgress() bool {
+	return r.EndedAt.IsZero()
+}
+
+type Recorder struct {
+	dirPath string
+}
+
+func New(dirPath string) *Recorder {
+	return &Recorder{
+		dirPath: dirPath,
+	}
+}
+
+func (r *Recorder) Start(recording *Recording) error {
+	if err := os.MkdirAll(r.dirPath, 0700); err != nil {
+		return err
+	}
+
+	recording.StartedAt = time.Now()
+
+	// IDs must be unique even if two sessions start at the same time
+	for IDNano := recording.StartedAt.UnixNano(); ; IDNano++ {
+		recording.ID = strconv.FormatInt(IDNano, 10)
+
+		_, err := os.Stat(r.buildFilePath(recording.ID, metadataFileExt))
+
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.saveMetadata(recording)
+}
+
+// StartPTY returns the asciicast that the session output must be written to
+func (r *Recorder) StartPTY(
+	recording *Recording,
+	width int,
+	height int,
+	env map[string]string,
+) (*Cast, error) {
+
+	if err := r.Start(recording); err != nil {
+		return nil, err
+	}
+
+	castFile, err := os.OpenFile(
+		r.buildFilePath(recording.ID, castFileExt),
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		0600,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newCast(castFile, recording.StartedAt, width, height, env)
+}
+
+func (r *Recorder) End(recording *Recording, exitStatus int) error {
+	recording.EndedAt = time.Now()
+	recording.ExitStatus = exitStatus
+
+	return r.saveMetadata(recording)
+}
+
+// EndInterrupted must be called before any session is started
+func (r *Recorder) EndInterrupted() error {
+	recordings, err := r.List()
+
+	if err != nil {
+		return err
+	}
+
+	for _, recording := range recordings {
+		if !recording.InProgress() {
+			continue
+		}
+
+		recording.EndedAt = recording.StartedAt
+
+		castFileInfo, err := os.Stat(r.buildFilePath(recording.ID, castFileExt))
+
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err == nil && castFileInfo.ModTime().After(recording.EndedAt) {
+			recording.EndedAt = castFileInfo.ModTime()
+		}
+
+		recording.ExitStatus = -1
+		recording.Interrupted = true
+
+		if err := r.saveMetadata(recording); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Recorder) List() ([]*Recording, error) {
+	entries, err := os.ReadDir(r.dirPath)
+
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		return []*Recording{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := []*Recording{}
+
+	for _, entry := range entries {
+		ID := strings.TrimSuffix(entry.Name(), metadataFileExt)
+
+		if ID == entry.Name() || !recordingIDRegExp.MatchString(ID) {
+			continue
+		}
+
+		recording, err := r.Get(ID)
+
+		// Removed between two calls
+		if errors.Is(err, ErrRecordingNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		recordings = append(recordings, recording)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt.Before(recordings[j].StartedAt)
+	})
+
+	return recordings, nil
+}
+
+func (r *Recorder) Get(ID string) (*Recording, error) {
+	if !recordingIDRegExp.MatchString(ID) {
+		return nil, ErrRecordingNotFound
+	}
+
+	metadata, err := os.ReadFile(r.buildFilePath(ID, metadataFileExt))
+
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		return nil, ErrRecordingNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var recording *Recording
+	err = json.Unmarshal(metadata, &recording)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recording, nil
+}
+
+// Recordings in progress cannot be deleted
+func (r *Recorder) Delete(ID string) error {
+	recording, err := r.Get(ID)
+
+	if err != nil {
+		return err
+	}
+
+	if recording.InProgress() {
+		return ErrRecordingInProgress
+	}
+
+	return r.remove(ID)
+}
+
+// Prune removes the oldest ended recordings
+func (r *Recorder) Prune(maxRecordings int) error {
+	recordings, err := r.List()
+
+	if err != nil {
+		return err
+	}
+
+	recordingsToRemove := len(recordings) - maxRecordings
+
+	for _, recording := range recordings {
+		if recordingsToRemove <= 0 {
+			break
+		}
+
+		if recording.InProgress() {
+			continue
+		}
+
+		if err := r.remove(recording.ID); err != nil {
+			return err
+		}
+
+		recordingsToRemove--
+	}
+
+	return nil
+}
+
+// When "follow" is true, new events are sent until the recording ends
+func (r *Recorder) StreamCast(
+	ctx context.Context,
+	ID string,
+	follow bool,
+	send func(data []byte) error,
+) error {
+
+	recording, err := r.Get(ID)
+
+	if err != nil {
+		return err
+	}
+
+	if recording.Type != RecordingTypePTY {
+		return ErrRecordingHasNoCast
+	}
+
+	castFile, err := os.Open(r.buildFilePath(ID, castFileExt))
+
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		return ErrRecordingNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer castFile.Close()
+
+	buffer := make([]byte, 32*1024)
+
+	for {
+		n, err := castFile.Read(buffer)
+
+		if n > 0 {
+			if err := send(buffer[:n]); err != nil {
+				return err
+			}
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if err == nil {
+			continue
+		}
+
+		// EOF
+		if !follow || !recording.InProgress() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followPollInterval):
+		}
+
+		recording, err = r.Get(ID)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Recorder) saveMetadata(recording *Recording) error {
+	metadata, err := json.Marshal(recording)
+
+	if err != nil {
+		return err
+	}
+
+	metadataFilePath := r.buildFilePath(recording.ID, metadataFileExt)
+
+	// Prevents partially written metadata from being read
+	tmpFilePath := metadataFilePath + ".tmp"
+
+	if err := os.WriteFile(tmpFilePath, metadata, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilePath, metadataFilePath)
+}
+
+func (r *Recorder) remove(ID string) error {
+	for _, ext := range []string{castFileExt, metadataFileExt} {
+		err := os.Remove(r.buildFilePath(ID, ext))
+
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Recorder) buildFilePath(ID, ext string) string {
+	return filepath.Join(r.dirPath, ID+ext)
+}
@@ -0,0 +1,267 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecorderPTY(t *testing.T) {
+	sessionRecorder := New(t.TempDir())
+
+	recording := &Recording{
+		Type:           RecordingTypePTY,
+		User:           "eleven",
+		KeyFingerprint: "SHA256:fingerprint",
+	}
+
+	cast, err := sessionRecorder.StartPTY(recording, 80, 24, map[string]string{
+		"TERM": "xterm-256color",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	// "é" split between two writes
+	cast.Write([]byte("caf\xc3"))
+	cast.Write([]byte("\xa9\r\n"))
+	cast.Resize(100, 30)
+
+	if err := cast.Close(); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if err := sessionRecorder.End(recording, 2); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	castContent := bytes.Buffer{}
+	err = sessionRecorder.StreamCast(
+		context.Background(),
+		recording.ID,
+		true,
+		func(data []byte) error {
+			castContent.Write(data)
+			return nil
+		},
+	)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	castLines := bufio.NewScanner(&castContent)
+	castLines.Scan()
+
+	var header castHeader
+	json.Unmarshal(castLines.Bytes(), &header)
+
+	expectedHeader := castHeader{
+		Version:   2,
+		Width:     80,
+		Height:    24,
+		Timestamp: recording.StartedAt.Unix(),
+		Env: map[string]string{
+			"TERM": "xterm-256color",
+		},
+	}
+
+	if !reflect.DeepEqual(header, expectedHeader) {
+		t.Fatalf(
+			"expected header to equal '%+v', got '%+v'",
+			expectedHeader,
+			header,
+		)
+	}
+
+	events := [][]string{}
+
+	for castLines.Scan() {
+		var event []interface{}
+		json.Unmarshal(castLines.Bytes(), &event)
+
+		events = append(events, []string{event[1].(string), event[2].(string)})
+	}
+
+	expectedEvents := [][]string{
+		{"o", "caf"},
+		{"o", "é\r\n"},
+		{"r", "100x30"},
+	}
+
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Fatalf(
+			"expected events to equal '%+v', got '%+v'",
+			expectedEvents,
+			events,
+		)
+	}
+
+	storedRecording, err := sessionRecorder.Get(recording.ID)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if storedRecording.ExitStatus != 2 || storedRecording.InProgress() ||
+		storedRecording.KeyFingerprint != recording.KeyFingerprint {
+
+		t.Fatalf("unexpected recording '%+v'", storedRecording)
+	}
+}
+
+func TestRecorderPruneAndDelete(t *testing.T) {
+	sessionRecorder := New(t.TempDir())
+	recordings := []*Recording{}
+
+	for i := 0; i < 3; i++ {
+		recording := &Recording{
+			Type:    RecordingTypeExec,
+			Command: "ls",
+		}
+
+		if err := sessionRecorder.Start(recording); err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+
+		recordings = append(recordings, recording)
+	}
+
+	// The oldest recording is in progress
+	sessionRecorder.End(recordings[1], 0)
+	sessionRecorder.End(recordings[2], 0)
+
+	if err := sessionRecorder.Prune(2); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	storedRecordings, err := sessionRecorder.List()
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	storedIDs := []string{}
+
+	for _, recording := range storedRecordings {
+		storedIDs = append(storedIDs, recording.ID)
+	}
+
+	expectedIDs := []string{recordings[0].ID, recordings[2].ID}
+
+	if !reflect.DeepEqual(storedIDs, expectedIDs) {
+		t.Fatalf(
+			"expected recordings IDs to equal '%+v', got '%+v'",
+			expectedIDs,
+			storedIDs,
+		)
+	}
+
+	err = sessionRecorder.Delete(recordings[0].ID)
+
+	if !errors.Is(err, ErrRecordingInProgress) {
+		t.Fatalf("expected in progress error, got '%+v'", err)
+	}
+
+	err = sessionRecorder.StreamCast(
+		context.Background(),
+		recordings[2].ID,
+		false,
+		func(data []byte) error { return nil },
+	)
+
+	if !errors.Is(err, ErrRecordingHasNoCast) {
+		t.Fatalf("expected no cast error, got '%+v'", err)
+	}
+
+	if err := sessionRecorder.Delete(recordings[2].ID); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	_, err = sessionRecorder.Get("../" + recordings[2].ID)
+
+	if !errors.Is(err, ErrRecordingNotFound) {
+		t.Fatalf("expected not found error, got '%+v'", err)
+	}
+}
+
+func TestRecorderEndInterrupted(t *testing.T) {
+	sessionRecorder := New(t.TempDir())
+
+	interruptedRecording := &Recording{
+		Type: RecordingTypePTY,
+		User: "eleven",
+	}
+
+	cast, err := sessionRecorder.StartPTY(interruptedRecording, 80, 24, nil)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	cast.Write([]byte("ls\r\n"))
+
+	if err := cast.Close(); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	endedRecording := &Recording{
+		Type:    RecordingTypeExec,
+		Command: "ls",
+	}
+
+	if err := sessionRecorder.Start(endedRecording); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if err := sessionRecorder.End(endedRecording, 2); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if err := sessionRecorder.EndInterrupted(); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	storedRecording, err := sessionRecorder.Get(interruptedRecording.ID)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if storedRecording.InProgress() || !storedRecording.Interrupted ||
+		storedRecording.ExitStatus != -1 ||
+		storedRecording.EndedAt.Before(storedRecording.StartedAt) {
+
+		t.Fatalf("expected recording to be interrupted, got '%+v'", storedRecording)
+	}
+
+	storedRecording, err = sessionRecorder.Get(endedRecording.ID)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if storedRecording.Interrupted || storedRecording.ExitStatus != 2 {
+		t.Fatalf("expected recording to be unchanged, got '%+v'", storedRecording)
+	}
+
+	// Could be pruned and deleted once ended
+	if err := sessionRecorder.Prune(0); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	storedRecordings, err := sessionRecorder.List()
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	if len(storedRecordings) != 0 {
+		t.Fatalf("expected no recordings, got '%+v'", storedRecordings)
+	}
+}
@@ -0,0 +1,74 @@
+package sshserver
+
+import (
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gliderlabs/ssh"
+)
+
+const agentForwardingSocketEnvVar = "SSH_AUTH_SOCK"
+
+// startAgentForwarding listens on a Unix socket owned by the passed
+// user and forwards its connections to the agent of the client
+// (see "auth-agent-req@openssh.com"). The returned function
+// must be called to stop the forwarding once the session ends.
+func startAgentForwarding(
+	sshSession ssh.Session,
+	user *user.User,
+) (string, func(), error) {
+
+	listener, err := ssh.NewAgentListener()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	socketPath := listener.Addr().String()
+	socketDirPath := filepath.Dir(socketPath)
+
+	stopForwarding := func() {
+		listener.Close()
+
+		if err := os.RemoveAll(socketDirPath); err != nil {
+			log.Printf(
+				"[SSH server] Error when removing agent socket directory: %v",
+				err,
+			)
+		}
+	}
+
+	userUID, err := strconv.Atoi(user.Uid)
+
+	if err != nil {
+		stopForwarding()
+		return "", nil, err
+	}
+
+	userGID, err := strconv.Atoi(user.Gid)
+
+	if err != nil {
+		stopForwarding()
+		return "", nil, err
+	}
+
+	// The directory is only accessible by its owner
+	for _, path := range []string{socketDirPath, socketPath} {
+		if err := os.Chown(path, userUID, userGID); err != nil {
+			stopForwarding()
+			return "", nil, err
+		}
+	}
+
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		stopForwarding()
+		return "", nil, err
+	}
+
+	go ssh.ForwardAgentConnections(listener, sshSession)
+
+	return socketPath, stopForwarding, nil
+}
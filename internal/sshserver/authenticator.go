@@ -1,7 +1,13 @@
 package sshserver
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"os"
+	"time"
 
 	"github.com/gliderlabs/ssh"
 	gossh "golang.org/x/crypto/ssh"
@@ -10,11 +16,28 @@ import (
 type AuthorizedUser struct {
 	UserName               string
 	AuthorizedKeysFilePath string
+	// Public keys (in "authorized_keys" format) of the
+	// CAs trusted to sign the certificates of the user
+	TrustedUserCAKeysFilePath string
+	// Patterns (like "LC_*") of the environment
+	// variables that clients could pass to sessions
+	AcceptedEnv []string
+	// "<host>:<port>" patterns (with "*" as wildcard host
+	// or port) of the destinations of local port forwarding.
+	// Host names are not resolved.
+	AllowedLocalForwards []string
+	// "<host>:<port>" patterns of the addresses
+	// that could be bound by reverse port forwarding
+	AllowedReverseForwards []string
+	// Patterns (see "path.Match") of the Unix sockets that could
+	// be forwarded to. Symbolic links are resolved before matching.
+	AllowedSocketPaths []string
 }
 
 type authenticator struct {
-	hostKeyFilePath string
-	authorizedUsers []AuthorizedUser
+	hostKeyFilePath     string
+	authorizedUsers     []AuthorizedUser
+	authorizedKeysCache *authorizedKeysCache
 }
 
 func newAuthenticator(
@@ -23,94 +46,243 @@ func newAuthenticator(
 ) *authenticator {
 
 	return &authenticator{
-		hostKeyFilePath: hostKeyFilePath,
-		authorizedUsers: authorizedUsers,
+		hostKeyFilePath:     hostKeyFilePath,
+		authorizedUsers:     authorizedUsers,
+		authorizedKeysCache: newAuthorizedKeysCache(),
 	}
 }
 
+// buildHostKeySigner loads the host key once on startup.
+// Errors contain the path of the key given that
+// the server could not be started without it.
 func (a *authenticator) buildHostKeySigner() (ssh.Signer, error) {
 	hostKey, err := os.ReadFile(a.hostKeyFilePath)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf(
+			"error when reading host key \"%s\": %v",
+			a.hostKeyFilePath,
+			err,
+		)
+	}
+
+	hostKeySigner, err := gossh.ParsePrivateKey(hostKey)
+
+	var passphraseMissingErr *gossh.PassphraseMissingError
+
+	if errors.As(err, &passphraseMissingErr) {
+		return nil, fmt.Errorf(
+			"host key \"%s\" must not be protected by a passphrase",
+			a.hostKeyFilePath,
+		)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error when parsing host key \"%s\": %v",
+			a.hostKeyFilePath,
+			err,
+		)
 	}
 
-	return gossh.ParsePrivateKey(hostKey)
+	return hostKeySigner, nil
 }
 
 func (a *authenticator) doesPublicKeyAuthorizedForUser(
 	username string,
 	publicKey ssh.PublicKey,
+	remoteAddr net.Addr,
 ) (bool, error) {
 
-	authorizedKeys, err := a.lookupAuthorizedKeysForUser(
-		username,
-	)
+	authorizedKey, err := a.authorizePublicKey(username, publicKey, remoteAddr)
 
 	if err != nil {
 		return false, err
 	}
 
-	if authorizedKeys == nil {
-		return false, nil
-	}
+	return authorizedKey != nil, nil
+}
 
-	for _, authorizedKey := range authorizedKeys {
-		if ssh.KeysEqual(publicKey, authorizedKey) {
-			return true, nil
+// authorizePublicKey returns the authorized key (with its options)
+// that matches the passed public key or nil if the key is not
+// authorized (or if its options prevent it from being used).
+func (a *authenticator) authorizePublicKey(
+	username string,
+	publicKey ssh.PublicKey,
+	remoteAddr net.Addr,
+) (*authorizedKey, error) {
+
+	if cert, isCert := publicKey.(*gossh.Certificate); isCert {
+		certAuthorized, err := a.isCertificateAuthorizedForUser(
+			username,
+			cert,
+			remoteAddr,
+		)
+
+		if err != nil || !certAuthorized {
+			return nil, err
 		}
+
+		return &authorizedKey{
+			publicKey: publicKey,
+			options:   buildCertificateOptions(cert),
+		}, nil
 	}
 
-	return false, nil
-}
+	authorizedKeys, err := a.lookupAuthorizedKeysForUser(
+		username,
+	)
 
-func (a *authenticator) lookupAuthorizedKeysForUser(
-	username string,
-) ([]ssh.PublicKey, error) {
+	if err != nil {
+		return nil, err
+	}
 
-	for _, authorizedUser := range a.authorizedUsers {
-		if authorizedUser.UserName != username {
+	for _, authorizedKey := range authorizedKeys {
+		if !ssh.KeysEqual(publicKey, authorizedKey.publicKey) {
 			continue
 		}
 
-		authorizedKeysBytes, err := os.ReadFile(
-			authorizedUser.AuthorizedKeysFilePath,
+		err := checkAuthorizedKeyOptions(
+			authorizedKey.options,
+			remoteAddr,
+			time.Now(),
 		)
 
 		if err != nil {
-			return nil, err
+			log.Printf(
+				"[SSH server] Key \"%s\" rejected for user \"%s\": %v",
+				gossh.FingerprintSHA256(publicKey),
+				username,
+				err,
+			)
+
+			// The same key may be listed
+			// multiple times with other options
+			continue
 		}
 
-		authorizedKeys, err := parseAuthorizedKeys(
-			authorizedKeysBytes,
-		)
+		return authorizedKey, nil
+	}
 
-		if err != nil {
-			return nil, err
+	return nil, nil
+}
+
+// lookupAuthorizedUser returns nil if
+// the passed user is not authorized
+func (a *authenticator) lookupAuthorizedUser(username string) *AuthorizedUser {
+	for index, authorizedUser := range a.authorizedUsers {
+		if authorizedUser.UserName == username {
+			return &a.authorizedUsers[index]
 		}
+	}
+
+	return nil
+}
+
+func (a *authenticator) lookupAuthorizedKeysForUser(
+	username string,
+) ([]*authorizedKey, error) {
 
-		return authorizedKeys, nil
+	authorizedUser := a.lookupAuthorizedUser(username)
+
+	if authorizedUser == nil {
+		return nil, nil
 	}
 
-	return nil, nil
+	return a.authorizedKeysCache.get(authorizedUser.AuthorizedKeysFilePath)
+}
+
+// AuthorizedKeysLineError represents an
+// invalid line of an "authorized_keys" file.
+type AuthorizedKeysLineError struct {
+	// Starts at 1
+	Line int
+	Err  error
+}
+
+func (e *AuthorizedKeysLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// ValidAuthorizedKey represents a valid
+// line of an "authorized_keys" file.
+type ValidAuthorizedKey struct {
+	// Starts at 1
+	Line        int
+	Fingerprint string
+	Comment     string
+}
+
+// ValidateAuthorizedKeys returns the valid keys and the invalid lines
+// of the passed content (in "authorized_keys" format). It is used
+// to check a file before installing it.
+func ValidateAuthorizedKeys(
+	authorizedKeysBytes []byte,
+) ([]*ValidAuthorizedKey, []*AuthorizedKeysLineError) {
+
+	authorizedKeys, lineErrors := parseAuthorizedKeys(authorizedKeysBytes)
+	validKeys := []*ValidAuthorizedKey{}
+
+	for _, authorizedKey := range authorizedKeys {
+		validKeys = append(validKeys, &ValidAuthorizedKey{
+			Line:        authorizedKey.line,
+			Fingerprint: gossh.FingerprintSHA256(authorizedKey.publicKey),
+			Comment:     authorizedKey.comment,
+		})
+	}
+
+	return validKeys, lineErrors
 }
 
+// parseAuthorizedKeys skips the invalid lines so that a
+// malformed line does not prevent the other keys from being used.
+// The invalid lines are returned as errors.
 func parseAuthorizedKeys(
 	authorizedKeysBytes []byte,
-) ([]ssh.PublicKey, error) {
+) ([]*authorizedKey, []*AuthorizedKeysLineError) {
+
+	authorizedKeys := []*authorizedKey{}
+	lineErrors := []*AuthorizedKeysLineError{}
+
+	lines := bytes.Split(authorizedKeysBytes, []byte("\n"))
+
+	for lineIndex, line := range lines {
+		line = bytes.TrimSpace(line)
+
+		// Empty line or comment
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		pubKey, comment, options, _, err := ssh.ParseAuthorizedKey(line)
+
+		if err != nil {
+			lineErrors = append(lineErrors, &AuthorizedKeysLineError{
+				Line: lineIndex + 1,
+				Err:  err,
+			})
 
-	authorizedKeys := []ssh.PublicKey{}
+			continue
+		}
 
-	for len(authorizedKeysBytes) > 0 {
-		pubKey, _, _, rest, err := ssh.ParseAuthorizedKey(authorizedKeysBytes)
+		keyOptions, err := parseAuthorizedKeyOptions(options)
 
 		if err != nil {
-			return nil, err
+			lineErrors = append(lineErrors, &AuthorizedKeysLineError{
+				Line: lineIndex + 1,
+				Err:  err,
+			})
+
+			continue
 		}
 
-		authorizedKeys = append(authorizedKeys, pubKey)
-		authorizedKeysBytes = rest
+		authorizedKeys = append(authorizedKeys, &authorizedKey{
+			publicKey: pubKey,
+			options:   keyOptions,
+			line:      lineIndex + 1,
+			comment:   comment,
+		})
 	}
 
-	return authorizedKeys, nil
+	return authorizedKeys, lineErrors
 }
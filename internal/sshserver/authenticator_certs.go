@@ -0,0 +1,210 @@
+package sshserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const sourceAddressCriticalOption = "source-address"
+
+// Like OpenSSH, certificates only permit
+// the features listed in their extensions
+const (
+	permitPTYExtension             = "permit-pty"
+	permitPortForwardingExtension  = "permit-port-forwarding"
+	permitAgentForwardingExtension = "permit-agent-forwarding"
+)
+
+// isCertificateAuthorizedForUser validates the passed certificate
+// against the CAs trusted for the passed user. The reason of
+// the rejection is logged when the certificate is not authorized.
+func (a *authenticator) isCertificateAuthorizedForUser(
+	username string,
+	cert *gossh.Certificate,
+	remoteAddr net.Addr,
+) (bool, error) {
+
+	authorizedUser := a.lookupAuthorizedUser(username)
+
+	if authorizedUser == nil ||
+		len(authorizedUser.TrustedUserCAKeysFilePath) == 0 {
+
+		logRejectedCertificate(
+			username,
+			cert,
+			errors.New("no CA trusted for user"),
+		)
+
+		return false, nil
+	}
+
+	trustedCAKeys, err := a.authorizedKeysCache.get(
+		authorizedUser.TrustedUserCAKeysFilePath,
+	)
+
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		logRejectedCertificate(
+			username,
+			cert,
+			errors.New("no CA trusted for user"),
+		)
+
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	trustedCAPublicKeys := []ssh.PublicKey{}
+
+	for _, trustedCAKey := range trustedCAKeys {
+		trustedCAPublicKeys = append(trustedCAPublicKeys, trustedCAKey.publicKey)
+	}
+
+	err = checkUserCertificate(
+		username,
+		cert,
+		trustedCAPublicKeys,
+		remoteAddr,
+		time.Now(),
+	)
+
+	if err != nil {
+		logRejectedCertificate(username, cert, err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// buildCertificateOptions maps the extensions of the
+// passed certificate to the authorized keys options
+func buildCertificateOptions(cert *gossh.Certificate) *authorizedKeyOptions {
+	hasExtension := func(name string) bool {
+		_, hasExtension := cert.Extensions[name]
+		return hasExtension
+	}
+
+	return &authorizedKeyOptions{
+		noPTY:             !hasExtension(permitPTYExtension),
+		noPortForwarding:  !hasExtension(permitPortForwardingExtension),
+		noAgentForwarding: !hasExtension(permitAgentForwardingExtension),
+	}
+}
+
+// checkUserCertificate checks that the passed certificate is a user
+// certificate signed by one of the passed CAs, valid at the passed
+// time, for the passed user (principal) and from the passed address.
+// Only the "source-address" critical option is supported.
+func checkUserCertificate(
+	username string,
+	cert *gossh.Certificate,
+	trustedCAKeys []ssh.PublicKey,
+	remoteAddr net.Addr,
+	now time.Time,
+) error {
+
+	if cert.CertType != gossh.UserCert {
+		return errors.New("not a user certificate")
+	}
+
+	// Like OpenSSH, certificates without
+	// principals are not valid for any user
+	if len(cert.ValidPrincipals) == 0 {
+		return errors.New("certificate has no principals")
+	}
+
+	certChecker := &gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			for _, trustedCAKey := range trustedCAKeys {
+				if ssh.KeysEqual(auth, trustedCAKey) {
+					return true
+				}
+			}
+
+			return false
+		},
+
+		Clock: func() time.Time {
+			return now
+		},
+	}
+
+	if !certChecker.IsUserAuthority(cert.SignatureKey) {
+		return errors.New("certificate not signed by a trusted CA")
+	}
+
+	// Checks the principals, the validity window,
+	// the critical options and the signature
+	if err := certChecker.CheckCert(username, cert); err != nil {
+		return err
+	}
+
+	sourceAddrs, hasSourceAddrs := cert.CriticalOptions[sourceAddressCriticalOption]
+
+	if hasSourceAddrs {
+		return checkSourceAddress(remoteAddr, sourceAddrs)
+	}
+
+	return nil
+}
+
+// checkSourceAddress checks that the passed address matches one
+// of the passed comma-separated addresses or CIDR ranges
+func checkSourceAddress(remoteAddr net.Addr, sourceAddrs string) error {
+	tcpAddr, isTCPAddr := remoteAddr.(*net.TCPAddr)
+
+	if !isTCPAddr {
+		return fmt.Errorf("unsupported remote address \"%v\"", remoteAddr)
+	}
+
+	for _, sourceAddr := range strings.Split(sourceAddrs, ",") {
+		if IP := net.ParseIP(sourceAddr); IP != nil {
+			if IP.Equal(tcpAddr.IP) {
+				return nil
+			}
+
+			continue
+		}
+
+		_, IPNet, err := net.ParseCIDR(sourceAddr)
+
+		if err != nil {
+			return fmt.Errorf("invalid source address \"%s\"", sourceAddr)
+		}
+
+		if IPNet.Contains(tcpAddr.IP) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"address \"%s\" not allowed by \"%s\"",
+		tcpAddr.IP,
+		sourceAddrs,
+	)
+}
+
+func logRejectedCertificate(
+	username string,
+	cert *gossh.Certificate,
+	reason error,
+) {
+
+	log.Printf(
+		"[SSH server] Certificate \"%s\" (serial %d) rejected for user \"%s\": %v",
+		cert.KeyId,
+		cert.Serial,
+		username,
+		reason,
+	)
+}
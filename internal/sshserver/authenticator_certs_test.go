@@ -0,0 +1,277 @@
+package sshserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestCheckUserCertificate(t *testing.T) {
+	now := time.Now()
+
+	trustedCASigner := generateTestSigner(t)
+	untrustedCASigner := generateTestSigner(t)
+	userSigner := generateTestSigner(t)
+
+	buildCert := func(
+		signer gossh.Signer,
+		update func(cert *gossh.Certificate),
+	) *gossh.Certificate {
+
+		cert := &gossh.Certificate{
+			Key:             userSigner.PublicKey(),
+			CertType:        gossh.UserCert,
+			KeyId:           "jeremy@org",
+			ValidPrincipals: []string{"eleven"},
+			ValidAfter:      uint64(now.Add(-time.Hour).Unix()),
+			ValidBefore:     uint64(now.Add(time.Hour).Unix()),
+		}
+
+		if update != nil {
+			update(cert)
+		}
+
+		if err := cert.SignCert(rand.Reader, signer); err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+
+		return cert
+	}
+
+	testCases := []struct {
+		test          string
+		cert          *gossh.Certificate
+		expectedValid bool
+	}{
+		{
+			test:          "with valid certificate",
+			cert:          buildCert(trustedCASigner, nil),
+			expectedValid: true,
+		},
+
+		{
+			test: "with valid certificate from allowed address",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.CriticalOptions = map[string]string{
+					sourceAddressCriticalOption: "10.0.0.0/8,127.0.0.1",
+				}
+			}),
+			expectedValid: true,
+		},
+
+		{
+			test: "with certificate from not allowed address",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.CriticalOptions = map[string]string{
+					sourceAddressCriticalOption: "10.0.0.0/8",
+				}
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test: "with expired certificate",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.ValidBefore = uint64(now.Add(-time.Minute).Unix())
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test: "with not yet valid certificate",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.ValidAfter = uint64(now.Add(time.Minute).Unix())
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test: "with certificate for another principal",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.ValidPrincipals = []string{"root"}
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test: "with certificate without principals",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.ValidPrincipals = nil
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test: "with unsupported critical option",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.CriticalOptions = map[string]string{
+					"force-command": "ls",
+				}
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test: "with host certificate",
+			cert: buildCert(trustedCASigner, func(cert *gossh.Certificate) {
+				cert.CertType = gossh.HostCert
+			}),
+			expectedValid: false,
+		},
+
+		{
+			test:          "with certificate signed by untrusted CA",
+			cert:          buildCert(untrustedCASigner, nil),
+			expectedValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			err := checkUserCertificate(
+				"eleven",
+				tc.cert,
+				[]ssh.PublicKey{trustedCASigner.PublicKey()},
+				&net.TCPAddr{IP: net.ParseIP("127.0.0.1")},
+				now,
+			)
+
+			if tc.expectedValid && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !tc.expectedValid && err == nil {
+				t.Fatalf("expected error, got nothing")
+			}
+		})
+	}
+}
+
+func TestAuthorizePublicKeyWithCertificateExtensions(t *testing.T) {
+	caSigner := generateTestSigner(t)
+	userSigner := generateTestSigner(t)
+
+	trustedCAKeysFilePath := filepath.Join(t.TempDir(), "trusted_user_ca_keys")
+
+	err := os.WriteFile(
+		trustedCAKeysFilePath,
+		gossh.MarshalAuthorizedKey(caSigner.PublicKey()),
+		0600,
+	)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	auth := newAuthenticator("", []AuthorizedUser{
+		{
+			UserName:                  "eleven",
+			AuthorizedKeysFilePath:    "./testdata/empty_authorized_keys",
+			TrustedUserCAKeysFilePath: trustedCAKeysFilePath,
+		},
+	})
+
+	testCases := []struct {
+		test            string
+		extensions      map[string]string
+		expectedOptions authorizedKeyOptions
+	}{
+		{
+			test:       "with no extensions",
+			extensions: nil,
+			expectedOptions: authorizedKeyOptions{
+				noPTY:             true,
+				noPortForwarding:  true,
+				noAgentForwarding: true,
+			},
+		},
+
+		{
+			test: "with PTY extension",
+			extensions: map[string]string{
+				permitPTYExtension: "",
+			},
+			expectedOptions: authorizedKeyOptions{
+				noPTY:             false,
+				noPortForwarding:  true,
+				noAgentForwarding: true,
+			},
+		},
+
+		{
+			test: "with all extensions",
+			extensions: map[string]string{
+				permitPTYExtension:             "",
+				permitPortForwardingExtension:  "",
+				permitAgentForwardingExtension: "",
+				"permit-X11-forwarding":        "",
+			},
+			expectedOptions: authorizedKeyOptions{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			cert := &gossh.Certificate{
+				Key:             userSigner.PublicKey(),
+				CertType:        gossh.UserCert,
+				KeyId:           "jeremy@org",
+				ValidPrincipals: []string{"eleven"},
+				ValidBefore:     gossh.CertTimeInfinity,
+				Permissions: gossh.Permissions{
+					Extensions: tc.extensions,
+				},
+			}
+
+			if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			authorizedKey, err := auth.authorizePublicKey(
+				"eleven",
+				cert,
+				&net.TCPAddr{IP: net.ParseIP("127.0.0.1")},
+			)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if authorizedKey == nil {
+				t.Fatalf("expected certificate to be authorized")
+			}
+
+			if !reflect.DeepEqual(*authorizedKey.options, tc.expectedOptions) {
+				t.Fatalf(
+					"expected options to equal '%+v', got '%+v'",
+					tc.expectedOptions,
+					*authorizedKey.options,
+				)
+			}
+		})
+	}
+}
+
+func generateTestSigner(t *testing.T) gossh.Signer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(privateKey)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	return signer
+}
@@ -1,6 +1,14 @@
 package sshserver
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"golang.org/x/crypto/ssh"
@@ -45,6 +53,19 @@ func TestDoesPublicKeyAuthorizedForUser(t *testing.T) {
 			expectedResponse: true,
 		},
 
+		{
+			test: "with public key authorized after invalid lines",
+			authorizedUsers: []AuthorizedUser{
+				{
+					UserName:               "jeremy",
+					AuthorizedKeysFilePath: "./testdata/invalid_lines_authorized_keys",
+				},
+			},
+			username:         "jeremy",
+			publicKey:        "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJqmVkvKmywIYkfXOWWPya3I1zAbWGwOGu9Q870Zh49v jeremylevy@macbook-pro-de-jeremy.home",
+			expectedResponse: true,
+		},
+
 		{
 			test: "with unauthorized user",
 			authorizedUsers: []AuthorizedUser{
@@ -90,6 +111,7 @@ func TestDoesPublicKeyAuthorizedForUser(t *testing.T) {
 			publicKeyAuthorized, err := auth.doesPublicKeyAuthorizedForUser(
 				tc.username,
 				publicKey,
+				&net.TCPAddr{IP: net.ParseIP("127.0.0.1")},
 			)
 
 			if err != nil {
@@ -106,3 +128,87 @@ func TestDoesPublicKeyAuthorizedForUser(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildHostKeySigner(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	encodedPrivateKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	hostKeysDirPath := t.TempDir()
+
+	hostKeys := map[string][]byte{
+		"host-key": pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: encodedPrivateKey,
+		}),
+		"invalid-host-key": []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJqm"),
+	}
+
+	for fileName, content := range hostKeys {
+		err := os.WriteFile(filepath.Join(hostKeysDirPath, fileName), content, 0600)
+
+		if err != nil {
+			t.Fatalf("expected no error, got '%+v'", err)
+		}
+	}
+
+	testCases := []struct {
+		test          string
+		hostKeyFile   string
+		expectedError bool
+	}{
+		{
+			test:        "with valid host key",
+			hostKeyFile: "host-key",
+		},
+
+		{
+			test:          "with invalid host key",
+			hostKeyFile:   "invalid-host-key",
+			expectedError: true,
+		},
+
+		{
+			test:          "with not existing host key",
+			hostKeyFile:   "not-existing-host-key",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			hostKeyFilePath := filepath.Join(hostKeysDirPath, tc.hostKeyFile)
+			auth := newAuthenticator(hostKeyFilePath, nil)
+
+			hostKeySigner, err := auth.buildHostKeySigner()
+
+			if tc.expectedError {
+				if err == nil || !strings.Contains(err.Error(), hostKeyFilePath) {
+					t.Fatalf("expected error with host key path, got '%+v'", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if hostKeySigner.PublicKey().Type() != ssh.KeyAlgoED25519 {
+				t.Fatalf(
+					"expected host key type to equal '%s', got '%s'",
+					ssh.KeyAlgoED25519,
+					hostKeySigner.PublicKey().Type(),
+				)
+			}
+		})
+	}
+}
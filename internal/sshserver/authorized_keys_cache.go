@@ -0,0 +1,249 @@
+package sshserver
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Events that could change the content of a file. The
+// directories are watched given that files are often
+// replaced (or created) rather than written in place.
+const authorizedKeysWatchMask = unix.IN_CLOSE_WRITE |
+	unix.IN_CREATE |
+	unix.IN_DELETE |
+	unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO
+
+type authorizedKeysFile struct {
+	keys []*authorizedKey
+	// Set if the file could not be read
+	err error
+}
+
+// authorizedKeysCache caches the parsed "authorized_keys" files
+// and reloads them when they change. Files are read on each call
+// when they could not be watched (inotify not available,
+// directory not found...).
+type authorizedKeysCache struct {
+	lock sync.Mutex
+	// Keyed by absolute file path
+	files map[string]*authorizedKeysFile
+	// -1 if inotify is not available
+	inotifyFD int
+	// Directory paths keyed by watch descriptor
+	watchedDirs map[int32]string
+	dirsWatches map[string]int32
+}
+
+func newAuthorizedKeysCache() *authorizedKeysCache {
+	cache := &authorizedKeysCache{
+		files:       map[string]*authorizedKeysFile{},
+		inotifyFD:   -1,
+		watchedDirs: map[int32]string{},
+		dirsWatches: map[string]int32{},
+	}
+
+	inotifyFD, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+
+	if err != nil {
+		log.Printf("[SSH server] Authorized keys will not be cached: %v", err)
+		return cache
+	}
+
+	cache.inotifyFD = inotifyFD
+
+	go cache.watch(inotifyFD)
+
+	return cache
+}
+
+// get returns the keys of the passed file. The invalid lines
+// are skipped and logged each time the file is loaded.
+func (c *authorizedKeysCache) get(filePath string) ([]*authorizedKey, error) {
+	if absFilePath, err := filepath.Abs(filePath); err == nil {
+		filePath = absFilePath
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if file, isCached := c.files[filePath]; isCached {
+		return file.keys, file.err
+	}
+
+	// Watched before being read so
+	// that no change could be missed
+	isWatched := c.watchDir(filepath.Dir(filePath))
+
+	file := loadAuthorizedKeysFile(filePath)
+
+	if isWatched {
+		c.files[filePath] = file
+	}
+
+	return file.keys, file.err
+}
+
+func (c *authorizedKeysCache) watchDir(dirPath string) bool {
+	if c.inotifyFD < 0 {
+		return false
+	}
+
+	if _, isWatched := c.dirsWatches[dirPath]; isWatched {
+		return true
+	}
+
+	watchDescriptor, err := unix.InotifyAddWatch(
+		c.inotifyFD,
+		dirPath,
+		authorizedKeysWatchMask,
+	)
+
+	if err != nil {
+		// The directory may be created later
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[SSH server] Error when watching \"%s\": %v", dirPath, err)
+		}
+
+		return false
+	}
+
+	c.watchedDirs[int32(watchDescriptor)] = dirPath
+	c.dirsWatches[dirPath] = int32(watchDescriptor)
+
+	return true
+}
+
+func (c *authorizedKeysCache) watch(inotifyFD int) {
+	// Large enough to read many events at once
+	buffer := make([]byte, 64*1024)
+
+	for {
+		n, err := unix.Read(inotifyFD, buffer)
+
+		if err != nil && errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			log.Printf("[SSH server] Authorized keys will not be cached: %v", err)
+			c.disable()
+			return
+		}
+
+		c.handleEvents(buffer[:n])
+	}
+}
+
+func (c *authorizedKeysCache) handleEvents(events []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(events); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&events[offset]))
+
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(event.Len)
+
+		if nameEnd > len(events) {
+			return
+		}
+
+		// Names are padded with null bytes
+		name := strings.TrimRight(string(events[nameStart:nameEnd]), "\x00")
+
+		c.handleEvent(event.Wd, event.Mask, name)
+
+		offset = nameEnd
+	}
+}
+
+func (c *authorizedKeysCache) handleEvent(
+	watchDescriptor int32,
+	mask uint32,
+	name string,
+) {
+
+	// Events were lost
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		for filePath := range c.files {
+			c.files[filePath] = loadAuthorizedKeysFile(filePath)
+		}
+
+		return
+	}
+
+	dirPath, isWatched := c.watchedDirs[watchDescriptor]
+
+	if !isWatched {
+		return
+	}
+
+	// Directory removed. The files will be read on
+	// each call until the directory could be watched again.
+	if mask&unix.IN_IGNORED != 0 {
+		delete(c.watchedDirs, watchDescriptor)
+		delete(c.dirsWatches, dirPath)
+
+		for filePath := range c.files {
+			if filepath.Dir(filePath) == dirPath {
+				delete(c.files, filePath)
+			}
+		}
+
+		return
+	}
+
+	filePath := filepath.Join(dirPath, name)
+
+	if _, isCached := c.files[filePath]; !isCached {
+		return
+	}
+
+	log.Printf("[SSH server] Reloading \"%s\"", filePath)
+
+	c.files[filePath] = loadAuthorizedKeysFile(filePath)
+}
+
+func (c *authorizedKeysCache) disable() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	unix.Close(c.inotifyFD)
+
+	c.inotifyFD = -1
+	c.files = map[string]*authorizedKeysFile{}
+	c.watchedDirs = map[int32]string{}
+	c.dirsWatches = map[string]int32{}
+}
+
+func loadAuthorizedKeysFile(filePath string) *authorizedKeysFile {
+	authorizedKeysBytes, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return &authorizedKeysFile{
+			err: err,
+		}
+	}
+
+	authorizedKeys, lineErrors := parseAuthorizedKeys(authorizedKeysBytes)
+
+	for _, lineError := range lineErrors {
+		log.Printf(
+			"[SSH server] Invalid key skipped in \"%s\": %v",
+			filePath,
+			lineError,
+		)
+	}
+
+	return &authorizedKeysFile{
+		keys: authorizedKeys,
+	}
+}
@@ -0,0 +1,142 @@
+package sshserver
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestValidateAuthorizedKeys(t *testing.T) {
+	publicKey := generateTestSigner(t).PublicKey()
+	authorizedKey := string(gossh.MarshalAuthorizedKey(publicKey))
+
+	testCases := []struct {
+		test                string
+		content             string
+		expectedValidLines  []int
+		expectedErrorsLines []int
+	}{
+		{
+			test:                "with empty content",
+			content:             "",
+			expectedValidLines:  []int{},
+			expectedErrorsLines: []int{},
+		},
+
+		{
+			test:                "with comments and empty lines",
+			content:             "# comment\n\n   \n" + authorizedKey,
+			expectedValidLines:  []int{4},
+			expectedErrorsLines: []int{},
+		},
+
+		{
+			test: "with invalid lines",
+			content: authorizedKey +
+				"ssh-ed25519 AAAA truncated@paste\n" +
+				`environment="NAME" ` + authorizedKey +
+				"no-pty " + authorizedKey,
+			expectedValidLines:  []int{1, 4},
+			expectedErrorsLines: []int{2, 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			validKeys, lineErrors := ValidateAuthorizedKeys([]byte(tc.content))
+
+			validLines := []int{}
+
+			for _, validKey := range validKeys {
+				validLines = append(validLines, validKey.Line)
+
+				if validKey.Fingerprint != gossh.FingerprintSHA256(publicKey) {
+					t.Fatalf(
+						"expected fingerprint to equal '%s', got '%s'",
+						gossh.FingerprintSHA256(publicKey),
+						validKey.Fingerprint,
+					)
+				}
+			}
+
+			errorsLines := []int{}
+
+			for _, lineError := range lineErrors {
+				errorsLines = append(errorsLines, lineError.Line)
+			}
+
+			if !reflect.DeepEqual(validLines, tc.expectedValidLines) {
+				t.Fatalf(
+					"expected valid lines to equal '%+v', got '%+v'",
+					tc.expectedValidLines,
+					validLines,
+				)
+			}
+
+			if !reflect.DeepEqual(errorsLines, tc.expectedErrorsLines) {
+				t.Fatalf(
+					"expected errors lines to equal '%+v', got '%+v'",
+					tc.expectedErrorsLines,
+					errorsLines,
+				)
+			}
+		})
+	}
+}
+
+func TestAuthorizedKeysCacheReloadsChangedFiles(t *testing.T) {
+	cache := newAuthorizedKeysCache()
+
+	if cache.inotifyFD < 0 {
+		t.Skip("inotify not available")
+	}
+
+	authorizedKeysFilePath := filepath.Join(t.TempDir(), "authorized_keys")
+
+	_, err := cache.get(authorizedKeysFilePath)
+
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got '%+v'", err)
+	}
+
+	publicKey := generateTestSigner(t).PublicKey()
+
+	err = os.WriteFile(
+		authorizedKeysFilePath,
+		gossh.MarshalAuthorizedKey(publicKey),
+		0600,
+	)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	// Reloaded asynchronously
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		authorizedKeys, err := cache.get(authorizedKeysFilePath)
+
+		if err == nil && len(authorizedKeys) == 1 {
+			if !reflect.DeepEqual(authorizedKeys[0].publicKey, publicKey) {
+				t.Fatalf(
+					"expected public key to equal '%+v', got '%+v'",
+					publicKey,
+					authorizedKeys[0].publicKey,
+				)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected file to be reloaded, got '%+v' '%+v'", authorizedKeys, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,272 @@
+package sshserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// Unsupported options are rejected given that ignoring one could grant more access
+type authorizedKeyOptions struct {
+	// Host names are not resolved
+	from              []string
+	command           string
+	noPortForwarding  bool
+	noAgentForwarding bool
+	noPTY             bool
+	// "<host>:<port>" with "*" as wildcard
+	permitOpen  []string
+	environment []sessionEnvVar
+	// Zero if the key never expires
+	expiryTime time.Time
+}
+
+type authorizedKey struct {
+	publicKey ssh.PublicKey
+	options   *authorizedKeyOptions
+	// Position in the "authorized_keys" file (starts at 1)
+	line    int
+	comment string
+}
+
+type sshContextKey struct {
+	name string
+}
+
+// Set by the public key handler
+var authorizedKeyContextKey = &sshContextKey{"authorized-key"}
+
+// Accepted given that their features are not supported
+var noopAuthorizedKeyOptions = map[string]bool{
+	"no-x11-forwarding": true,
+	"x11-forwarding":    true,
+	"no-user-rc":        true,
+	"user-rc":           true,
+}
+
+// Layouts of "expiry-time" values with or without trailing "Z"
+var expiryTimeLayouts = []string{
+	"20060102",
+	"200601021504",
+	"20060102150405",
+}
+
+func parseAuthorizedKeyOptions(options []string) (*authorizedKeyOptions, error) {
+	keyOptions := &authorizedKeyOptions{}
+
+	for _, option := range options {
+		name, value, hasValue := parseAuthorizedKeyOption(option)
+
+		if !hasValue {
+			switch strings.ToLower(name) {
+			// Applied in order so that "restrict,pty" enables the PTY
+			case "restrict":
+				keyOptions.noPortForwarding = true
+				keyOptions.noAgentForwarding = true
+				keyOptions.noPTY = true
+			case "no-port-forwarding":
+				keyOptions.noPortForwarding = true
+			case "port-forwarding":
+				keyOptions.noPortForwarding = false
+			case "no-agent-forwarding":
+				keyOptions.noAgentForwarding = true
+			case "agent-forwarding":
+				keyOptions.noAgentForwarding = false
+			case "no-pty":
+				keyOptions.noPTY = true
+			case "pty":
+				keyOptions.noPTY = false
+			default:
+				if !noopAuthorizedKeyOptions[strings.ToLower(name)] {
+					return nil, fmt.Errorf("unsupported option \"%s\"", name)
+				}
+			}
+
+			continue
+		}
+
+		switch strings.ToLower(name) {
+		case "from":
+			keyOptions.from = strings.Split(value, ",")
+		case "command":
+			keyOptions.command = value
+		case "permitopen":
+			keyOptions.permitOpen = append(keyOptions.permitOpen, value)
+		case "environment":
+			nameAndValue := strings.SplitN(value, "=", 2)
+
+			if len(nameAndValue) != 2 || len(nameAndValue[0]) == 0 {
+				return nil, fmt.Errorf("invalid environment \"%s\"", value)
+			}
+
+			if !isAllowedEnvVarName(nameAndValue[0]) {
+				return nil, fmt.Errorf(
+					"environment variable \"%s\" not allowed",
+					nameAndValue[0],
+				)
+			}
+
+			keyOptions.environment = append(keyOptions.environment, sessionEnvVar{
+				name:  nameAndValue[0],
+				value: nameAndValue[1],
+			})
+		case "expiry-time":
+			expiryTime, err := parseExpiryTime(value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			keyOptions.expiryTime = expiryTime
+		default:
+			return nil, fmt.Errorf("unsupported option \"%s\"", name)
+		}
+	}
+
+	return keyOptions, nil
+}
+
+// Options look like `name` or `name="value"`
+func parseAuthorizedKeyOption(option string) (string, string, bool) {
+	nameAndValue := strings.SplitN(option, "=", 2)
+
+	if len(nameAndValue) != 2 {
+		return option, "", false
+	}
+
+	value := nameAndValue[1]
+
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	}
+
+	return nameAndValue[0], value, true
+}
+
+// Values are in local time or in UTC when suffixed with "Z"
+func parseExpiryTime(value string) (time.Time, error) {
+	location := time.Local
+
+	if strings.HasSuffix(value, "Z") {
+		location = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	}
+
+	for _, layout := range expiryTimeLayouts {
+		if len(layout) != len(value) {
+			continue
+		}
+
+		return time.ParseInLocation(layout, value, location)
+	}
+
+	return time.Time{}, fmt.Errorf("invalid expiry time \"%s\"", value)
+}
+
+func checkAuthorizedKeyOptions(
+	options *authorizedKeyOptions,
+	remoteAddr net.Addr,
+	now time.Time,
+) error {
+
+	if !options.expiryTime.IsZero() && !now.Before(options.expiryTime) {
+		return fmt.Errorf("key expired at %s", options.expiryTime)
+	}
+
+	if len(options.from) == 0 {
+		return nil
+	}
+
+	remoteIP := getRemoteIP(remoteAddr)
+
+	if !matchAddrPatterns(remoteIP, options.from) {
+		return fmt.Errorf(
+			"address \"%s\" not allowed by \"%s\"",
+			remoteIP,
+			strings.Join(options.from, ","),
+		)
+	}
+
+	return nil
+}
+
+// Patterns could be CIDR ranges or IPs with "*" and "?" wildcards
+func matchAddrPatterns(IP net.IP, patterns []string) bool {
+	if IP == nil {
+		return false
+	}
+
+	matched := false
+
+	for _, pattern := range patterns {
+		isNegated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		if !matchAddrPattern(IP, pattern) {
+			continue
+		}
+
+		if isNegated {
+			return false
+		}
+
+		matched = true
+	}
+
+	return matched
+}
+
+func matchAddrPattern(IP net.IP, pattern string) bool {
+	if _, IPNet, err := net.ParseCIDR(pattern); err == nil {
+		return IPNet.Contains(IP)
+	}
+
+	matched, _ := path.Match(pattern, IP.String())
+	return matched
+}
+
+func isPermittedOpen(permitOpen []string, host string, port uint32) bool {
+	for _, permitted := range permitOpen {
+		permittedHost, permittedPort, err := net.SplitHostPort(permitted)
+
+		if err != nil {
+			continue
+		}
+
+		if permittedHost != "*" && !strings.EqualFold(permittedHost, host) {
+			continue
+		}
+
+		if permittedPort != "*" && permittedPort != strconv.Itoa(int(port)) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func getAuthorizedKeyOptions(ctx context.Context) *authorizedKeyOptions {
+	authorizedKey, hasAuthorizedKey := ctx.Value(authorizedKeyContextKey).(*authorizedKey)
+
+	if !hasAuthorizedKey || authorizedKey.options == nil {
+		return &authorizedKeyOptions{}
+	}
+
+	return authorizedKey.options
+}
+
+func getRemoteIP(remoteAddr net.Addr) net.IP {
+	if tcpAddr, isTCPAddr := remoteAddr.(*net.TCPAddr); isTCPAddr {
+		return tcpAddr.IP
+	}
+
+	return nil
+}
@@ -0,0 +1,271 @@
+package sshserver
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAuthorizedKeyOptions(t *testing.T) {
+	testCases := []struct {
+		test            string
+		options         []string
+		expectedOptions *authorizedKeyOptions
+		expectedError   bool
+	}{
+		{
+			test:            "with no options",
+			options:         []string{},
+			expectedOptions: &authorizedKeyOptions{},
+		},
+
+		{
+			test: "with supported options",
+			options: []string{
+				`from="10.0.0.0/8,!10.0.0.1"`,
+				`command="echo \"hello\""`,
+				"no-port-forwarding",
+				"No-Pty",
+				`permitopen="localhost:8080"`,
+				`permitopen="*:3000"`,
+				`environment="NAME=value=1"`,
+				`expiry-time="20300102Z"`,
+			},
+			expectedOptions: &authorizedKeyOptions{
+				from:             []string{"10.0.0.0/8", "!10.0.0.1"},
+				command:          `echo "hello"`,
+				noPortForwarding: true,
+				noPTY:            true,
+				permitOpen:       []string{"localhost:8080", "*:3000"},
+				environment: []sessionEnvVar{
+					{name: "NAME", value: "value=1"},
+				},
+				expiryTime: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+
+		{
+			test:    "with restrict",
+			options: []string{"restrict"},
+			expectedOptions: &authorizedKeyOptions{
+				noPortForwarding:  true,
+				noAgentForwarding: true,
+				noPTY:             true,
+			},
+		},
+
+		{
+			test:    "with restrict and enabled PTY",
+			options: []string{"restrict", "pty", "no-user-rc"},
+			expectedOptions: &authorizedKeyOptions{
+				noPortForwarding:  true,
+				noAgentForwarding: true,
+			},
+		},
+
+		{
+			test:    "with no agent forwarding",
+			options: []string{"no-agent-forwarding", "no-X11-forwarding"},
+			expectedOptions: &authorizedKeyOptions{
+				noAgentForwarding: true,
+			},
+		},
+
+		{
+			test:          "with unsupported option",
+			options:       []string{"no-agent-forwarding", `tunnel="0"`},
+			expectedError: true,
+		},
+
+		{
+			test:          "with unsupported option without value",
+			options:       []string{"cert-authority"},
+			expectedError: true,
+		},
+
+		{
+			test:          "with invalid environment",
+			options:       []string{`environment="NAME"`},
+			expectedError: true,
+		},
+
+		{
+			test:          "with environment name that looks like an option",
+			options:       []string{`environment="--user=root"`},
+			expectedError: true,
+		},
+
+		{
+			test:          "with denied environment",
+			options:       []string{`environment="LD_PRELOAD=/tmp/evil.so"`},
+			expectedError: true,
+		},
+
+		{
+			test:          "with reserved environment",
+			options:       []string{`environment="SSH_AUTH_SOCK=/tmp/agent.sock"`},
+			expectedError: true,
+		},
+
+		{
+			test:          "with invalid expiry time",
+			options:       []string{`expiry-time="2030"`},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			options, err := parseAuthorizedKeyOptions(tc.options)
+
+			if tc.expectedError && err == nil {
+				t.Fatalf("expected error, got nothing")
+			}
+
+			if !tc.expectedError && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !reflect.DeepEqual(options, tc.expectedOptions) {
+				t.Fatalf(
+					"expected options to equal '%+v', got '%+v'",
+					tc.expectedOptions,
+					options,
+				)
+			}
+		})
+	}
+}
+
+func TestCheckAuthorizedKeyOptions(t *testing.T) {
+	now := time.Now()
+	remoteAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 52000}
+
+	testCases := []struct {
+		test          string
+		options       *authorizedKeyOptions
+		expectedValid bool
+	}{
+		{
+			test:          "with no options",
+			options:       &authorizedKeyOptions{},
+			expectedValid: true,
+		},
+
+		{
+			test: "with not expired key",
+			options: &authorizedKeyOptions{
+				expiryTime: now.Add(time.Minute),
+			},
+			expectedValid: true,
+		},
+
+		{
+			test: "with expired key",
+			options: &authorizedKeyOptions{
+				expiryTime: now.Add(-time.Minute),
+			},
+			expectedValid: false,
+		},
+
+		{
+			test: "with allowed address",
+			options: &authorizedKeyOptions{
+				from: []string{"192.168.0.1", "10.0.0.0/8"},
+			},
+			expectedValid: true,
+		},
+
+		{
+			test: "with allowed address using wildcard",
+			options: &authorizedKeyOptions{
+				from: []string{"10.0.0.*"},
+			},
+			expectedValid: true,
+		},
+
+		{
+			test: "with negated address",
+			options: &authorizedKeyOptions{
+				from: []string{"10.0.0.0/8", "!10.0.0.2"},
+			},
+			expectedValid: false,
+		},
+
+		{
+			test: "with not allowed address",
+			options: &authorizedKeyOptions{
+				from: []string{"192.168.0.0/16"},
+			},
+			expectedValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			err := checkAuthorizedKeyOptions(tc.options, remoteAddr, now)
+
+			if tc.expectedValid && err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			if !tc.expectedValid && err == nil {
+				t.Fatalf("expected error, got nothing")
+			}
+		})
+	}
+}
+
+func TestIsPermittedOpen(t *testing.T) {
+	permitOpen := []string{"localhost:8080", "*:3000", "10.0.0.1:*"}
+
+	testCases := []struct {
+		test              string
+		host              string
+		port              uint32
+		expectedPermitted bool
+	}{
+		{
+			test:              "with permitted host and port",
+			host:              "LOCALHOST",
+			port:              8080,
+			expectedPermitted: true,
+		},
+
+		{
+			test:              "with permitted port on any host",
+			host:              "example.com",
+			port:              3000,
+			expectedPermitted: true,
+		},
+
+		{
+			test:              "with any port on permitted host",
+			host:              "10.0.0.1",
+			port:              22,
+			expectedPermitted: true,
+		},
+
+		{
+			test:              "with not permitted port",
+			host:              "localhost",
+			port:              8081,
+			expectedPermitted: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			permitted := isPermittedOpen(permitOpen, tc.host, tc.port)
+
+			if permitted != tc.expectedPermitted {
+				t.Fatalf(
+					"expected permitted to equal '%+v', got '%+v'",
+					tc.expectedPermitted,
+					permitted,
+				)
+			}
+		})
+	}
+}
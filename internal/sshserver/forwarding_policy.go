@@ -0,0 +1,108 @@
+package sshserver
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gliderlabs/ssh"
+)
+
+// Named in the logs of the
+// requests denied by users policies
+const forwardingPolicyName = "forwarding policy"
+
+// buildLocalPortForwardingCallback returns the callback that checks
+// the "direct-tcpip" requests against the options of the key
+// and the allowed local forwards of the user.
+func buildLocalPortForwardingCallback(
+	auth *authenticator,
+) ssh.LocalPortForwardingCallback {
+
+	return func(ctx ssh.Context, dhost string, dport uint32) bool {
+		keyOptions := getAuthorizedKeyOptions(ctx)
+		request := fmt.Sprintf(
+			"local port forwarding to %s",
+			net.JoinHostPort(dhost, strconv.Itoa(int(dport))),
+		)
+
+		if keyOptions.noPortForwarding {
+			logDeniedRequest(ctx, request, "no-port-forwarding")
+			return false
+		}
+
+		if len(keyOptions.permitOpen) > 0 &&
+			!isPermittedOpen(keyOptions.permitOpen, dhost, dport) {
+
+			logDeniedRequest(ctx, request, "permitopen")
+			return false
+		}
+
+		authorizedUser := auth.lookupAuthorizedUser(ctx.User())
+
+		if authorizedUser == nil ||
+			!isPermittedOpen(authorizedUser.AllowedLocalForwards, dhost, dport) {
+
+			logDeniedRequest(ctx, request, forwardingPolicyName)
+			return false
+		}
+
+		return true
+	}
+}
+
+// buildReversePortForwardingCallback returns the callback that checks
+// the "tcpip-forward" requests against the options of the key
+// and the allowed reverse forwards of the user.
+func buildReversePortForwardingCallback(
+	auth *authenticator,
+) ssh.ReversePortForwardingCallback {
+
+	return func(ctx ssh.Context, host string, port uint32) bool {
+		request := fmt.Sprintf(
+			"reverse port forwarding from %s",
+			net.JoinHostPort(host, strconv.Itoa(int(port))),
+		)
+
+		if getAuthorizedKeyOptions(ctx).noPortForwarding {
+			logDeniedRequest(ctx, request, "no-port-forwarding")
+			return false
+		}
+
+		authorizedUser := auth.lookupAuthorizedUser(ctx.User())
+
+		if authorizedUser == nil ||
+			!isPermittedOpen(authorizedUser.AllowedReverseForwards, host, port) {
+
+			logDeniedRequest(ctx, request, forwardingPolicyName)
+			return false
+		}
+
+		return true
+	}
+}
+
+// isSocketPathAllowed returns true if the passed Unix
+// socket could be forwarded to by the passed user. Symbolic
+// links must be resolved before the call and the resolved
+// path must be the one dialed so that the socket could not
+// be swapped by a link once checked.
+func isSocketPathAllowed(
+	authorizedUser *AuthorizedUser,
+	socketPath string,
+) bool {
+
+	if authorizedUser == nil || !filepath.IsAbs(socketPath) {
+		return false
+	}
+
+	for _, pattern := range authorizedUser.AllowedSocketPaths {
+		if matched, _ := path.Match(pattern, socketPath); matched {
+			return true
+		}
+	}
+
+	return false
+}
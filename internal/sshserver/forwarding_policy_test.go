@@ -0,0 +1,83 @@
+package sshserver
+
+import "testing"
+
+func TestIsSocketPathAllowed(t *testing.T) {
+	authorizedUser := &AuthorizedUser{
+		UserName: "eleven",
+		AllowedSocketPaths: []string{
+			"/eleven/agent/grpc-server.sock",
+			"/home/eleven/workspace/*.sock",
+		},
+	}
+
+	testCases := []struct {
+		test            string
+		authorizedUser  *AuthorizedUser
+		socketPath      string
+		expectedAllowed bool
+	}{
+		{
+			test:            "with allowed socket",
+			authorizedUser:  authorizedUser,
+			socketPath:      "/eleven/agent/grpc-server.sock",
+			expectedAllowed: true,
+		},
+
+		{
+			test:            "with socket matching pattern",
+			authorizedUser:  authorizedUser,
+			socketPath:      "/home/eleven/workspace/app.sock",
+			expectedAllowed: true,
+		},
+
+		{
+			test:            "with socket in sub directory of pattern",
+			authorizedUser:  authorizedUser,
+			socketPath:      "/home/eleven/workspace/app/app.sock",
+			expectedAllowed: false,
+		},
+
+		{
+			test:            "with not allowed socket",
+			authorizedUser:  authorizedUser,
+			socketPath:      "/var/run/docker.sock",
+			expectedAllowed: false,
+		},
+
+		{
+			test:            "with relative socket path",
+			authorizedUser:  authorizedUser,
+			socketPath:      "grpc-server.sock",
+			expectedAllowed: false,
+		},
+
+		{
+			test:            "with user without allowed sockets",
+			authorizedUser:  &AuthorizedUser{UserName: "root"},
+			socketPath:      "/eleven/agent/grpc-server.sock",
+			expectedAllowed: false,
+		},
+
+		{
+			test:            "with not authorized user",
+			authorizedUser:  nil,
+			socketPath:      "/eleven/agent/grpc-server.sock",
+			expectedAllowed: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			allowed := isSocketPathAllowed(tc.authorizedUser, tc.socketPath)
+
+			if allowed != tc.expectedAllowed {
+				t.Fatalf(
+					"expected allowed to equal '%v', got '%v'",
+					tc.expectedAllowed,
+					allowed,
+				)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package sshserver
 
 import (
+	"context"
 	"log"
 	"os/user"
 
 	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 func NewServer(
@@ -21,7 +23,11 @@ func NewServer(
 		return nil, err
 	}
 
+	// No session is in progress on startup
+	recoverSessionRecordings()
+
 	forwardHandler := &ssh.ForwardedTCPHandler{}
+	handleSession := buildSessionHandlerFunc(auth)
 
 	return &ssh.Server{
 		Addr: listenAddr,
@@ -29,9 +35,19 @@ func NewServer(
 		HostSigners: []ssh.Signer{hostKeySigner},
 
 		PublicKeyHandler: func(ctx ssh.Context, publicKey ssh.PublicKey) bool {
-			publicKeyAuthorized, err := auth.doesPublicKeyAuthorizedForUser(
+			// Authentication results are cached by key and the context
+			// is shared between attempts. Only one key could be accepted
+			// so that its options apply to the connection.
+			acceptedKey, hasAcceptedKey := ctx.Value(authorizedKeyContextKey).(*authorizedKey)
+
+			if hasAcceptedKey {
+				return ssh.KeysEqual(publicKey, acceptedKey.publicKey)
+			}
+
+			authorizedKey, err := auth.authorizePublicKey(
 				ctx.User(),
 				publicKey,
+				ctx.RemoteAddr(),
 			)
 
 			if err != nil {
@@ -43,36 +59,33 @@ func NewServer(
 				return false
 			}
 
-			return publicKeyAuthorized
-		},
-
-		Handler: func(sshSession ssh.Session) {
-			user, err := user.Lookup(sshSession.User())
-
-			if err != nil {
-				log.Printf(
-					"[SSH server] Error during user lookup: %v",
-					err,
-				)
-
-				sshSession.Close()
-				return
+			if authorizedKey == nil {
+				return false
 			}
 
-			sessionCmdBuilder := newSessionCmdBuilder(user)
+			ctx.SetValue(authorizedKeyContextKey, authorizedKey)
 
-			sessionHandler := newSessionHandler(sessionCmdBuilder)
-			sessionHandler.handle(sshSession)
+			return true
 		},
 
-		LocalPortForwardingCallback: ssh.LocalPortForwardingCallback(func(ctx ssh.Context, dhost string, dport uint32) bool {
-			return true
-		}),
+		PtyCallback: ssh.PtyCallback(func(ctx ssh.Context, pty ssh.Pty) bool {
+			if getAuthorizedKeyOptions(ctx).noPTY {
+				logDeniedRequest(ctx, "PTY", "no-pty")
+				return false
+			}
 
-		ReversePortForwardingCallback: ssh.ReversePortForwardingCallback(func(ctx ssh.Context, host string, port uint32) bool {
 			return true
 		}),
 
+		Handler: handleSession,
+
+		SubsystemHandlers: map[string]ssh.SubsystemHandler{
+			sftpSubsystem: ssh.SubsystemHandler(handleSession),
+		},
+
+		LocalPortForwardingCallback:   buildLocalPortForwardingCallback(auth),
+		ReversePortForwardingCallback: buildReversePortForwardingCallback(auth),
+
 		RequestHandlers: map[string]ssh.RequestHandler{
 			"tcpip-forward":        forwardHandler.HandleSSHRequest,
 			"cancel-tcpip-forward": forwardHandler.HandleSSHRequest,
@@ -81,7 +94,66 @@ func NewServer(
 		ChannelHandlers: map[string]ssh.ChannelHandler{
 			"direct-tcpip":                   ssh.DirectTCPIPHandler,
 			"session":                        ssh.DefaultSessionHandler,
-			"direct-streamlocal@openssh.com": handleDirectStreamLocalOpenSSH,
+			"direct-streamlocal@openssh.com": buildDirectStreamLocalOpenSSHHandler(auth),
 		},
 	}, nil
 }
+
+// buildSessionHandlerFunc returns the function that handles
+// the "shell", "exec" and "sftp" sessions as the user
+// that has authenticated.
+func buildSessionHandlerFunc(auth *authenticator) ssh.Handler {
+	return func(sshSession ssh.Session) {
+		user, err := user.Lookup(sshSession.User())
+
+		if err != nil {
+			log.Printf(
+				"[SSH server] Error during user lookup: %v",
+				err,
+			)
+
+			sshSession.Close()
+			return
+		}
+
+		sessionCmdBuilder := newSessionCmdBuilder(user)
+
+		if authorizedUser := auth.lookupAuthorizedUser(user.Username); authorizedUser != nil {
+			acceptedEnv := filterAcceptedEnv(
+				sshSession.Environ(),
+				authorizedUser.AcceptedEnv,
+			)
+
+			for _, envVar := range acceptedEnv {
+				sessionCmdBuilder.addEnv(envVar.name, envVar.value)
+			}
+		}
+
+		// Set after the accepted env to take precedence
+		for _, envVar := range getAuthorizedKeyOptions(sshSession.Context()).environment {
+			sessionCmdBuilder.addEnv(envVar.name, envVar.value)
+		}
+
+		sessionHandler := newSessionHandler(sessionCmdBuilder)
+		sessionHandler.handle(sshSession)
+	}
+}
+
+// logDeniedRequest logs the passed request with the key that
+// has authenticated and the option that has denied it
+func logDeniedRequest(ctx context.Context, request, deniedBy string) {
+	keyFingerprint := ""
+	user, _ := ctx.Value(ssh.ContextKeyUser).(string)
+
+	if authorizedKey, hasAuthorizedKey := ctx.Value(authorizedKeyContextKey).(*authorizedKey); hasAuthorizedKey {
+		keyFingerprint = gossh.FingerprintSHA256(authorizedKey.publicKey)
+	}
+
+	log.Printf(
+		"[SSH server] %s denied by \"%s\" for user \"%s\" (key \"%s\")",
+		request,
+		deniedBy,
+		user,
+		keyFingerprint,
+	)
+}
@@ -9,6 +9,9 @@ import (
 
 type sessionCmdBuilder struct {
 	user *user.User
+	// Passed to the commands as "<name>=<value>"
+	// given that "sudo" and "login" reset the environment
+	env []string
 }
 
 func newSessionCmdBuilder(
@@ -20,6 +23,10 @@ func newSessionCmdBuilder(
 	}
 }
 
+func (s *sessionCmdBuilder) addEnv(name, value string) {
+	s.env = append(s.env, name+"="+value)
+}
+
 func (s *sessionCmdBuilder) build(args ...string) *exec.Cmd {
 	cmdToBuildArgs := []string{
 		"--set-home",
@@ -28,6 +35,12 @@ func (s *sessionCmdBuilder) build(args ...string) *exec.Cmd {
 		s.user.Username,
 	}
 
+	// Must be passed before the command
+	cmdToBuildArgs = append(cmdToBuildArgs, s.env...)
+
+	// "sudo" stops parsing options and variables at "--"
+	cmdToBuildArgs = append(cmdToBuildArgs, "--")
+
 	return exec.Command("sudo", append(cmdToBuildArgs, args...)...)
 }
 
@@ -35,16 +48,44 @@ func (s *sessionCmdBuilder) buildShell() *exec.Cmd {
 	return s.build()
 }
 
+// buildShellPTY always preserves the environment set by "sudo"
+// so that shells get the same environment whether or not
+// variables are passed (eg: "SSH_AUTH_SOCK").
 func (s *sessionCmdBuilder) buildShellPTY() *exec.Cmd {
-	cmdToBuildArgs := []string{
+	cmdToBuildArgs := append([]string{}, s.env...)
+
+	cmdToBuildArgs = append(
+		cmdToBuildArgs,
+		"--",
 		"login",
+		"-p",
 		"-f",
 		s.user.Username,
-	}
+	)
 
 	return exec.Command("sudo", cmdToBuildArgs...)
 }
 
+// buildSFTPServer runs the OpenSSH SFTP server as the user so
+// that transfers respect its file permissions. Not run in a
+// login shell given that any output would break the protocol.
+// "-d" only sets the start directory: the user could still
+// access any file that its permissions allow.
+func (s *sessionCmdBuilder) buildSFTPServer() *exec.Cmd {
+	return exec.Command(
+		"sudo",
+		"--set-home",
+		"--user",
+		s.user.Username,
+		"--",
+		config.SFTPServerBinaryPath,
+		// Start directory (relative paths are
+		// resolved from it). Not a chroot.
+		"-d",
+		config.WorkspaceDirPath,
+	)
+}
+
 func (s *sessionCmdBuilder) buildExec(cmd string) *exec.Cmd {
 	return s.build(
 		config.ElevenUserShellPath,
@@ -0,0 +1,98 @@
+package sshserver
+
+import (
+	"os/user"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/config"
+)
+
+func TestSessionCmdBuilderEnv(t *testing.T) {
+	testCases := []struct {
+		test                 string
+		env                  map[string]string
+		expectedShellArgs    []string
+		expectedShellPTYArgs []string
+	}{
+		{
+			test:                 "without env",
+			expectedShellArgs:    []string{"sudo", "--set-home", "--login", "--user", "eleven", "--"},
+			expectedShellPTYArgs: []string{"sudo", "--", "login", "-p", "-f", "eleven"},
+		},
+
+		{
+			test: "with env",
+			env: map[string]string{
+				"SSH_AUTH_SOCK": "/tmp/auth-agent/listener.sock",
+			},
+			expectedShellArgs: []string{
+				"sudo", "--set-home", "--login", "--user", "eleven",
+				"SSH_AUTH_SOCK=/tmp/auth-agent/listener.sock",
+				"--",
+			},
+			expectedShellPTYArgs: []string{
+				"sudo",
+				"SSH_AUTH_SOCK=/tmp/auth-agent/listener.sock",
+				"--", "login", "-p", "-f", "eleven",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			cmdBuilder := newSessionCmdBuilder(&user.User{
+				Username: "eleven",
+			})
+
+			for name, value := range tc.env {
+				cmdBuilder.addEnv(name, value)
+			}
+
+			shellArgs := cmdBuilder.buildShell().Args
+
+			if !reflect.DeepEqual(shellArgs, tc.expectedShellArgs) {
+				t.Fatalf(
+					"expected shell args to equal '%+v', got '%+v'",
+					tc.expectedShellArgs,
+					shellArgs,
+				)
+			}
+
+			shellPTYArgs := cmdBuilder.buildShellPTY().Args
+
+			if !reflect.DeepEqual(shellPTYArgs, tc.expectedShellPTYArgs) {
+				t.Fatalf(
+					"expected shell PTY args to equal '%+v', got '%+v'",
+					tc.expectedShellPTYArgs,
+					shellPTYArgs,
+				)
+			}
+		})
+	}
+}
+
+func TestSessionCmdBuilderSFTPServer(t *testing.T) {
+	cmdBuilder := newSessionCmdBuilder(&user.User{
+		Username: "eleven",
+	})
+
+	// Not passed to the SFTP server
+	cmdBuilder.addEnv("SSH_AUTH_SOCK", "/tmp/auth-agent/listener.sock")
+
+	expectedArgs := []string{
+		"sudo", "--set-home", "--user", "eleven", "--",
+		config.SFTPServerBinaryPath,
+		"-d", "/home/eleven/workspace",
+	}
+
+	sftpServerArgs := cmdBuilder.buildSFTPServer().Args
+
+	if !reflect.DeepEqual(sftpServerArgs, expectedArgs) {
+		t.Fatalf(
+			"expected SFTP server args to equal '%+v', got '%+v'",
+			expectedArgs,
+			sftpServerArgs,
+		)
+	}
+}
@@ -0,0 +1,114 @@
+package sshserver
+
+import (
+	"log"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Set by the SSH server. Never accepted from clients.
+var reservedEnvVars = map[string]bool{
+	agentForwardingSocketEnvVar: true,
+	originalCommandEnvVar:       true,
+}
+
+// Names are passed to "sudo" as "<name>=<value>"
+// arguments so they must not look like options
+var envVarNameRegExp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Change how the programs run as root by "sudo" and "login"
+// are loaded or how the shells are started. Never accepted.
+var deniedEnvVars = map[string]bool{
+	"BASH_ENV":    true,
+	"BASHOPTS":    true,
+	"ENV":         true,
+	"GCONV_PATH":  true,
+	"HOSTALIASES": true,
+	"IFS":         true,
+	"LOCALDOMAIN": true,
+	"NLSPATH":     true,
+	"PS4":         true,
+	"RES_OPTIONS": true,
+	"SHELLOPTS":   true,
+	"ZDOTDIR":     true,
+}
+
+var deniedEnvVarPrefixes = []string{
+	"LD_",
+	"DYLD_",
+	"MALLOC_",
+}
+
+type sessionEnvVar struct {
+	name  string
+	value string
+}
+
+// filterAcceptedEnv returns the variables of the passed environment
+// ("<name>=<value>" list) whose name matches one of the passed
+// patterns. Patterns support the "*" and "?" wildcards.
+func filterAcceptedEnv(
+	environ []string,
+	acceptedPatterns []string,
+) []sessionEnvVar {
+
+	acceptedEnv := []sessionEnvVar{}
+
+	for _, envVar := range environ {
+		nameAndValue := strings.SplitN(envVar, "=", 2)
+
+		if len(nameAndValue) != 2 || len(nameAndValue[0]) == 0 {
+			continue
+		}
+
+		name := nameAndValue[0]
+
+		if !isAllowedEnvVarName(name) || !isAcceptedEnvVar(name, acceptedPatterns) {
+			log.Printf(
+				"[SSH server] Environment variable \"%s\" not accepted",
+				name,
+			)
+
+			continue
+		}
+
+		acceptedEnv = append(acceptedEnv, sessionEnvVar{
+			name:  name,
+			value: nameAndValue[1],
+		})
+	}
+
+	return acceptedEnv
+}
+
+func isAcceptedEnvVar(name string, acceptedPatterns []string) bool {
+	for _, pattern := range acceptedPatterns {
+		// Names cannot contain path separators
+		// so "path.Match" behaves like "fnmatch"
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isAllowedEnvVarName returns false for invalid names,
+// reserved variables and denied ones (eg: "LD_PRELOAD")
+func isAllowedEnvVarName(name string) bool {
+	if !envVarNameRegExp.MatchString(name) ||
+		reservedEnvVars[name] ||
+		deniedEnvVars[strings.ToUpper(name)] {
+
+		return false
+	}
+
+	for _, deniedPrefix := range deniedEnvVarPrefixes {
+		if strings.HasPrefix(strings.ToUpper(name), deniedPrefix) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,81 @@
+package sshserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterAcceptedEnv(t *testing.T) {
+	testCases := []struct {
+		test             string
+		environ          []string
+		acceptedPatterns []string
+		expectedEnv      []sessionEnvVar
+	}{
+		{
+			test:             "with exact and wildcard patterns",
+			environ:          []string{"LANG=en_US.UTF-8", "LC_ALL=C", "EDITOR=vim", "COLORTERM=truecolor"},
+			acceptedPatterns: []string{"LANG", "LC_*", "COLORTERM"},
+			expectedEnv: []sessionEnvVar{
+				{name: "LANG", value: "en_US.UTF-8"},
+				{name: "LC_ALL", value: "C"},
+				{name: "COLORTERM", value: "truecolor"},
+			},
+		},
+
+		{
+			test:             "with values containing equal signs",
+			environ:          []string{"MY_VAR=a=b"},
+			acceptedPatterns: []string{"MY_???"},
+			expectedEnv: []sessionEnvVar{
+				{name: "MY_VAR", value: "a=b"},
+			},
+		},
+
+		{
+			test:             "with reserved and invalid variables",
+			environ:          []string{"SSH_AUTH_SOCK=/tmp/sock", "=value", "NO_VALUE"},
+			acceptedPatterns: []string{"*"},
+			expectedEnv:      []sessionEnvVar{},
+		},
+
+		{
+			test: "with denied variables and names that are not valid",
+			environ: []string{
+				"LD_PRELOAD=/tmp/evil.so",
+				"ld_library_path=/tmp",
+				"BASH_ENV=/tmp/evil.sh",
+				"SSH_ORIGINAL_COMMAND=id",
+				"--user=root",
+				"1VAR=value",
+				"MY-VAR=value",
+				"EDITOR=vim",
+			},
+			acceptedPatterns: []string{"*"},
+			expectedEnv: []sessionEnvVar{
+				{name: "EDITOR", value: "vim"},
+			},
+		},
+
+		{
+			test:             "without accepted patterns",
+			environ:          []string{"LANG=en_US.UTF-8"},
+			acceptedPatterns: nil,
+			expectedEnv:      []sessionEnvVar{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			env := filterAcceptedEnv(tc.environ, tc.acceptedPatterns)
+
+			if !reflect.DeepEqual(env, tc.expectedEnv) {
+				t.Fatalf(
+					"expected env to equal '%+v', got '%+v'",
+					tc.expectedEnv,
+					env,
+				)
+			}
+		})
+	}
+}
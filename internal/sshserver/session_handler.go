@@ -12,9 +12,24 @@ import (
 	"github.com/creack/pty"
 	"github.com/eleven-sh/agent/config"
 	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/recorder"
 	"github.com/gliderlabs/ssh"
 )
 
+const sftpSubsystem = "sftp"
+
+const (
+	forcedCmdSessionType = "forced command"
+	sftpSessionType      = "sftp"
+	shellPTYSessionType  = "shell PTY"
+	shellSessionType     = "shell"
+	execSessionType      = "exec"
+)
+
+// Set to the command requested by the client
+// when a forced command is run instead
+const originalCommandEnvVar = "SSH_ORIGINAL_COMMAND"
+
 type sessionHandler struct {
 	cmdBuilder *sessionCmdBuilder
 }
@@ -45,20 +60,73 @@ func (s *sessionHandler) handle(sshSession ssh.Session) {
 		sshSession.Exit(0)
 	}()
 
-	if len(sshSession.Command()) == 0 { // "shell" session
-		_, _, hasPTY := sshSession.Pty()
+	agentForwardingRequested := ssh.AgentRequested(sshSession)
+
+	if agentForwardingRequested &&
+		getAuthorizedKeyOptions(sshSession.Context()).noAgentForwarding {
+
+		logDeniedRequest(sshSession.Context(), "agent forwarding", "no-agent-forwarding")
+		agentForwardingRequested = false
+	}
 
-		if hasPTY {
-			sessionError = s.handleShellPTY(sshSession)
+	if agentForwardingRequested {
+		agentSocketPath, stopAgentForwarding, err := startAgentForwarding(
+			sshSession,
+			s.cmdBuilder.user,
+		)
+
+		if err != nil {
+			sessionError = err
 			return
 		}
 
+		defer stopAgentForwarding()
+
+		s.cmdBuilder.addEnv(agentForwardingSocketEnvVar, agentSocketPath)
+	}
+
+	switch getSessionType(sshSession) {
+	case forcedCmdSessionType:
+		if originalCmd := sshSession.RawCommand(); len(originalCmd) > 0 {
+			s.cmdBuilder.addEnv(originalCommandEnvVar, originalCmd)
+		}
+
+		sessionError = s.handleExec(
+			sshSession,
+			getAuthorizedKeyOptions(sshSession.Context()).command,
+		)
+	case sftpSessionType:
+		sessionError = s.handleSFTP(sshSession)
+	case shellPTYSessionType:
+		sessionError = s.handleShellPTY(sshSession)
+	case shellSessionType:
 		sessionError = s.handleShell(sshSession)
-		return
+	default:
+		sessionError = s.handleExec(sshSession, sshSession.RawCommand())
+	}
+}
+
+// getSessionType returns the type of the passed session.
+// The forced command replaces the shell,
+// the command and the subsystem requested.
+func getSessionType(sshSession ssh.Session) string {
+	if len(getAuthorizedKeyOptions(sshSession.Context()).command) > 0 {
+		return forcedCmdSessionType
+	}
+
+	if sshSession.Subsystem() == sftpSubsystem {
+		return sftpSessionType
+	}
+
+	if len(sshSession.Command()) > 0 {
+		return execSessionType
 	}
 
-	// "exec" session
-	sessionError = s.handleExec(sshSession)
+	if _, _, hasPTY := sshSession.Pty(); hasPTY {
+		return shellPTYSessionType
+	}
+
+	return shellSessionType
 }
 
 func (s *sessionHandler) handleShell(sshSession ssh.Session) error {
@@ -203,6 +271,8 @@ func (s *sessionHandler) handleShellPTY(sshSession ssh.Session) error {
 		return err
 	}
 
+	recording := startSessionRecording(sshSession, recorder.RecordingTypePTY)
+
 	go func() {
 		for window := range windowChan {
 			setWindowSize(
@@ -210,6 +280,8 @@ func (s *sessionHandler) handleShellPTY(sshSession ssh.Session) error {
 				window.Width,
 				window.Height,
 			)
+
+			recording.resize(window)
 		}
 	}()
 
@@ -217,13 +289,18 @@ func (s *sessionHandler) handleShellPTY(sshSession ssh.Session) error {
 		io.Copy(shellCmdPty, sshSession) // stdin
 	}()
 
-	io.Copy(sshSession, shellCmdPty) // stdout
+	io.Copy(recording.output(sshSession), shellCmdPty) // stdout
 
-	return shellCmd.Wait()
+	err = shellCmd.Wait()
+	recording.end(err)
+
+	return err
 }
 
-func (s *sessionHandler) handleExec(sshSession ssh.Session) error {
-	passedCmd := sshSession.RawCommand()
+func (s *sessionHandler) handleExec(
+	sshSession ssh.Session,
+	passedCmd string,
+) error {
 
 	if len(passedCmd) == 0 {
 		return errors.New("expected command, got nothing")
@@ -241,15 +318,20 @@ func (s *sessionHandler) handleExec(sshSession ssh.Session) error {
 		return err
 	}
 
+	recording := startSessionRecording(sshSession, recorder.RecordingTypeExec)
+
 	// We use ".Process.Wait()" here given that
 	// ".Wait()" will wait indefinitly for "Stdin"
 	// (the SSH channel) to close before returning.
 	cmdState, err := cmdToExec.Process.Wait()
 
 	if err != nil {
+		recording.end(err)
 		return err
 	}
 
+	recording.endWithExitStatus(cmdState.ExitCode())
+
 	if cmdExitCode := cmdState.ExitCode(); cmdExitCode != 0 {
 		return fmt.Errorf(
 			"the command \"%s\" has returned a non-zero (%d) exit code",
@@ -260,3 +342,31 @@ func (s *sessionHandler) handleExec(sshSession ssh.Session) error {
 
 	return nil
 }
+
+func (s *sessionHandler) handleSFTP(sshSession ssh.Session) error {
+	sftpServerCmd := s.cmdBuilder.buildSFTPServer()
+
+	sftpServerCmd.Stdin = sshSession
+	sftpServerCmd.Stdout = sshSession
+	sftpServerCmd.Stderr = sshSession.Stderr()
+
+	if err := sftpServerCmd.Start(); err != nil {
+		return err
+	}
+
+	// See "handleExec()"
+	cmdState, err := sftpServerCmd.Process.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	if cmdExitCode := cmdState.ExitCode(); cmdExitCode != 0 {
+		return fmt.Errorf(
+			"the SFTP server has returned a non-zero (%d) exit code",
+			cmdExitCode,
+		)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"path/filepath"
 
 	"github.com/gliderlabs/ssh"
 	gossh "golang.org/x/crypto/ssh"
@@ -21,13 +22,24 @@ type directStreamLocalOpenSSHMsg struct {
 	Reserved1  uint32
 }
 
-// handleDirectStreamLocalOpenSSH is used to
-// forward local conn to a remote unix socket.
+// buildDirectStreamLocalOpenSSHHandler returns the handler used to
+// forward local conn to a remote unix socket allowed for the user.
 // Corresponds to the "direct-streamlocal@openssh.com" channel type.
 // Used by the Eleven CLI to reach the GRPC server unix socket.
+func buildDirectStreamLocalOpenSSHHandler(auth *authenticator) ssh.ChannelHandler {
+	return func(
+		srv *ssh.Server,
+		conn *gossh.ServerConn,
+		newChan gossh.NewChannel,
+		ctx ssh.Context,
+	) {
+
+		handleDirectStreamLocalOpenSSH(auth, newChan, ctx)
+	}
+}
+
 func handleDirectStreamLocalOpenSSH(
-	srv *ssh.Server,
-	conn *gossh.ServerConn,
+	auth *authenticator,
 	newChan gossh.NewChannel,
 	ctx ssh.Context,
 ) {
@@ -46,7 +58,33 @@ func handleDirectStreamLocalOpenSSH(
 		return
 	}
 
-	socketConn, err := net.Dial("unix", msg.SocketPath)
+	request := fmt.Sprintf("forwarding to socket %s", msg.SocketPath)
+
+	if getAuthorizedKeyOptions(ctx).noPortForwarding {
+		logDeniedRequest(ctx, request, "no-port-forwarding")
+		newChan.Reject(gossh.Prohibited, "port forwarding is disabled")
+		return
+	}
+
+	// Relative paths are denied by the policy
+	socketPath := msg.SocketPath
+
+	if filepath.IsAbs(socketPath) {
+		socketPath, err = filepath.EvalSymlinks(socketPath)
+
+		if err != nil {
+			newChan.Reject(gossh.ConnectionFailed, err.Error())
+			return
+		}
+	}
+
+	if !isSocketPathAllowed(auth.lookupAuthorizedUser(ctx.User()), socketPath) {
+		logDeniedRequest(ctx, request, forwardingPolicyName)
+		newChan.Reject(gossh.Prohibited, "socket forwarding is not allowed")
+		return
+	}
+
+	socketConn, err := net.Dial("unix", socketPath)
 
 	if err != nil {
 		newChan.Reject(gossh.ConnectionFailed, err.Error())
@@ -0,0 +1,142 @@
+package sshserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gliderlabs/ssh"
+)
+
+type fakeSession struct {
+	ssh.Session
+	ctx        context.Context
+	rawCommand string
+	command    []string
+	subsystem  string
+	hasPTY     bool
+}
+
+func (f *fakeSession) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeSession) RawCommand() string {
+	return f.rawCommand
+}
+
+func (f *fakeSession) Command() []string {
+	return f.command
+}
+
+func (f *fakeSession) Subsystem() string {
+	return f.subsystem
+}
+
+func (f *fakeSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	return ssh.Pty{}, nil, f.hasPTY
+}
+
+func TestGetSessionType(t *testing.T) {
+	forcedCmdCtx := context.WithValue(
+		context.Background(),
+		authorizedKeyContextKey,
+		&authorizedKey{
+			options: &authorizedKeyOptions{
+				command: "/usr/bin/backup",
+			},
+		},
+	)
+
+	testCases := []struct {
+		test         string
+		session      *fakeSession
+		expectedType string
+	}{
+		{
+			test:         "with shell",
+			session:      &fakeSession{ctx: context.Background()},
+			expectedType: shellSessionType,
+		},
+
+		{
+			test: "with shell and PTY",
+			session: &fakeSession{
+				ctx:    context.Background(),
+				hasPTY: true,
+			},
+			expectedType: shellPTYSessionType,
+		},
+
+		{
+			test: "with command",
+			session: &fakeSession{
+				ctx:        context.Background(),
+				rawCommand: "ls -la",
+				command:    []string{"ls", "-la"},
+			},
+			expectedType: execSessionType,
+		},
+
+		{
+			test: "with command and PTY",
+			session: &fakeSession{
+				ctx:        context.Background(),
+				rawCommand: "top",
+				command:    []string{"top"},
+				hasPTY:     true,
+			},
+			expectedType: execSessionType,
+		},
+
+		{
+			test: "with sftp subsystem",
+			session: &fakeSession{
+				ctx:       context.Background(),
+				subsystem: sftpSubsystem,
+			},
+			expectedType: sftpSessionType,
+		},
+
+		{
+			test: "with forced command and shell",
+			session: &fakeSession{
+				ctx:    forcedCmdCtx,
+				hasPTY: true,
+			},
+			expectedType: forcedCmdSessionType,
+		},
+
+		{
+			test: "with forced command and command",
+			session: &fakeSession{
+				ctx:        forcedCmdCtx,
+				rawCommand: "ls -la",
+				command:    []string{"ls", "-la"},
+			},
+			expectedType: forcedCmdSessionType,
+		},
+
+		{
+			test: "with forced command and sftp subsystem",
+			session: &fakeSession{
+				ctx:       forcedCmdCtx,
+				subsystem: sftpSubsystem,
+			},
+			expectedType: forcedCmdSessionType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			sessionType := getSessionType(tc.session)
+
+			if sessionType != tc.expectedType {
+				t.Fatalf(
+					"expected session type to equal '%s', got '%s'",
+					tc.expectedType,
+					sessionType,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package sshserver
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+
+	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/recorder"
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const defaultMaxSessionRecordings = 100
+
+var sessionRecorder = recorder.New(config.SessionRecordingsDirPath)
+
+type sessionRecording struct {
+	recording *recorder.Recording
+	// Only set for PTY sessions
+	cast *recorder.Cast
+}
+
+// startSessionRecording returns nil if session recording is
+// disabled. Errors are logged and do not prevent the session
+// from starting. The methods of the returned recording
+// could be called on a nil recording.
+func startSessionRecording(
+	sshSession ssh.Session,
+	recordingType recorder.RecordingType,
+) *sessionRecording {
+
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	if err != nil {
+		log.Printf("[SSH server] Error when loading recording config: %v", err)
+		return nil
+	}
+
+	if agentConfig == nil ||
+		agentConfig.SessionRecording == nil ||
+		!agentConfig.SessionRecording.Enabled {
+
+		return nil
+	}
+
+	recording := &recorder.Recording{
+		Type: recordingType,
+		User: sshSession.User(),
+	}
+
+	if publicKey := sshSession.PublicKey(); publicKey != nil {
+		recording.KeyFingerprint = gossh.FingerprintSHA256(publicKey)
+	}
+
+	if recordingType == recorder.RecordingTypeExec {
+		recording.Command = sshSession.RawCommand()
+
+		if forcedCmd := getAuthorizedKeyOptions(sshSession.Context()).command; len(forcedCmd) > 0 {
+			recording.Command = forcedCmd
+		}
+	}
+
+	var cast *recorder.Cast
+
+	if recordingType == recorder.RecordingTypePTY {
+		ptyReq, _, _ := sshSession.Pty()
+
+		cast, err = sessionRecorder.StartPTY(
+			recording,
+			ptyReq.Window.Width,
+			ptyReq.Window.Height,
+			map[string]string{
+				"TERM":  ptyReq.Term,
+				"SHELL": config.ElevenUserShellPath,
+			},
+		)
+	} else {
+		err = sessionRecorder.Start(recording)
+	}
+
+	if err != nil {
+		log.Printf("[SSH server] Error when starting session recording: %v", err)
+		return nil
+	}
+
+	return &sessionRecording{
+		recording: recording,
+		cast:      cast,
+	}
+}
+
+// recoverSessionRecordings ends the recordings of the sessions
+// stopped with the agent so that they could be pruned and deleted.
+// Must be called on startup, before any session is started.
+func recoverSessionRecordings() {
+	if err := sessionRecorder.EndInterrupted(); err != nil {
+		log.Printf("[SSH server] Error when ending interrupted session recordings: %v", err)
+	}
+
+	pruneSessionRecordings()
+}
+
+// pruneSessionRecordings is called on startup and each time a
+// recording ends given that recordings in progress are not pruned
+func pruneSessionRecordings() {
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	if err != nil {
+		log.Printf("[SSH server] Error when loading recording config: %v", err)
+		return
+	}
+
+	maxRecordings := defaultMaxSessionRecordings
+
+	if agentConfig != nil &&
+		agentConfig.SessionRecording != nil &&
+		agentConfig.SessionRecording.MaxRecordings > 0 {
+
+		maxRecordings = agentConfig.SessionRecording.MaxRecordings
+	}
+
+	if err := sessionRecorder.Prune(maxRecordings); err != nil {
+		log.Printf("[SSH server] Error when pruning session recordings: %v", err)
+	}
+}
+
+// output returns a writer that writes to the
+// passed writer and to the cast (if any)
+func (s *sessionRecording) output(w io.Writer) io.Writer {
+	if s == nil || s.cast == nil {
+		return w
+	}
+
+	return io.MultiWriter(w, s.cast)
+}
+
+func (s *sessionRecording) resize(window ssh.Window) {
+	if s == nil || s.cast == nil {
+		return
+	}
+
+	s.cast.Resize(window.Width, window.Height)
+}
+
+// end records the exit status
+// of the passed command error
+func (s *sessionRecording) end(cmdErr error) {
+	if s == nil {
+		return
+	}
+
+	exitStatus := 0
+
+	if cmdErr != nil {
+		exitStatus = -1
+
+		var exitErr *exec.ExitError
+
+		if errors.As(cmdErr, &exitErr) {
+			exitStatus = exitErr.ExitCode()
+		}
+	}
+
+	s.endWithExitStatus(exitStatus)
+}
+
+func (s *sessionRecording) endWithExitStatus(exitStatus int) {
+	if s == nil {
+		return
+	}
+
+	if s.cast != nil {
+		if err := s.cast.Close(); err != nil {
+			log.Printf("[SSH server] Error when closing session recording: %v", err)
+		}
+	}
+
+	if err := sessionRecorder.End(s.recording, exitStatus); err != nil {
+		log.Printf("[SSH server] Error when ending session recording: %v", err)
+		return
+	}
+
+	pruneSessionRecordings()
+}
@@ -0,0 +1,369 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/network"
+	"github.com/prometheus/procfs"
+)
+
+const (
+	forwardedPortsTargetAddr = "127.0.0.1"
+	// Used for services listening only on IPv6
+	forwardedPortsFallbackTargetAddr = "::1"
+
+	udpForwarderSessionIdleTimeout = 60 * time.Second
+	udpForwarderMaxPacketSize      = 65535
+)
+
+type udpForwarder struct {
+	listeningPort string
+	targetPort    string
+	packetConn    net.PacketConn
+	sessions      map[string]*udpForwarderSession
+	sessionsLock  sync.Mutex
+	doneChan      chan struct{}
+}
+
+type udpForwarderSession struct {
+	clientAddr net.Addr
+	targetConn net.Conn
+}
+
+// TCP forwarded ports reuse the localhost proxies forwarding logic
+var tcpForwarders = map[env.ConfigForwardedPort]*localhostProxy{}
+var udpForwarders = map[env.ConfigForwardedPort]*udpForwarder{}
+
+// Last error reported for each forwarded port
+var forwardedPortsErrors = map[env.ConfigForwardedPort]string{}
+
+func ReconcileForwardedPorts(forwardedPorts env.ConfigForwardedPorts) {
+	wantedForwarders := map[env.ConfigForwardedPort]bool{}
+
+	for _, forwardedPort := range forwardedPorts {
+		wantedForwarders[forwardedPort] = true
+	}
+
+	for forwardedPort, proxy := range tcpForwarders {
+		if wantedForwarders[forwardedPort] {
+			continue
+		}
+
+		stopLocalhostProxy(proxy)
+		delete(tcpForwarders, forwardedPort)
+	}
+
+	for forwardedPort, forwarder := range udpForwarders {
+		if wantedForwarders[forwardedPort] {
+			continue
+		}
+
+		close(forwarder.doneChan)
+		delete(udpForwarders, forwardedPort)
+	}
+
+	forwardersErrors := map[env.ConfigForwardedPort]error{}
+
+	for forwardedPort := range wantedForwarders {
+		switch forwardedPort.Protocol {
+		case env.ConfigServedPortProtocolTCP:
+			if _, forwarderExists := tcpForwarders[forwardedPort]; forwarderExists {
+				continue
+			}
+
+			if err := startTCPForwarder(forwardedPort); err != nil {
+				forwardersErrors[forwardedPort] = err
+			}
+		case env.ConfigServedPortProtocolUDP:
+			if _, forwarderExists := udpForwarders[forwardedPort]; forwarderExists {
+				continue
+			}
+
+			if err := startUDPForwarder(forwardedPort); err != nil {
+				forwardersErrors[forwardedPort] = err
+			}
+		default:
+			forwardersErrors[forwardedPort] = fmt.Errorf(
+				"unsupported protocol \"%s\"",
+				forwardedPort.Protocol,
+			)
+		}
+	}
+
+	reportForwardedPortsErrors(forwardersErrors)
+}
+
+func reportForwardedPortsErrors(
+	forwardersErrors map[env.ConfigForwardedPort]error,
+) {
+
+	reportedErrors := map[env.ConfigForwardedPort]string{}
+
+	for forwardedPort, err := range forwardersErrors {
+		reportedErrors[forwardedPort] = err.Error()
+
+		if forwardedPortsErrors[forwardedPort] == err.Error() {
+			continue
+		}
+
+		log.Printf(
+			"[Forwarded ports] Error for %s port %s: %v",
+			forwardedPort.Protocol,
+			forwardedPort.ListenPort,
+			err,
+		)
+	}
+
+	forwardedPortsErrors = reportedErrors
+}
+
+func startTCPForwarder(forwardedPort env.ConfigForwardedPort) error {
+	proxy := &localhostProxy{
+		listeningPort:      forwardedPort.ListenPort,
+		targetAddr:         forwardedPortsTargetAddr,
+		fallbackTargetAddr: forwardedPortsFallbackTargetAddr,
+		targetPort:         forwardedPort.TargetPort,
+		targetUnixSocket:   forwardedPort.TargetUnixSocket,
+		proxyProtocol:      forwardedPort.ProxyProtocol,
+		doneChan:           make(chan struct{}),
+		conns:              map[uint64]*localhostProxyConn{},
+	}
+
+	proxyNetListener, err := net.Listen(
+		"tcp",
+		":"+forwardedPort.ListenPort,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	proxy.netListener = proxyNetListener
+
+	tcpForwarders[forwardedPort] = proxy
+
+	go handleLocalhostProxyConn(proxy)
+
+	return nil
+}
+
+func startUDPForwarder(forwardedPort env.ConfigForwardedPort) error {
+	forwarder := &udpForwarder{
+		listeningPort: forwardedPort.ListenPort,
+		targetPort:    forwardedPort.TargetPort,
+		sessions:      map[string]*udpForwarderSession{},
+		doneChan:      make(chan struct{}),
+	}
+
+	packetConn, err := net.ListenPacket(
+		"udp",
+		":"+forwardedPort.ListenPort,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	forwarder.packetConn = packetConn
+
+	udpForwarders[forwardedPort] = forwarder
+
+	go handleUDPForwarderPackets(forwarder)
+
+	return nil
+}
+
+// Contrary to TCP, dialing UDP doesn't fail when nothing is listening
+func resolveUDPForwarderTargetAddr(targetPort string) string {
+	udpSockets, err := network.GetBoundUDPSockets()
+
+	if err != nil {
+		log.Printf(
+			"[Forwarded ports] Error when looking up UDP sockets: %v",
+			err,
+		)
+
+		return forwardedPortsTargetAddr
+	}
+
+	return findUDPTargetAddr(udpSockets, targetPort)
+}
+
+func findUDPTargetAddr(udpSockets procfs.NetUDP, targetPort string) string {
+	targetAddr := forwardedPortsTargetAddr
+
+	for _, socket := range udpSockets {
+		if fmt.Sprintf("%d", socket.LocalPort) != targetPort {
+			continue
+		}
+
+		if socket.LocalAddr.To4() != nil {
+			return forwardedPortsTargetAddr
+		}
+
+		if socket.LocalAddr.IsLoopback() || socket.LocalAddr.IsUnspecified() {
+			targetAddr = forwardedPortsFallbackTargetAddr
+		}
+	}
+
+	return targetAddr
+}
+
+func handleUDPForwarderPackets(forwarder *udpForwarder) {
+	go func() {
+		<-forwarder.doneChan
+
+		if err := forwarder.packetConn.Close(); err != nil {
+			log.Printf(
+				"[Forwarded ports] Error when closing UDP forwarder for port %s: %v",
+				forwarder.listeningPort,
+				err,
+			)
+		}
+
+		forwarder.sessionsLock.Lock()
+		defer forwarder.sessionsLock.Unlock()
+
+		for _, session := range forwarder.sessions {
+			session.targetConn.Close()
+		}
+	}()
+
+	packet := make([]byte, udpForwarderMaxPacketSize)
+
+	for {
+		packetLen, clientAddr, err := forwarder.packetConn.ReadFrom(packet)
+
+		if err != nil {
+			select {
+			case <-forwarder.doneChan:
+				return
+			default:
+				log.Printf(
+					"[Forwarded ports] Error when reading packet on UDP forwarder for port %s: %v",
+					forwarder.listeningPort,
+					err,
+				)
+
+				continue
+			}
+		}
+
+		session, err := getOrCreateUDPForwarderSession(
+			forwarder,
+			clientAddr,
+		)
+
+		if err != nil {
+			log.Printf(
+				"[Forwarded ports] Error when connecting to port %s: %v",
+				forwarder.targetPort,
+				err,
+			)
+
+			continue
+		}
+
+		if _, err := session.targetConn.Write(packet[:packetLen]); err != nil {
+			log.Printf(
+				"[Forwarded ports] Error when forwarding packet to %s: %v",
+				session.targetConn.RemoteAddr(),
+				err,
+			)
+
+			continue
+		}
+
+		// Client activity keeps the session alive
+		session.targetConn.SetReadDeadline(
+			time.Now().Add(udpForwarderSessionIdleTimeout),
+		)
+	}
+}
+
+func getOrCreateUDPForwarderSession(
+	forwarder *udpForwarder,
+	clientAddr net.Addr,
+) (*udpForwarderSession, error) {
+
+	forwarder.sessionsLock.Lock()
+	defer forwarder.sessionsLock.Unlock()
+
+	if session, sessionExists := forwarder.sessions[clientAddr.String()]; sessionExists {
+		return session, nil
+	}
+
+	// Each client gets its own local socket to route replies back
+	targetConn, err := net.Dial(
+		"udp",
+		net.JoinHostPort(
+			resolveUDPForwarderTargetAddr(forwarder.targetPort),
+			forwarder.targetPort,
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	session := &udpForwarderSession{
+		clientAddr: clientAddr,
+		targetConn: targetConn,
+	}
+
+	forwarder.sessions[clientAddr.String()] = session
+
+	go forwardUDPRepliesToClient(forwarder, session)
+
+	return session, nil
+}
+
+func forwardUDPRepliesToClient(
+	forwarder *udpForwarder,
+	session *udpForwarderSession,
+) {
+
+	defer func() {
+		forwarder.sessionsLock.Lock()
+		defer forwarder.sessionsLock.Unlock()
+
+		session.targetConn.Close()
+		delete(forwarder.sessions, session.clientAddr.String())
+	}()
+
+	reply := make([]byte, udpForwarderMaxPacketSize)
+
+	err := session.targetConn.SetReadDeadline(
+		time.Now().Add(udpForwarderSessionIdleTimeout),
+	)
+
+	if err != nil {
+		return
+	}
+
+	for {
+		replyLen, err := session.targetConn.Read(reply)
+
+		if err != nil {
+			return
+		}
+
+		_, err = forwarder.packetConn.WriteTo(
+			reply[:replyLen],
+			session.clientAddr,
+		)
+
+		if err != nil {
+			return
+		}
+
+		session.targetConn.SetReadDeadline(
+			time.Now().Add(udpForwarderSessionIdleTimeout),
+		)
+	}
+}
@@ -0,0 +1,213 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/prometheus/procfs"
+)
+
+func TestReportForwardedPortsErrors(t *testing.T) {
+	tcpPort := env.ConfigForwardedPort{
+		Protocol:   env.ConfigServedPortProtocolTCP,
+		ListenPort: "5432",
+		TargetPort: "5432",
+	}
+
+	udpPort := env.ConfigForwardedPort{
+		Protocol:   env.ConfigServedPortProtocolUDP,
+		ListenPort: "5353",
+		TargetPort: "5353",
+	}
+
+	testCases := []struct {
+		test               string
+		forwardersErrors   []map[env.ConfigForwardedPort]error
+		expectedLoggedErrs int
+	}{
+		{
+			test: "same error is logged once",
+			forwardersErrors: []map[env.ConfigForwardedPort]error{
+				{tcpPort: errors.New("address already in use")},
+				{tcpPort: errors.New("address already in use")},
+				{tcpPort: errors.New("address already in use")},
+			},
+			expectedLoggedErrs: 1,
+		},
+
+		{
+			test: "changed error is logged again",
+			forwardersErrors: []map[env.ConfigForwardedPort]error{
+				{tcpPort: errors.New("address already in use")},
+				{tcpPort: errors.New("permission denied")},
+			},
+			expectedLoggedErrs: 2,
+		},
+
+		{
+			test: "error is logged again once fixed then failing again",
+			forwardersErrors: []map[env.ConfigForwardedPort]error{
+				{tcpPort: errors.New("address already in use")},
+				{},
+				{tcpPort: errors.New("address already in use")},
+			},
+			expectedLoggedErrs: 2,
+		},
+
+		{
+			test: "errors are logged for each port",
+			forwardersErrors: []map[env.ConfigForwardedPort]error{
+				{
+					tcpPort: errors.New("address already in use"),
+					udpPort: errors.New("address already in use"),
+				},
+				{
+					tcpPort: errors.New("address already in use"),
+					udpPort: errors.New("address already in use"),
+				},
+			},
+			expectedLoggedErrs: 2,
+		},
+	}
+
+	defer log.SetOutput(os.Stderr)
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			forwardedPortsErrors = map[env.ConfigForwardedPort]string{}
+
+			logs := &bytes.Buffer{}
+			log.SetOutput(logs)
+
+			for _, forwardersErrors := range tc.forwardersErrors {
+				reportForwardedPortsErrors(forwardersErrors)
+			}
+
+			loggedErrs := strings.Count(logs.String(), "[Forwarded ports] Error")
+
+			if loggedErrs != tc.expectedLoggedErrs {
+				t.Fatalf(
+					"expected logged errors to equal '%+v', got '%+v'",
+					tc.expectedLoggedErrs,
+					loggedErrs,
+				)
+			}
+		})
+	}
+}
+
+func TestFindUDPTargetAddr(t *testing.T) {
+	testCases := []struct {
+		test               string
+		udpSockets         procfs.NetUDP
+		targetPort         string
+		expectedTargetAddr string
+	}{
+		{
+			test:               "nothing bound",
+			udpSockets:         procfs.NetUDP{},
+			targetPort:         "5353",
+			expectedTargetAddr: "127.0.0.1",
+		},
+
+		{
+			test: "bound on IPv4 loopback",
+			udpSockets: procfs.NetUDP{
+				{LocalAddr: net.ParseIP("127.0.0.1"), LocalPort: 5353},
+			},
+			targetPort:         "5353",
+			expectedTargetAddr: "127.0.0.1",
+		},
+
+		{
+			test: "bound on IPv6 loopback only",
+			udpSockets: procfs.NetUDP{
+				{LocalAddr: net.ParseIP("::1"), LocalPort: 5353},
+			},
+			targetPort:         "5353",
+			expectedTargetAddr: "::1",
+		},
+
+		{
+			test: "bound on all IPv6 addresses only",
+			udpSockets: procfs.NetUDP{
+				{LocalAddr: net.ParseIP("::"), LocalPort: 5353},
+			},
+			targetPort:         "5353",
+			expectedTargetAddr: "::1",
+		},
+
+		{
+			test: "bound on both IPv6 and IPv4",
+			udpSockets: procfs.NetUDP{
+				{LocalAddr: net.ParseIP("::1"), LocalPort: 5353},
+				{LocalAddr: net.ParseIP("0.0.0.0"), LocalPort: 5353},
+			},
+			targetPort:         "5353",
+			expectedTargetAddr: "127.0.0.1",
+		},
+
+		{
+			test: "IPv6 loopback bound on another port",
+			udpSockets: procfs.NetUDP{
+				{LocalAddr: net.ParseIP("::1"), LocalPort: 5354},
+			},
+			targetPort:         "5353",
+			expectedTargetAddr: "127.0.0.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			targetAddr := findUDPTargetAddr(tc.udpSockets, tc.targetPort)
+
+			if targetAddr != tc.expectedTargetAddr {
+				t.Fatalf(
+					"expected target addr to equal '%+v', got '%+v'",
+					tc.expectedTargetAddr,
+					targetAddr,
+				)
+			}
+		})
+	}
+}
+
+func TestConnectToLocalhostTargetFallback(t *testing.T) {
+	target, err := net.Listen("tcp", "[::1]:0")
+
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+
+	defer target.Close()
+
+	_, targetPort, _ := net.SplitHostPort(target.Addr().String())
+
+	proxy := &localhostProxy{
+		targetAddr:         forwardedPortsTargetAddr,
+		fallbackTargetAddr: forwardedPortsFallbackTargetAddr,
+		targetPort:         targetPort,
+	}
+
+	localConn, err := connectToLocalhostTarget(proxy)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer localConn.Close()
+
+	if localConn.RemoteAddr().String() != target.Addr().String() {
+		t.Fatalf(
+			"expected remote addr to equal '%+v', got '%+v'",
+			target.Addr().String(),
+			localConn.RemoteAddr().String(),
+		)
+	}
+}
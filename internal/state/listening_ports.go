@@ -0,0 +1,295 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/network"
+	"github.com/prometheus/procfs"
+)
+
+type ListeningPortEventType string
+
+const (
+	ListeningPortEventTypeOpened ListeningPortEventType = "opened"
+	ListeningPortEventTypeClosed ListeningPortEventType = "closed"
+)
+
+// Subscriptions are ended once the buffer is full
+const listeningPortsSubscriberBufferSize = 64
+
+type ListeningPort struct {
+	Addr string
+	Port string
+	// 0 if the owning process could not be found
+	PID    int
+	Served bool
+	inode  uint64
+}
+
+type ListeningPortEvent struct {
+	Type      ListeningPortEventType
+	Port      ListeningPort
+	Timestamp time.Time
+}
+
+type listeningPortsSubscriber struct {
+	ports  map[string]bool
+	events chan *ListeningPortEvent
+}
+
+// Address and port
+type listeningPortID string
+
+var listeningPorts = map[listeningPortID]*ListeningPort{}
+var listeningPortsSubscribers = map[*listeningPortsSubscriber]bool{}
+var listeningPortsLock sync.Mutex
+
+// The channel is closed if the subscriber is too slow
+func SubscribeToListeningPorts(
+	ports []string,
+) (<-chan *ListeningPortEvent, func()) {
+
+	listeningPortsLock.Lock()
+	defer listeningPortsLock.Unlock()
+
+	sub := &listeningPortsSubscriber{
+		ports: map[string]bool{},
+		events: make(
+			chan *ListeningPortEvent,
+			listeningPortsSubscriberBufferSize+len(listeningPorts),
+		),
+	}
+
+	for _, port := range ports {
+		sub.ports[port] = true
+	}
+
+	noPorts := map[listeningPortID]*ListeningPort{}
+
+	for _, event := range diffListeningPorts(noPorts, listeningPorts, time.Now()) {
+		sub.send(event)
+	}
+
+	listeningPortsSubscribers[sub] = true
+
+	return sub.events, func() {
+		listeningPortsLock.Lock()
+		defer listeningPortsLock.Unlock()
+
+		delete(listeningPortsSubscribers, sub)
+	}
+}
+
+func (s *listeningPortsSubscriber) send(event *ListeningPortEvent) {
+	if len(s.ports) > 0 && !s.ports[event.Port.Port] {
+		return
+	}
+
+	// Ports listened on by the agent are ignored
+	if event.Port.PID == os.Getpid() {
+		return
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		// Ended instead of dropping the event to not leave it with an outdated state
+		close(s.events)
+		delete(listeningPortsSubscribers, s)
+	}
+}
+
+func reconcileListeningPorts(
+	tcpConns procfs.NetTCP,
+	servedPorts env.ConfigServedPorts,
+) {
+
+	newListeningPorts := buildListeningPorts(tcpConns, servedPorts)
+
+	listeningPortsLock.Lock()
+	defer listeningPortsLock.Unlock()
+
+	events := diffListeningPorts(listeningPorts, newListeningPorts, time.Now())
+
+	var socketsPIDs map[uint64]int
+
+	for _, event := range events {
+		if event.Type != ListeningPortEventTypeOpened {
+			continue
+		}
+
+		// Processes are only looked up when needed
+		if socketsPIDs == nil {
+			var err error
+			socketsPIDs, err = getSocketsPIDs()
+
+			if err != nil {
+				log.Printf(
+					"[Listening ports] Error when looking up processes: %v",
+					err,
+				)
+
+				socketsPIDs = map[uint64]int{}
+			}
+		}
+
+		event.Port.PID = socketsPIDs[event.Port.inode]
+		newListeningPorts[buildListeningPortID(event.Port)].PID = event.Port.PID
+	}
+
+	for _, event := range events {
+		for sub := range listeningPortsSubscribers {
+			sub.send(event)
+		}
+	}
+
+	listeningPorts = newListeningPorts
+}
+
+func buildListeningPorts(
+	tcpConns procfs.NetTCP,
+	servedPorts env.ConfigServedPorts,
+) map[listeningPortID]*ListeningPort {
+
+	ports := map[listeningPortID]*ListeningPort{}
+
+	for _, conn := range tcpConns {
+		if conn.St != uint64(network.TCPConnStatusListening) {
+			continue
+		}
+
+		port := fmt.Sprintf("%d", conn.LocalPort)
+		_, isServed := servedPorts[env.ConfigServedPort(port)]
+
+		listeningPort := &ListeningPort{
+			Addr:   conn.LocalAddr.String(),
+			Port:   port,
+			Served: isServed,
+			inode:  conn.Inode,
+		}
+
+		ports[buildListeningPortID(*listeningPort)] = listeningPort
+	}
+
+	return ports
+}
+
+// Ports listened on again by another socket are sent as "closed" then "opened"
+func diffListeningPorts(
+	previousPorts map[listeningPortID]*ListeningPort,
+	currentPorts map[listeningPortID]*ListeningPort,
+	timestamp time.Time,
+) []*ListeningPortEvent {
+
+	events := []*ListeningPortEvent{}
+
+	for ID, previousPort := range previousPorts {
+		currentPort, isListened := currentPorts[ID]
+
+		if isListened && currentPort.inode == previousPort.inode {
+			currentPort.PID = previousPort.PID
+			continue
+		}
+
+		events = append(events, &ListeningPortEvent{
+			Type:      ListeningPortEventTypeClosed,
+			Port:      *previousPort,
+			Timestamp: timestamp,
+		})
+	}
+
+	for ID, currentPort := range currentPorts {
+		previousPort, wasListened := previousPorts[ID]
+
+		if wasListened && currentPort.inode == previousPort.inode {
+			continue
+		}
+
+		events = append(events, &ListeningPortEvent{
+			Type:      ListeningPortEventTypeOpened,
+			Port:      *currentPort,
+			Timestamp: timestamp,
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Port.Port != events[j].Port.Port {
+			return events[i].Port.Port < events[j].Port.Port
+		}
+
+		if events[i].Port.Addr != events[j].Port.Addr {
+			return events[i].Port.Addr < events[j].Port.Addr
+		}
+
+		// "closed" before "opened"
+		return events[i].Type == ListeningPortEventTypeClosed &&
+			events[j].Type == ListeningPortEventTypeOpened
+	})
+
+	return events
+}
+
+func buildListeningPortID(port ListeningPort) listeningPortID {
+	return listeningPortID(net.JoinHostPort(port.Addr, port.Port))
+}
+
+func getSocketsPIDs() (map[uint64]int, error) {
+	processes, err := procfs.AllProcs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	socketsPIDs := map[uint64]int{}
+
+	for _, process := range processes {
+		fdTargets, err := process.FileDescriptorTargets()
+
+		if err != nil {
+			// Race condition
+			continue
+		}
+
+		for _, fdTarget := range fdTargets {
+			inode, isSocket := parseSocketInode(fdTarget)
+
+			if !isSocket {
+				continue
+			}
+
+			socketsPIDs[inode] = process.PID
+		}
+	}
+
+	return socketsPIDs, nil
+}
+
+// Targets look like "socket:[<inode>]"
+func parseSocketInode(fdTarget string) (uint64, bool) {
+	if !strings.HasPrefix(fdTarget, "socket:[") ||
+		!strings.HasSuffix(fdTarget, "]") {
+
+		return 0, false
+	}
+
+	inode, err := strconv.ParseUint(
+		strings.TrimSuffix(strings.TrimPrefix(fdTarget, "socket:["), "]"),
+		10,
+		64,
+	)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return inode, true
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiffListeningPorts(t *testing.T) {
+	timestamp := time.Now()
+
+	testCases := []struct {
+		test           string
+		previousPorts  map[listeningPortID]*ListeningPort
+		currentPorts   map[listeningPortID]*ListeningPort
+		expectedEvents []*ListeningPortEvent
+	}{
+		{
+			test:          "with opened ports",
+			previousPorts: map[listeningPortID]*ListeningPort{},
+			currentPorts: map[listeningPortID]*ListeningPort{
+				"[::]:8080":      {Addr: "::", Port: "8080", inode: 2},
+				"127.0.0.1:5173": {Addr: "127.0.0.1", Port: "5173", inode: 3},
+				"0.0.0.0:8080":   {Addr: "0.0.0.0", Port: "8080", Served: true, inode: 1},
+			},
+			expectedEvents: []*ListeningPortEvent{
+				{
+					Type:      ListeningPortEventTypeOpened,
+					Port:      ListeningPort{Addr: "127.0.0.1", Port: "5173", inode: 3},
+					Timestamp: timestamp,
+				},
+				{
+					Type:      ListeningPortEventTypeOpened,
+					Port:      ListeningPort{Addr: "0.0.0.0", Port: "8080", Served: true, inode: 1},
+					Timestamp: timestamp,
+				},
+				{
+					Type:      ListeningPortEventTypeOpened,
+					Port:      ListeningPort{Addr: "::", Port: "8080", inode: 2},
+					Timestamp: timestamp,
+				},
+			},
+		},
+
+		{
+			test: "with closed and unchanged ports",
+			previousPorts: map[listeningPortID]*ListeningPort{
+				"127.0.0.1:5173": {Addr: "127.0.0.1", Port: "5173", PID: 10, inode: 3},
+				"0.0.0.0:8080":   {Addr: "0.0.0.0", Port: "8080", PID: 11, inode: 1},
+			},
+			currentPorts: map[listeningPortID]*ListeningPort{
+				"0.0.0.0:8080": {Addr: "0.0.0.0", Port: "8080", inode: 1},
+			},
+			expectedEvents: []*ListeningPortEvent{
+				{
+					Type:      ListeningPortEventTypeClosed,
+					Port:      ListeningPort{Addr: "127.0.0.1", Port: "5173", PID: 10, inode: 3},
+					Timestamp: timestamp,
+				},
+			},
+		},
+
+		{
+			test: "with port listened on again by another socket",
+			previousPorts: map[listeningPortID]*ListeningPort{
+				"127.0.0.1:5173": {Addr: "127.0.0.1", Port: "5173", PID: 10, inode: 3},
+			},
+			currentPorts: map[listeningPortID]*ListeningPort{
+				"127.0.0.1:5173": {Addr: "127.0.0.1", Port: "5173", inode: 4},
+			},
+			expectedEvents: []*ListeningPortEvent{
+				{
+					Type:      ListeningPortEventTypeClosed,
+					Port:      ListeningPort{Addr: "127.0.0.1", Port: "5173", PID: 10, inode: 3},
+					Timestamp: timestamp,
+				},
+				{
+					Type:      ListeningPortEventTypeOpened,
+					Port:      ListeningPort{Addr: "127.0.0.1", Port: "5173", inode: 4},
+					Timestamp: timestamp,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			events := diffListeningPorts(tc.previousPorts, tc.currentPorts, timestamp)
+
+			if !reflect.DeepEqual(events, tc.expectedEvents) {
+				t.Fatalf(
+					"expected events to equal '%+v', got '%+v'",
+					tc.expectedEvents,
+					events,
+				)
+			}
+		})
+	}
+}
+
+func TestParseSocketInode(t *testing.T) {
+	testCases := []struct {
+		test             string
+		fdTarget         string
+		expectedInode    uint64
+		expectedIsSocket bool
+	}{
+		{
+			test:             "with socket",
+			fdTarget:         "socket:[123456]",
+			expectedInode:    123456,
+			expectedIsSocket: true,
+		},
+
+		{
+			test:             "with pipe",
+			fdTarget:         "pipe:[123456]",
+			expectedIsSocket: false,
+		},
+
+		{
+			test:             "with file",
+			fdTarget:         "/dev/null",
+			expectedIsSocket: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			inode, isSocket := parseSocketInode(tc.fdTarget)
+
+			if inode != tc.expectedInode || isSocket != tc.expectedIsSocket {
+				t.Fatalf(
+					"expected inode and socket to equal '%d, %t', got '%d, %t'",
+					tc.expectedInode,
+					tc.expectedIsSocket,
+					inode,
+					isSocket,
+				)
+			}
+		})
+	}
+}
+
+func TestListeningPortsSubscriberOverflow(t *testing.T) {
+	sub := &listeningPortsSubscriber{
+		ports:  map[string]bool{},
+		events: make(chan *ListeningPortEvent, listeningPortsSubscriberBufferSize),
+	}
+
+	listeningPortsLock.Lock()
+	listeningPortsSubscribers[sub] = true
+
+	for i := 0; i <= listeningPortsSubscriberBufferSize; i++ {
+		sub.send(&ListeningPortEvent{
+			Type: ListeningPortEventTypeOpened,
+			Port: ListeningPort{
+				Addr: "127.0.0.1",
+				Port: strconv.Itoa(3000 + i),
+			},
+		})
+	}
+
+	_, isSubscribed := listeningPortsSubscribers[sub]
+	listeningPortsLock.Unlock()
+
+	if isSubscribed {
+		t.Fatalf("expected subscriber to be removed on overflow")
+	}
+
+	receivedEvents := 0
+
+	for range sub.events {
+		receivedEvents++
+	}
+
+	if receivedEvents != listeningPortsSubscriberBufferSize {
+		t.Fatalf(
+			"expected received events to equal '%d', got '%d'",
+			listeningPortsSubscriberBufferSize,
+			receivedEvents,
+		)
+	}
+}
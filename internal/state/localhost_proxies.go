@@ -2,40 +2,85 @@ package state
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"net"
+	"sync"
 
 	"github.com/eleven-sh/agent/internal/env"
 	"github.com/eleven-sh/agent/internal/network"
 )
 
+// Bind address and port
 type localhostListenerID string
 
 type localhostListener struct {
 	listeningPort string
+	// The loopback address listened on
 	listeningAddr string
+	bindAddr      string
+	proxyProtocol env.ConfigProxyProtocolVersion
 }
 
 type localhostListeners map[localhostListenerID]*localhostListener
 
 type localhostProxy struct {
+	// First to be 64-bit aligned
+	stats localhostProxyStats
+
+	bindAddr      string
 	listeningPort string
 	targetAddr    string
-	netListener   net.Listener
-	doneChan      chan struct{}
+	// Dialed when nothing is listening on the target address
+	fallbackTargetAddr string
+	targetPort         string
+	// Takes precedence over the target address and port
+	targetUnixSocket string
+	proxyProtocol    env.ConfigProxyProtocolVersion
+	netListener      net.Listener
+	doneChan         chan struct{}
+	connsLock        sync.Mutex
+	conns            map[uint64]*localhostProxyConn
+}
+
+// target returns the Unix socket or the
+// address that the proxy forwards to
+func (p *localhostProxy) target() string {
+	if len(p.targetUnixSocket) > 0 {
+		return p.targetUnixSocket
+	}
+
+	return net.JoinHostPort(p.targetAddr, p.targetPort)
 }
 
 var localhostProxies = map[localhostListenerID]*localhostProxy{}
 
-func ReconcileLocalhostProxies(servedPorts env.ConfigServedPorts) error {
+// Localhost proxies are listed by the gRPC server
+var localhostProxiesLock sync.Mutex
+
+// Last error reported for each address. Used to not
+// log the same error on each reconciliation.
+var localhostProxiesErrors = map[string]string{}
+
+// ReconcileLocalhostProxies exposes the served ports that are
+// only listened on a loopback address on the bind addresses
+// described by the passed config (see "env.ConfigLocalhostProxies").
+// The listening ports subscribers are notified from the same scan.
+func ReconcileLocalhostProxies(
+	servedPorts env.ConfigServedPorts,
+	proxyProtocols env.ConfigProxyProtocols,
+	proxiesConfig *env.ConfigLocalhostProxies,
+) error {
+
 	tcpConns, err := network.GetOpenedTCPConns()
 
 	if err != nil {
 		return err
 	}
 
-	listeners := localhostListeners{}
+	reconcileListeningPorts(tcpConns, servedPorts)
+
+	// Loopback addresses mapped to their port
+	loopbackAddrs := map[string][]net.IP{}
 
 	for _, conn := range tcpConns {
 		if conn.St != uint64(network.TCPConnStatusListening) {
@@ -52,35 +97,123 @@ func ReconcileLocalhostProxies(servedPorts env.ConfigServedPorts) error {
 			continue
 		}
 
-		listeningAddr := conn.LocalAddr.String()
+		loopbackAddrs[listeningPortS] = append(
+			loopbackAddrs[listeningPortS],
+			conn.LocalAddr,
+		)
+	}
+
+	proxiesErrors := map[string]error{}
+	bindIPs := getLocalhostProxiesBindIPs(proxiesConfig, proxiesErrors)
+
+	localhostProxiesLock.Lock()
+	defer localhostProxiesLock.Unlock()
+
+	reconcileLocalhostProxiesState(
+		buildLocalhostListeners(loopbackAddrs, bindIPs, proxyProtocols),
+		proxiesErrors,
+	)
+
+	reportLocalhostProxiesErrors(proxiesErrors)
+
+	return nil
+}
+
+// getLocalhostProxiesBindIPs adds the errors
+// to the passed map keyed by address
+func getLocalhostProxiesBindIPs(
+	proxiesConfig *env.ConfigLocalhostProxies,
+	proxiesErrors map[string]error,
+) []net.IP {
 
-		if conn.LocalAddr.To4() == nil { // IPv6
-			listeningAddr = "[" + listeningAddr + "]"
+	if proxiesConfig == nil {
+		proxiesConfig = &env.ConfigLocalhostProxies{}
+	}
+
+	if len(proxiesConfig.BindAddrs) == 0 {
+		IPs, err := network.GetInterfacesIPs(proxiesConfig.BindInterface)
+
+		if err != nil {
+			interfaceName := proxiesConfig.BindInterface
+
+			if len(interfaceName) == 0 {
+				interfaceName = "all interfaces"
+			}
+
+			proxiesErrors[interfaceName] = err
 		}
 
-		listenerAddrAndPort := net.JoinHostPort(
-			listeningAddr,
-			listeningPortS,
-		)
+		return IPs
+	}
+
+	IPs := []net.IP{}
 
-		listeners[localhostListenerID(listenerAddrAndPort)] = &localhostListener{
-			listeningAddr: listeningAddr,
-			listeningPort: listeningPortS,
+	for _, bindAddr := range proxiesConfig.BindAddrs {
+		IP := net.ParseIP(bindAddr)
+
+		if IP == nil {
+			proxiesErrors[bindAddr] = fmt.Errorf("invalid IP address \"%s\"", bindAddr)
+			continue
 		}
+
+		IPs = append(IPs, IP)
 	}
 
-	reconcileLocalhostProxiesState(listeners)
+	return IPs
+}
 
-	return nil
+// buildLocalhostListeners returns a listener for each
+// bind address and port. Loopback addresses of the same
+// IP version than the bind address are targeted first.
+func buildLocalhostListeners(
+	loopbackAddrs map[string][]net.IP,
+	bindIPs []net.IP,
+	proxyProtocols env.ConfigProxyProtocols,
+) localhostListeners {
+
+	listeners := localhostListeners{}
+
+	for port, portLoopbackAddrs := range loopbackAddrs {
+		for _, bindIP := range bindIPs {
+			targetAddr := portLoopbackAddrs[0]
+
+			for _, loopbackAddr := range portLoopbackAddrs {
+				if isIPv4(loopbackAddr) == isIPv4(bindIP) {
+					targetAddr = loopbackAddr
+					break
+				}
+			}
+
+			bindAddr := bindIP.String()
+
+			listeners[localhostListenerID(net.JoinHostPort(bindAddr, port))] = &localhostListener{
+				listeningAddr: targetAddr.String(),
+				listeningPort: port,
+				bindAddr:      bindAddr,
+				proxyProtocol: proxyProtocols[env.ConfigServedPort(port)],
+			}
+		}
+	}
+
+	return listeners
 }
 
-func reconcileLocalhostProxiesState(listeners localhostListeners) {
+func reconcileLocalhostProxiesState(
+	listeners localhostListeners,
+	proxiesErrors map[string]error,
+) {
+
 	for listenerID, proxy := range localhostProxies {
-		if _, listenerExists := listeners[listenerID]; listenerExists {
+		listener, listenerExists := listeners[listenerID]
+
+		if listenerExists &&
+			listener.listeningAddr == proxy.targetAddr &&
+			listener.proxyProtocol == proxy.proxyProtocol {
+
 			continue
 		}
 
-		close(proxy.doneChan)
+		stopLocalhostProxy(proxy)
 		delete(localhostProxies, listenerID)
 	}
 
@@ -90,17 +223,21 @@ func reconcileLocalhostProxiesState(listeners localhostListeners) {
 		}
 
 		proxy := &localhostProxy{
+			bindAddr:      listener.bindAddr,
 			targetAddr:    listener.listeningAddr,
+			targetPort:    listener.listeningPort,
 			listeningPort: listener.listeningPort,
+			proxyProtocol: listener.proxyProtocol,
 			doneChan:      make(chan struct{}),
+			conns:         map[uint64]*localhostProxyConn{},
 		}
 
 		proxyNetListener, err := startLocalhostProxy(proxy)
 
 		if err != nil {
-			log.Printf(
-				"[Localhost proxies] Error when starting proxy for %s: %v",
-				net.JoinHostPort(proxy.targetAddr, proxy.listeningPort),
+			proxiesErrors[string(listenerID)] = fmt.Errorf(
+				"error when starting proxy for %s: %v",
+				proxy.target(),
 				err,
 			)
 
@@ -116,123 +253,31 @@ func reconcileLocalhostProxiesState(listeners localhostListeners) {
 }
 
 func startLocalhostProxy(proxy *localhostProxy) (net.Listener, error) {
-	ip, err := network.GetOutboundIP()
-
-	if err != nil {
-		return nil, err
-	}
-
 	return net.Listen(
 		"tcp",
-		net.JoinHostPort(ip.String(), proxy.listeningPort),
+		net.JoinHostPort(proxy.bindAddr, proxy.listeningPort),
 	)
 }
 
-func handleLocalhostProxyConn(proxy *localhostProxy) {
-	go func() {
-		<-proxy.doneChan
-
-		if err := proxy.netListener.Close(); err != nil {
-			log.Printf(
-				"[Localhost proxies] Error when closing proxy for %s: %v",
-				net.JoinHostPort(proxy.targetAddr, proxy.listeningPort),
-				err,
-			)
-		}
-	}()
+// reportLocalhostProxiesErrors logs the passed
+// errors that were not reported during the
+// previous reconciliation (or that changed)
+func reportLocalhostProxiesErrors(proxiesErrors map[string]error) {
+	reportedErrors := map[string]string{}
 
-	for {
-		proxyConn, err := proxy.netListener.Accept()
-
-		if err != nil {
-			select {
-			case <-proxy.doneChan:
-				return
-			default:
-				log.Printf(
-					"[Localhost proxies] Error when accepting connection on proxy for %s: %v",
-					net.JoinHostPort(proxy.targetAddr, proxy.listeningPort),
-					err,
-				)
-
-				continue
-			}
-		}
-
-		localConn, err := connectToLocalhostTarget(proxy)
-
-		if err != nil {
-			log.Printf(
-				"[Localhost proxies] Error when connecting to %s: %v",
-				net.JoinHostPort(proxy.targetAddr, proxy.listeningPort),
-				err,
-			)
-
-			if err := proxyConn.Close(); err != nil {
-				log.Printf(
-					"[Localhost proxies] Error when closing proxy connection: %v",
-					err,
-				)
-			}
+	for addr, err := range proxiesErrors {
+		reportedErrors[addr] = err.Error()
 
+		if localhostProxiesErrors[addr] == err.Error() {
 			continue
 		}
 
-		go forwardProxyConnToLocalhost(
-			proxyConn,
-			localConn,
-		)
+		log.Printf("[Localhost proxies] Error for %s: %v", addr, err)
 	}
-}
 
-func connectToLocalhostTarget(proxy *localhostProxy) (net.Conn, error) {
-	return net.Dial(
-		"tcp",
-		net.JoinHostPort(proxy.targetAddr, proxy.listeningPort),
-	)
+	localhostProxiesErrors = reportedErrors
 }
 
-func forwardProxyConnToLocalhost(
-	proxyConn net.Conn,
-	localConn net.Conn,
-) error {
-
-	defer func() {
-		proxyConn.Close()
-		localConn.Close()
-	}()
-
-	proxyConnChan := make(chan error, 1)
-	localConnChan := make(chan error, 1)
-
-	// Forward local -> proxy
-	go func() {
-		_, err := io.Copy(proxyConn, localConn)
-		localConnChan <- err
-	}()
-
-	// Forward proxy -> local
-	go func() {
-		_, err := io.Copy(localConn, proxyConn)
-		proxyConnChan <- err
-	}()
-
-	select {
-	case proxyConnErr := <-proxyConnChan:
-		if proxyConnErr != nil {
-			log.Printf(
-				"[Localhost proxies] Error during proxy connection forwarding: %v",
-				proxyConnErr,
-			)
-		}
-		return proxyConnErr
-	case localConnErr := <-localConnChan:
-		if localConnErr != nil {
-			log.Printf(
-				"[Localhost proxies] Error during local connection forwarding: %v",
-				localConnErr,
-			)
-		}
-		return localConnErr
-	}
+func isIPv4(IP net.IP) bool {
+	return IP.To4() != nil
 }
@@ -0,0 +1,498 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/network"
+)
+
+// Time given to the connections of a removed proxy to end
+const localhostProxyDrainTimeout = 5 * time.Second
+
+// Chunks let splice be used while counting bytes
+const countingWriterChunkSize = 1 << 20
+
+var ErrLocalhostProxyConnNotFound = errors.New("ErrLocalhostProxyConnNotFound")
+
+// Fields are int64 first to be 64-bit aligned
+type localhostProxyStats struct {
+	activeConns int64
+	totalConns  int64
+	bytesIn     int64
+	bytesOut    int64
+	errors      int64
+}
+
+type localhostProxyConn struct {
+	// Accessed atomically
+	bytesIn  int64
+	bytesOut int64
+
+	ID         uint64
+	remoteAddr string
+	startedAt  time.Time
+	proxyConn  net.Conn
+	localConn  net.Conn
+}
+
+func (c *localhostProxyConn) close() {
+	c.proxyConn.Close()
+	c.localConn.Close()
+}
+
+var lastLocalhostProxyConnID uint64
+
+type LocalhostProxyStats struct {
+	BindAddr    string
+	Port        string
+	TargetAddr  string
+	ActiveConns int64
+	TotalConns  int64
+	BytesIn     int64
+	BytesOut    int64
+	Errors      int64
+	Conns       []*LocalhostProxyConn
+}
+
+type LocalhostProxyConn struct {
+	ID         uint64
+	RemoteAddr string
+	StartedAt  time.Time
+	BytesIn    int64
+	BytesOut   int64
+}
+
+// All proxies are returned if no ports are passed
+func ListLocalhostProxies(ports []string) []*LocalhostProxyStats {
+	localhostProxiesLock.Lock()
+	defer localhostProxiesLock.Unlock()
+
+	portsSet := map[string]bool{}
+
+	for _, port := range ports {
+		portsSet[port] = true
+	}
+
+	proxiesStats := []*LocalhostProxyStats{}
+
+	for _, proxy := range localhostProxies {
+		if len(portsSet) > 0 && !portsSet[proxy.listeningPort] {
+			continue
+		}
+
+		proxiesStats = append(proxiesStats, buildLocalhostProxyStats(proxy))
+	}
+
+	sort.Slice(proxiesStats, func(i, j int) bool {
+		if proxiesStats[i].Port != proxiesStats[j].Port {
+			return proxiesStats[i].Port < proxiesStats[j].Port
+		}
+
+		return proxiesStats[i].BindAddr < proxiesStats[j].BindAddr
+	})
+
+	return proxiesStats
+}
+
+func CloseLocalhostProxyConn(ID uint64) error {
+	localhostProxiesLock.Lock()
+	defer localhostProxiesLock.Unlock()
+
+	for _, proxy := range localhostProxies {
+		proxy.connsLock.Lock()
+		conn, hasConn := proxy.conns[ID]
+		proxy.connsLock.Unlock()
+
+		if hasConn {
+			conn.close()
+			return nil
+		}
+	}
+
+	return ErrLocalhostProxyConnNotFound
+}
+
+func buildLocalhostProxyStats(proxy *localhostProxy) *LocalhostProxyStats {
+	proxyStats := &LocalhostProxyStats{
+		BindAddr:    proxy.bindAddr,
+		Port:        proxy.listeningPort,
+		TargetAddr:  proxy.targetAddr,
+		ActiveConns: atomic.LoadInt64(&proxy.stats.activeConns),
+		TotalConns:  atomic.LoadInt64(&proxy.stats.totalConns),
+		BytesIn:     atomic.LoadInt64(&proxy.stats.bytesIn),
+		BytesOut:    atomic.LoadInt64(&proxy.stats.bytesOut),
+		Errors:      atomic.LoadInt64(&proxy.stats.errors),
+		Conns:       []*LocalhostProxyConn{},
+	}
+
+	proxy.connsLock.Lock()
+	defer proxy.connsLock.Unlock()
+
+	for _, conn := range proxy.conns {
+		proxyStats.Conns = append(proxyStats.Conns, &LocalhostProxyConn{
+			ID:         conn.ID,
+			RemoteAddr: conn.remoteAddr,
+			StartedAt:  conn.startedAt,
+			BytesIn:    atomic.LoadInt64(&conn.bytesIn),
+			BytesOut:   atomic.LoadInt64(&conn.bytesOut),
+		})
+	}
+
+	sort.Slice(proxyStats.Conns, func(i, j int) bool {
+		return proxyStats.Conns[i].ID < proxyStats.Conns[j].ID
+	})
+
+	return proxyStats
+}
+
+// The listener is closed synchronously given that
+// the proxy may be restarted on the same address
+func stopLocalhostProxy(proxy *localhostProxy) {
+	close(proxy.doneChan)
+
+	if err := proxy.netListener.Close(); err != nil {
+		log.Printf(
+			"[Localhost proxies] Error when closing proxy for %s: %v",
+			proxy.target(),
+			err,
+		)
+	}
+
+	go drainLocalhostProxyConns(proxy)
+}
+
+func handleLocalhostProxyConn(proxy *localhostProxy) {
+	for {
+		proxyConn, err := proxy.netListener.Accept()
+
+		if err != nil {
+			select {
+			case <-proxy.doneChan:
+				return
+			default:
+				atomic.AddInt64(&proxy.stats.errors, 1)
+
+				log.Printf(
+					"[Localhost proxies] Error when accepting connection on proxy for %s: %v",
+					proxy.target(),
+					err,
+				)
+
+				continue
+			}
+		}
+
+		localConn, err := connectToLocalhostTarget(proxy)
+
+		if err != nil {
+			atomic.AddInt64(&proxy.stats.errors, 1)
+
+			log.Printf(
+				"[Localhost proxies] Error when connecting to %s: %v",
+				proxy.target(),
+				err,
+			)
+
+			if err := proxyConn.Close(); err != nil {
+				log.Printf(
+					"[Localhost proxies] Error when closing proxy connection: %v",
+					err,
+				)
+			}
+
+			continue
+		}
+
+		if len(proxy.proxyProtocol) > 0 {
+			err := writeProxyProtocolHeader(proxy.proxyProtocol, proxyConn, localConn)
+
+			if err != nil {
+				atomic.AddInt64(&proxy.stats.errors, 1)
+
+				log.Printf(
+					"[Localhost proxies] Error when sending PROXY protocol header to %s: %v",
+					proxy.target(),
+					err,
+				)
+
+				proxyConn.Close()
+				localConn.Close()
+
+				continue
+			}
+		}
+
+		conn := &localhostProxyConn{
+			ID:         atomic.AddUint64(&lastLocalhostProxyConnID, 1),
+			remoteAddr: proxyConn.RemoteAddr().String(),
+			startedAt:  time.Now(),
+			proxyConn:  proxyConn,
+			localConn:  localConn,
+		}
+
+		if !trackLocalhostProxyConn(proxy, conn) {
+			// Proxy stopped while connecting
+			conn.close()
+			continue
+		}
+
+		go func() {
+			defer untrackLocalhostProxyConn(proxy, conn)
+
+			err := forwardProxyConnToLocalhost(proxy, conn)
+
+			if err != nil {
+				atomic.AddInt64(&proxy.stats.errors, 1)
+			}
+		}()
+	}
+}
+
+// Returns false if the proxy is stopped (connections may not be drained anymore)
+func trackLocalhostProxyConn(
+	proxy *localhostProxy,
+	conn *localhostProxyConn,
+) bool {
+
+	proxy.connsLock.Lock()
+	defer proxy.connsLock.Unlock()
+
+	select {
+	case <-proxy.doneChan:
+		return false
+	default:
+	}
+
+	atomic.AddInt64(&proxy.stats.activeConns, 1)
+	atomic.AddInt64(&proxy.stats.totalConns, 1)
+
+	proxy.conns[conn.ID] = conn
+
+	return true
+}
+
+func untrackLocalhostProxyConn(proxy *localhostProxy, conn *localhostProxyConn) {
+	atomic.AddInt64(&proxy.stats.activeConns, -1)
+
+	proxy.connsLock.Lock()
+	defer proxy.connsLock.Unlock()
+
+	delete(proxy.conns, conn.ID)
+}
+
+func drainLocalhostProxyConns(proxy *localhostProxy) {
+	drainDeadline := time.Now().Add(localhostProxyDrainTimeout)
+
+	for atomic.LoadInt64(&proxy.stats.activeConns) > 0 &&
+		time.Now().Before(drainDeadline) {
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	proxy.connsLock.Lock()
+	defer proxy.connsLock.Unlock()
+
+	for _, conn := range proxy.conns {
+		conn.close()
+	}
+}
+
+func connectToLocalhostTarget(proxy *localhostProxy) (net.Conn, error) {
+	if len(proxy.targetUnixSocket) > 0 {
+		// The socket could be replaced by a symlink
+		socketPath, err := env.ResolveWorkspaceUnixSocket(proxy.targetUnixSocket)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return net.Dial("unix", socketPath)
+	}
+
+	localConn, err := net.Dial(
+		"tcp",
+		net.JoinHostPort(proxy.targetAddr, proxy.targetPort),
+	)
+
+	if err != nil && len(proxy.fallbackTargetAddr) > 0 {
+		fallbackLocalConn, fallbackErr := net.Dial(
+			"tcp",
+			net.JoinHostPort(proxy.fallbackTargetAddr, proxy.targetPort),
+		)
+
+		if fallbackErr == nil {
+			return fallbackLocalConn, nil
+		}
+	}
+
+	return localConn, err
+}
+
+func writeProxyProtocolHeader(
+	version env.ConfigProxyProtocolVersion,
+	proxyConn net.Conn,
+	localConn net.Conn,
+) error {
+
+	srcAddr, srcIsTCP := proxyConn.RemoteAddr().(*net.TCPAddr)
+	dstAddr, dstIsTCP := proxyConn.LocalAddr().(*net.TCPAddr)
+
+	if !srcIsTCP || !dstIsTCP {
+		return errors.New("PROXY protocol is only supported for TCP connections")
+	}
+
+	var header []byte
+
+	switch version {
+	case env.ConfigProxyProtocolV1:
+		header = network.BuildProxyProtocolV1Header(srcAddr, dstAddr)
+	case env.ConfigProxyProtocolV2:
+		header = network.BuildProxyProtocolV2Header(srcAddr, dstAddr)
+	default:
+		return fmt.Errorf("unsupported PROXY protocol version \"%s\"", version)
+	}
+
+	_, err := localConn.Write(header)
+	return err
+}
+
+func forwardProxyConnToLocalhost(
+	proxy *localhostProxy,
+	conn *localhostProxyConn,
+) error {
+
+	defer conn.close()
+
+	proxyConnChan := make(chan error, 1)
+	localConnChan := make(chan error, 1)
+
+	// Forward local -> proxy
+	go func() {
+		_, err := io.Copy(
+			&countingWriter{
+				Writer:   conn.proxyConn,
+				counters: []*int64{&conn.bytesOut, &proxy.stats.bytesOut},
+			},
+			conn.localConn,
+		)
+
+		localConnChan <- err
+	}()
+
+	// Forward proxy -> local
+	go func() {
+		_, err := io.Copy(
+			&countingWriter{
+				Writer:   conn.localConn,
+				counters: []*int64{&conn.bytesIn, &proxy.stats.bytesIn},
+			},
+			conn.proxyConn,
+		)
+
+		proxyConnChan <- err
+	}()
+
+	select {
+	case proxyConnErr := <-proxyConnChan:
+		if proxyConnErr != nil && !errors.Is(proxyConnErr, net.ErrClosed) {
+			log.Printf(
+				"[Localhost proxies] Error during proxy connection forwarding: %v",
+				proxyConnErr,
+			)
+
+			return proxyConnErr
+		}
+	case localConnErr := <-localConnChan:
+		if localConnErr != nil && !errors.Is(localConnErr, net.ErrClosed) {
+			log.Printf(
+				"[Localhost proxies] Error during local connection forwarding: %v",
+				localConnErr,
+			)
+
+			return localConnErr
+		}
+	}
+
+	return nil
+}
+
+type countingWriter struct {
+	io.Writer
+	counters []*int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.Writer.Write(data)
+	c.count(int64(n))
+
+	return n, err
+}
+
+// Small reads are written as is to keep counters up to date
+// for interactive connections (splice blocks until a chunk is full)
+func (c *countingWriter) ReadFrom(src io.Reader) (int64, error) {
+	readerFrom, hasReaderFrom := c.Writer.(io.ReaderFrom)
+
+	buffer := make([]byte, 32*1024)
+	written := int64(0)
+
+	for {
+		readLen, readErr := src.Read(buffer)
+
+		if readLen > 0 {
+			writeLen, err := c.Write(buffer[:readLen])
+			written += int64(writeLen)
+
+			if err != nil {
+				return written, err
+			}
+
+			if writeLen != readLen {
+				return written, io.ErrShortWrite
+			}
+		}
+
+		if readErr == io.EOF {
+			return written, nil
+		}
+
+		if readErr != nil {
+			return written, readErr
+		}
+
+		if !hasReaderFrom || readLen < len(buffer) {
+			continue
+		}
+
+		chunkLen, err := readerFrom.ReadFrom(&io.LimitedReader{
+			R: src,
+			N: countingWriterChunkSize,
+		})
+
+		c.count(chunkLen)
+		written += chunkLen
+
+		if err != nil {
+			return written, err
+		}
+
+		// Source ended before the end of the chunk
+		if chunkLen < countingWriterChunkSize {
+			return written, nil
+		}
+	}
+}
+
+func (c *countingWriter) count(n int64) {
+	for _, counter := range c.counters {
+		atomic.AddInt64(counter, n)
+	}
+}
@@ -0,0 +1,233 @@
+package state
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLocalhostProxyConns(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer target.Close()
+
+	// Echo server
+	go func() {
+		for {
+			conn, err := target.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go io.Copy(conn, conn)
+		}
+	}()
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	targetAddr, targetPort, _ := net.SplitHostPort(target.Addr().String())
+	_, proxyPort, _ := net.SplitHostPort(proxyListener.Addr().String())
+
+	proxy := &localhostProxy{
+		bindAddr:      "127.0.0.1",
+		listeningPort: proxyPort,
+		targetAddr:    targetAddr,
+		targetPort:    targetPort,
+		netListener:   proxyListener,
+		doneChan:      make(chan struct{}),
+		conns:         map[uint64]*localhostProxyConn{},
+	}
+
+	localhostProxiesLock.Lock()
+	localhostProxies[localhostListenerID(proxyListener.Addr().String())] = proxy
+	localhostProxiesLock.Unlock()
+
+	defer func() {
+		localhostProxiesLock.Lock()
+		delete(localhostProxies, localhostListenerID(proxyListener.Addr().String()))
+		localhostProxiesLock.Unlock()
+	}()
+
+	go handleLocalhostProxyConn(proxy)
+
+	conn, err := net.Dial("tcp", proxyListener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer conn.Close()
+
+	conn.Write([]byte("hello"))
+
+	echo := make([]byte, 5)
+
+	if _, err := io.ReadFull(conn, echo); err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	proxiesStats := ListLocalhostProxies([]string{proxyPort})
+
+	if len(proxiesStats) != 1 {
+		t.Fatalf("expected one proxy, got '%d'", len(proxiesStats))
+	}
+
+	proxyStats := proxiesStats[0]
+
+	if proxyStats.ActiveConns != 1 ||
+		proxyStats.TotalConns != 1 ||
+		proxyStats.BytesIn != 5 ||
+		proxyStats.BytesOut != 5 ||
+		len(proxyStats.Conns) != 1 {
+
+		t.Fatalf("unexpected proxy stats '%+v'", proxyStats)
+	}
+
+	err = CloseLocalhostProxyConn(proxyStats.Conns[0].ID)
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	if _, err := conn.Read(echo); err != io.EOF {
+		t.Fatalf("expected error to equal '%+v', got '%+v'", io.EOF, err)
+	}
+
+	stopLocalhostProxy(proxy)
+}
+
+func TestCountingWriterReadFrom(t *testing.T) {
+	testCases := []struct {
+		test        string
+		payloadSize int
+	}{
+		{
+			test:        "with small payload",
+			payloadSize: 5,
+		},
+
+		{
+			test:        "with payload bigger than chunk",
+			payloadSize: 2*countingWriterChunkSize + 1000,
+		},
+
+		{
+			test:        "with payload ending with a full chunk",
+			payloadSize: countingWriterChunkSize + 32*1024,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			srcConn, srcPeerConn := newTCPConnPair(t)
+			defer srcConn.Close()
+			defer srcPeerConn.Close()
+
+			dstConn, dstPeerConn := newTCPConnPair(t)
+			defer dstConn.Close()
+			defer dstPeerConn.Close()
+
+			payload := bytes.Repeat([]byte("a"), tc.payloadSize)
+
+			go func() {
+				srcPeerConn.Write(payload)
+				srcPeerConn.Close()
+			}()
+
+			receivedChan := make(chan []byte, 1)
+
+			go func() {
+				received, _ := io.ReadAll(dstPeerConn)
+				receivedChan <- received
+			}()
+
+			var counter int64
+
+			written, err := io.Copy(
+				&countingWriter{
+					Writer:   dstConn,
+					counters: []*int64{&counter},
+				},
+				srcConn,
+			)
+
+			if err != nil {
+				t.Fatalf("expected no error, got '%+v'", err)
+			}
+
+			dstConn.Close()
+
+			if written != int64(tc.payloadSize) || counter != written {
+				t.Fatalf(
+					"expected written bytes and counter to equal '%d', got '%d' and '%d'",
+					tc.payloadSize,
+					written,
+					counter,
+				)
+			}
+
+			if received := <-receivedChan; !bytes.Equal(received, payload) {
+				t.Fatalf("expected %d bytes to be received, got %d", len(payload), len(received))
+			}
+		})
+	}
+}
+
+func TestTrackLocalhostProxyConnOnStoppedProxy(t *testing.T) {
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	proxy := &localhostProxy{
+		netListener: proxyListener,
+		doneChan:    make(chan struct{}),
+		conns:       map[uint64]*localhostProxyConn{},
+	}
+
+	stopLocalhostProxy(proxy)
+
+	tracked := trackLocalhostProxyConn(proxy, &localhostProxyConn{ID: 1})
+
+	if tracked || len(proxy.conns) > 0 || proxy.stats.activeConns > 0 {
+		t.Fatalf("expected connection to not be tracked on stopped proxy")
+	}
+}
+
+func newTCPConnPair(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	peerConn, err := listener.Accept()
+
+	if err != nil {
+		t.Fatalf("expected no error, got '%+v'", err)
+	}
+
+	return conn, peerConn
+}
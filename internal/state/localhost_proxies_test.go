@@ -0,0 +1,100 @@
+package state
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+)
+
+func TestBuildLocalhostListeners(t *testing.T) {
+	testCases := []struct {
+		test              string
+		loopbackAddrs     map[string][]net.IP
+		bindIPs           []net.IP
+		proxyProtocols    env.ConfigProxyProtocols
+		expectedListeners localhostListeners
+	}{
+		{
+			test: "with IPv4 loopback address",
+			loopbackAddrs: map[string][]net.IP{
+				"8080": {net.ParseIP("127.0.0.1")},
+			},
+			bindIPs: []net.IP{
+				net.ParseIP("10.0.0.2"),
+				net.ParseIP("2001:db8::2"),
+			},
+			expectedListeners: localhostListeners{
+				"10.0.0.2:8080": {
+					listeningAddr: "127.0.0.1",
+					listeningPort: "8080",
+					bindAddr:      "10.0.0.2",
+				},
+				"[2001:db8::2]:8080": {
+					listeningAddr: "127.0.0.1",
+					listeningPort: "8080",
+					bindAddr:      "2001:db8::2",
+				},
+			},
+		},
+
+		{
+			test: "with IPv4 and IPv6 loopback addresses",
+			loopbackAddrs: map[string][]net.IP{
+				"8080": {
+					net.ParseIP("127.0.0.1"),
+					net.ParseIP("::1"),
+				},
+			},
+			bindIPs: []net.IP{
+				net.ParseIP("10.0.0.2"),
+				net.ParseIP("2001:db8::2"),
+			},
+			proxyProtocols: env.ConfigProxyProtocols{
+				"8080": env.ConfigProxyProtocolV2,
+			},
+			expectedListeners: localhostListeners{
+				"10.0.0.2:8080": {
+					listeningAddr: "127.0.0.1",
+					listeningPort: "8080",
+					bindAddr:      "10.0.0.2",
+					proxyProtocol: env.ConfigProxyProtocolV2,
+				},
+				"[2001:db8::2]:8080": {
+					listeningAddr: "::1",
+					listeningPort: "8080",
+					bindAddr:      "2001:db8::2",
+					proxyProtocol: env.ConfigProxyProtocolV2,
+				},
+			},
+		},
+
+		{
+			test: "without bind addresses",
+			loopbackAddrs: map[string][]net.IP{
+				"8080": {net.ParseIP("::1")},
+			},
+			bindIPs:           []net.IP{},
+			expectedListeners: localhostListeners{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			listeners := buildLocalhostListeners(
+				tc.loopbackAddrs,
+				tc.bindIPs,
+				tc.proxyProtocols,
+			)
+
+			if !reflect.DeepEqual(listeners, tc.expectedListeners) {
+				t.Fatalf(
+					"expected listeners to equal '%+v', got '%+v'",
+					tc.expectedListeners,
+					listeners,
+				)
+			}
+		})
+	}
+}
@@ -40,6 +40,7 @@ func ReconcileLongRunningProcesses(
 		}
 
 		clearProcess(currentProcess)
+		forgetProcessStatus(currentProcessWD)
 	}
 
 	for newProcessWD, newProcessCmd := range newProcesses {
@@ -130,6 +131,8 @@ func killProcess(cmd *exec.Cmd) error {
 func waitForProcess(p *process) error {
 	unexpectedProcessExit := false
 
+	go watchProcessListeningPorts(p)
+
 	go func() {
 		<-p.doneChan
 
@@ -158,6 +161,7 @@ func waitForProcess(p *process) error {
 		// already killed process
 		unexpectedProcessExit = true
 		clearProcess(p)
+		recordProcessExit(p, err)
 
 		log.Printf(
 			"[Forever] Unexpected exit for process %s:%s: %v",
@@ -347,6 +351,19 @@ func saveLongRunningProcess(p *process) error {
 
 	agentConfig.LongRunningProcesses[p.cmdWD] = p.cmdString
 
+	ports, err := getProcessListeningPorts(p)
+
+	if err != nil {
+		return err
+	}
+
+	if agentConfig.LongRunningProcessesPorts == nil {
+		agentConfig.LongRunningProcessesPorts = env.ConfigLongRunningProcessesPorts{}
+	}
+
+	agentConfig.LongRunningProcessesPorts[p.cmdWD] = ports
+	recordProcessListeningPorts(p.cmdWD, ports)
+
 	currentProcessesLock.Lock()
 	defer currentProcessesLock.Unlock()
 
@@ -0,0 +1,251 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/network"
+	"github.com/prometheus/procfs"
+)
+
+type LongRunningProcessStatus struct {
+	WD             string
+	Cmd            string
+	Running        bool
+	LastExitReason string
+	LastExitedAt   time.Time
+}
+
+type processExit struct {
+	reason   string
+	exitedAt time.Time
+}
+
+// Latest process (by working directory) that has listened
+// on each port. Kept between the restarts of the same command.
+var processesByPort = map[string]env.ConfigLongRunningProcessWD{}
+var processesLastExit = map[env.ConfigLongRunningProcessWD]*processExit{}
+var processesStatusLock sync.Mutex
+
+const processListeningPortsPollInterval = 2 * time.Second
+
+// GetLongRunningProcessStatusForPort returns the status
+// of the configured long running process that listens
+// on the passed port, or nil if there is none.
+func GetLongRunningProcessStatusForPort(
+	agentConfig *env.Config,
+	port string,
+) *LongRunningProcessStatus {
+
+	processesStatusLock.Lock()
+	defer processesStatusLock.Unlock()
+
+	processWD, hasProcess := findLongRunningProcessForPort(
+		agentConfig,
+		processesByPort,
+		port,
+	)
+
+	if !hasProcess {
+		return nil
+	}
+
+	status := &LongRunningProcessStatus{
+		WD:  string(processWD),
+		Cmd: string(agentConfig.LongRunningProcesses[processWD]),
+	}
+
+	currentProcessesLock.Lock()
+	_, status.Running = currentProcesses[processWD]
+	currentProcessesLock.Unlock()
+
+	if lastExit, hasExited := processesLastExit[processWD]; hasExited {
+		status.LastExitReason = lastExit.reason
+		status.LastExitedAt = lastExit.exitedAt
+	}
+
+	return status
+}
+
+// findLongRunningProcessForPort looks up the latest process that
+// has listened on the passed port then the ports listened on by
+// the configured processes when started (for the processes that
+// exit before listening once the agent has restarted).
+func findLongRunningProcessForPort(
+	agentConfig *env.Config,
+	processesByPort map[string]env.ConfigLongRunningProcessWD,
+	port string,
+) (env.ConfigLongRunningProcessWD, bool) {
+
+	if processWD, hasProcess := processesByPort[port]; hasProcess {
+		if _, isConfigured := agentConfig.LongRunningProcesses[processWD]; isConfigured {
+			return processWD, true
+		}
+	}
+
+	processesWD := []string{}
+
+	for processWD := range agentConfig.LongRunningProcesses {
+		processesWD = append(processesWD, string(processWD))
+	}
+
+	sort.Strings(processesWD)
+
+	for _, processWD := range processesWD {
+		ports := agentConfig.LongRunningProcessesPorts[env.ConfigLongRunningProcessWD(processWD)]
+
+		for _, processPort := range ports {
+			if processPort == port {
+				return env.ConfigLongRunningProcessWD(processWD), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func recordProcessListeningPorts(
+	processWD env.ConfigLongRunningProcessWD,
+	ports []string,
+) {
+
+	processesStatusLock.Lock()
+	defer processesStatusLock.Unlock()
+
+	for _, port := range ports {
+		processesByPort[port] = processWD
+	}
+}
+
+// forgetProcessStatus is called when
+// a process is stopped or replaced
+func forgetProcessStatus(processWD env.ConfigLongRunningProcessWD) {
+	processesStatusLock.Lock()
+	defer processesStatusLock.Unlock()
+
+	for port, portProcessWD := range processesByPort {
+		if portProcessWD == processWD {
+			delete(processesByPort, port)
+		}
+	}
+
+	delete(processesLastExit, processWD)
+}
+
+func recordProcessExit(p *process, exitErr error) {
+	processesStatusLock.Lock()
+	defer processesStatusLock.Unlock()
+
+	exitReason := "exit status 0"
+
+	if exitErr != nil {
+		exitReason = exitErr.Error()
+	}
+
+	processesLastExit[p.cmdWD] = &processExit{
+		reason:   exitReason,
+		exitedAt: time.Now(),
+	}
+}
+
+func watchProcessListeningPorts(p *process) {
+	for {
+		ports, err := getProcessListeningPorts(p)
+
+		// The process may have exited
+		// between two calls
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			log.Printf(
+				"[Forever] Error when looking up ports for process %s:%s: %v",
+				p.cmdWD,
+				p.cmdString,
+				err,
+			)
+		}
+
+		recordProcessListeningPorts(p.cmdWD, ports)
+
+		select {
+		case <-p.doneChan:
+			return
+		case <-time.After(processListeningPortsPollInterval):
+		}
+	}
+}
+
+func getProcessListeningPorts(p *process) ([]string, error) {
+	processGrpID, err := syscall.Getpgid(p.cmd.Process.Pid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	processes, err := procfs.AllProcs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	socketInodes := map[string]bool{}
+
+	for _, process := range processes {
+		st, err := process.Stat()
+
+		if err != nil {
+			// Race condition
+			continue
+		}
+
+		if st.PGRP != processGrpID {
+			continue
+		}
+
+		fdTargets, err := process.FileDescriptorTargets()
+
+		if err != nil {
+			// Race condition
+			continue
+		}
+
+		for _, fdTarget := range fdTargets {
+			// Sockets are represented as "socket:[<inode>]"
+			if !strings.HasPrefix(fdTarget, "socket:[") {
+				continue
+			}
+
+			socketInodes[strings.TrimSuffix(
+				strings.TrimPrefix(fdTarget, "socket:["),
+				"]",
+			)] = true
+		}
+	}
+
+	tcpConns, err := network.GetOpenedTCPConns()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []string{}
+
+	for _, conn := range tcpConns {
+		if conn.St != uint64(network.TCPConnStatusListening) {
+			continue
+		}
+
+		if !socketInodes[fmt.Sprintf("%d", conn.Inode)] {
+			continue
+		}
+
+		ports = append(ports, fmt.Sprintf("%d", conn.LocalPort))
+	}
+
+	return ports, nil
+}
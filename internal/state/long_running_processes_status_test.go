@@ -0,0 +1,87 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/eleven-sh/agent/internal/env"
+)
+
+func TestFindLongRunningProcessForPort(t *testing.T) {
+	agentConfig := &env.Config{
+		LongRunningProcesses: env.ConfigLongRunningProcesses{
+			"/home/eleven/workspace/api":      "npm start",
+			"/home/eleven/workspace/website":  "npm run dev",
+			"/home/eleven/workspace/worker":   "npm run worker",
+			"/home/eleven/workspace/database": "docker compose up",
+		},
+		LongRunningProcessesPorts: env.ConfigLongRunningProcessesPorts{
+			"/home/eleven/workspace/api":     {"8080"},
+			"/home/eleven/workspace/website": {"3000", "8080"},
+			"/home/eleven/workspace/stopped": {"4000"},
+		},
+	}
+
+	processesByPort := map[string]env.ConfigLongRunningProcessWD{
+		"3000": "/home/eleven/workspace/worker",
+		"5000": "/home/eleven/workspace/stopped",
+	}
+
+	testCases := []struct {
+		test            string
+		port            string
+		expectedProcess env.ConfigLongRunningProcessWD
+		expectedFound   bool
+	}{
+		{
+			test:            "with latest process that has listened on port",
+			port:            "3000",
+			expectedProcess: "/home/eleven/workspace/worker",
+			expectedFound:   true,
+		},
+
+		{
+			test:            "with port listened on when started",
+			port:            "8080",
+			expectedProcess: "/home/eleven/workspace/api",
+			expectedFound:   true,
+		},
+
+		{
+			test:          "with port of not configured process",
+			port:          "4000",
+			expectedFound: false,
+		},
+
+		{
+			test:          "with port of not configured latest process",
+			port:          "5000",
+			expectedFound: false,
+		},
+
+		{
+			test:          "with unknown port",
+			port:          "9000",
+			expectedFound: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.test, func(t *testing.T) {
+			process, found := findLongRunningProcessForPort(
+				agentConfig,
+				processesByPort,
+				tc.port,
+			)
+
+			if found != tc.expectedFound || process != tc.expectedProcess {
+				t.Fatalf(
+					"expected process to equal '%s' (%v), got '%s' (%v)",
+					tc.expectedProcess,
+					tc.expectedFound,
+					process,
+					found,
+				)
+			}
+		})
+	}
+}
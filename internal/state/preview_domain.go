@@ -0,0 +1,89 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/network"
+	"github.com/eleven-sh/agent/internal/proxy"
+)
+
+// GetListeningPorts returns the sorted list
+// of TCP ports that are listened on (on any interface).
+func GetListeningPorts() ([]string, error) {
+	tcpConns, err := network.GetOpenedTCPConns()
+
+	if err != nil {
+		return nil, err
+	}
+
+	portsSet := map[string]bool{}
+
+	for _, conn := range tcpConns {
+		if conn.St != uint64(network.TCPConnStatusListening) {
+			continue
+		}
+
+		portsSet[fmt.Sprintf("%d", conn.LocalPort)] = true
+	}
+
+	ports := []string{}
+
+	for port := range portsSet {
+		ports = append(ports, port)
+	}
+
+	sort.Strings(ports)
+
+	return ports, nil
+}
+
+// ReconcilePreviewDomain updates the state served by the passed
+// proxy when the ports served on the preview domain change.
+func ReconcilePreviewDomain(
+	p proxy.Proxy,
+	previewDomain *env.ConfigPreviewDomain,
+) {
+
+	if previewDomain == nil {
+		return
+	}
+
+	listeningPorts, err := GetListeningPorts()
+
+	if err != nil {
+		log.Printf(
+			"[Preview domain] Error when listing listening ports: %v",
+			err,
+		)
+
+		return
+	}
+
+	err = proxy.Update(p, func(state *proxy.State) error {
+		// Preview domain config not applied yet (or already updated)
+		if state.PreviewDomain == nil ||
+			state.PreviewDomain.Domain != previewDomain.Domain {
+
+			return nil
+		}
+
+		state.PreviewPorts = proxy.GetPreviewPorts(
+			state.PreviewDomain,
+			listeningPorts,
+		)
+
+		return nil
+	})
+
+	// The proxy may be restarting.
+	// Retried on next call.
+	if err != nil {
+		log.Printf(
+			"[Preview domain] Error when updating preview ports: %v",
+			err,
+		)
+	}
+}
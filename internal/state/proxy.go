@@ -0,0 +1,54 @@
+package state
+
+import (
+	"log"
+
+	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/proxy"
+	"github.com/eleven-sh/agent/proto"
+)
+
+// ReconcileProxy applies the persisted served ports to the
+// passed proxy on agent startup and re-applies the served
+// state when it drifts (eg: Caddy restarted without its config).
+func ReconcileProxy(
+	p proxy.Proxy,
+	servedPortsBindings map[string]*proto.EnvServedPortBindings,
+	previewDomain *env.ConfigPreviewDomain,
+) {
+
+	err := proxy.Restore(p, func() (*proxy.State, error) {
+		initialState := proxy.NewState()
+
+		for port, bindings := range servedPortsBindings {
+			initialState.ServedPorts[port] = bindings
+		}
+
+		if previewDomain == nil {
+			return initialState, nil
+		}
+
+		listeningPorts, err := GetListeningPorts()
+
+		if err != nil {
+			return nil, err
+		}
+
+		initialState.PreviewDomain = previewDomain
+		initialState.PreviewPorts = proxy.GetPreviewPorts(
+			previewDomain,
+			listeningPorts,
+		)
+
+		return initialState, nil
+	})
+
+	// The proxy may be starting.
+	// Retried on next call.
+	if err != nil {
+		log.Printf(
+			"[Proxy] Error when restoring served ports: %v",
+			err,
+		)
+	}
+}
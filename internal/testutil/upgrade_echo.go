@@ -0,0 +1,40 @@
+package testutil
+
+import (
+	"net/http"
+	"testing"
+)
+
+// NewUpgradeEchoHandler returns a handler that upgrades the
+// connections to an "echo" protocol that echoes each line
+func NewUpgradeEchoHandler(t testing.TB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		conn, readWriter, err := w.(http.Hijacker).Hijack()
+
+		if err != nil {
+			t.Errorf("expected no error, got '%+v'", err)
+			return
+		}
+
+		defer conn.Close()
+
+		readWriter.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		readWriter.Flush()
+
+		for {
+			line, err := readWriter.ReadString('\n')
+
+			if err != nil {
+				return
+			}
+
+			readWriter.WriteString(line)
+			readWriter.Flush()
+		}
+	})
+}
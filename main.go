@@ -3,14 +3,21 @@ package main
 import (
 	"log"
 	"os"
+	"os/exec"
 	"time"
 
 	"github.com/eleven-sh/agent/config"
+	"github.com/eleven-sh/agent/internal/caddy"
 	"github.com/eleven-sh/agent/internal/env"
+	"github.com/eleven-sh/agent/internal/errorpage"
 	"github.com/eleven-sh/agent/internal/forever"
 	"github.com/eleven-sh/agent/internal/grpcserver"
+	"github.com/eleven-sh/agent/internal/httpproxy"
+	"github.com/eleven-sh/agent/internal/inspector"
+	"github.com/eleven-sh/agent/internal/proxy"
 	"github.com/eleven-sh/agent/internal/sshserver"
 	"github.com/eleven-sh/agent/internal/state"
+	"github.com/eleven-sh/agent/proto"
 )
 
 type Command string
@@ -22,8 +29,13 @@ const (
 var (
 	SSHServerAuthorizedUsers = []sshserver.AuthorizedUser{
 		{
-			UserName:               config.ElevenUserName,
-			AuthorizedKeysFilePath: config.ElevenUserAuthorizedSSHKeysFilePath,
+			UserName:                  config.ElevenUserName,
+			AuthorizedKeysFilePath:    config.ElevenUserAuthorizedSSHKeysFilePath,
+			TrustedUserCAKeysFilePath: config.ElevenUserTrustedSSHCAKeysFilePath,
+			AcceptedEnv:               config.SSHServerAcceptedEnv,
+			AllowedLocalForwards:      config.SSHServerAllowedLocalForwards,
+			AllowedReverseForwards:    config.SSHServerAllowedReverseForwards,
+			AllowedSocketPaths:        config.SSHServerAllowedSocketPaths,
 		},
 	}
 )
@@ -51,6 +63,9 @@ func main() {
 		)
 	}
 
+	envInspector := inspector.New()
+	envProxy := inspector.NewProxy(newProxy(), envInspector)
+
 	go func() {
 		log.Printf(
 			"gRPC server listening at: %s",
@@ -60,6 +75,8 @@ func main() {
 		err := grpcserver.ListenAndServe(
 			config.GRPCServerAddrProtocol,
 			config.GRPCServerAddr,
+			envProxy,
+			envInspector,
 		)
 
 		if err != nil {
@@ -69,30 +86,44 @@ func main() {
 
 	go func() {
 		log.Printf(
-			"Reconciling localhost proxies state...",
+			"Error page server listening at: %s",
+			config.ErrorPageServerListenAddr,
 		)
 
-		for {
-			servedPorts := env.ConfigServedPorts{}
+		err := errorpage.ListenAndServe(
+			config.ErrorPageServerListenAddr,
+		)
 
-			agentConfig, err := env.LoadConfigIfExists(
-				config.ElevenAgentConfigFilePath,
-			)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}()
 
-			if err != nil {
-				log.Fatalf("%v", err)
-			}
+	go func() {
+		log.Printf(
+			"Inspector server listening at: %s",
+			config.InspectorServerListenAddr,
+		)
+
+		err := inspector.ListenAndServe(
+			config.InspectorServerListenAddr,
+			envInspector,
+		)
 
-			if agentConfig != nil {
-				servedPorts = agentConfig.ServedPorts
-			}
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}()
 
-			err = state.ReconcileLocalhostProxies(servedPorts)
+	go func() {
+		log.Printf(
+			"Reconciling localhost proxies state...",
+		)
 
-			if err != nil {
-				log.Fatalf("%v", err)
-			}
+		reconcileErrors := map[string]string{}
 
+		for {
+			reconcileServedPortsState(envProxy, reconcileErrors)
 			time.Sleep(400 * time.Millisecond)
 		}
 	}()
@@ -102,27 +133,10 @@ func main() {
 			"Reconciling long running processes state...",
 		)
 
-		for {
-			longRunningProcesses := env.ConfigLongRunningProcesses{}
-
-			agentConfig, err := env.LoadConfigIfExists(
-				config.ElevenAgentConfigFilePath,
-			)
-
-			if err != nil {
-				log.Fatalf("%v", err)
-			}
-
-			if agentConfig != nil {
-				longRunningProcesses = agentConfig.LongRunningProcesses
-			}
-
-			err = state.ReconcileLongRunningProcesses(longRunningProcesses)
-
-			if err != nil {
-				log.Fatalf("%v", err)
-			}
+		reconcileErrors := map[string]string{}
 
+		for {
+			reconcileLongRunningProcessesState(reconcileErrors)
 			time.Sleep(400 * time.Millisecond)
 		}
 	}()
@@ -146,3 +160,117 @@ func main() {
 		log.Fatalf("%v", err)
 	}
 }
+
+// newProxy returns the Caddy proxy if Caddy is installed
+// or the pure Go proxy otherwise.
+func newProxy() proxy.Proxy {
+	if _, err := exec.LookPath("caddy"); err == nil {
+		log.Printf("Using Caddy to serve bindings")
+		return caddy.NewProxy()
+	}
+
+	log.Printf("Caddy not found. Using the built-in HTTP proxy to serve bindings")
+
+	return httpproxy.NewProxy(
+		config.HTTPProxyCertsDirPath,
+		config.CaddyAccessLogsDirPath,
+		config.ErrorPageServerListenAddr,
+		config.InspectorServerListenAddr,
+	)
+}
+
+func reconcileServedPortsState(
+	envProxy proxy.Proxy,
+	reconcileErrors map[string]string,
+) {
+
+	servedPorts := env.ConfigServedPorts{}
+	servedPortsBindings := map[string]*proto.EnvServedPortBindings{}
+	forwardedPorts := env.ConfigForwardedPorts{}
+	var previewDomain *env.ConfigPreviewDomain
+	proxyProtocols := env.ConfigProxyProtocols{}
+	var localhostProxies *env.ConfigLocalhostProxies
+
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	// Not reconciled with an empty config
+	// to not stop the served ports
+	if reportReconcileError(reconcileErrors, "loading agent config", err) {
+		return
+	}
+
+	if agentConfig != nil {
+		servedPorts = agentConfig.ServedPorts
+		servedPortsBindings = agentConfig.ServedPortsBindings
+		forwardedPorts = agentConfig.ForwardedPorts
+		previewDomain = agentConfig.PreviewDomain
+		proxyProtocols = agentConfig.ProxyProtocols
+		localhostProxies = agentConfig.LocalhostProxies
+	}
+
+	err = state.ReconcileLocalhostProxies(
+		servedPorts,
+		proxyProtocols,
+		localhostProxies,
+	)
+
+	reportReconcileError(reconcileErrors, "reconciling localhost proxies", err)
+
+	state.ReconcileForwardedPorts(forwardedPorts)
+
+	state.ReconcileProxy(
+		envProxy,
+		servedPortsBindings,
+		previewDomain,
+	)
+
+	state.ReconcilePreviewDomain(envProxy, previewDomain)
+}
+
+func reconcileLongRunningProcessesState(reconcileErrors map[string]string) {
+	longRunningProcesses := env.ConfigLongRunningProcesses{}
+
+	agentConfig, err := env.LoadConfigIfExists(
+		config.ElevenAgentConfigFilePath,
+	)
+
+	// Not reconciled with an empty config
+	// to not stop the running processes
+	if reportReconcileError(reconcileErrors, "loading agent config", err) {
+		return
+	}
+
+	if agentConfig != nil {
+		longRunningProcesses = agentConfig.LongRunningProcesses
+	}
+
+	err = state.ReconcileLongRunningProcesses(longRunningProcesses)
+
+	reportReconcileError(reconcileErrors, "reconciling long running processes", err)
+}
+
+// reportReconcileError logs the passed error if it was not
+// reported by the previous reconciliation (or if it changed)
+// and returns true if an error was passed. Reconciliation
+// errors don't stop the agent given that they may be transient.
+func reportReconcileError(
+	reportedErrors map[string]string,
+	step string,
+	err error,
+) bool {
+
+	if err == nil {
+		delete(reportedErrors, step)
+		return false
+	}
+
+	if reportedErrors[step] != err.Error() {
+		log.Printf("Error when %s: %v", step, err)
+	}
+
+	reportedErrors[step] = err.Error()
+
+	return true
+}
@@ -24,6 +24,20 @@ type AgentClient interface {
 	CheckDomainReachability(ctx context.Context, in *CheckDomainReachabilityRequest, opts ...grpc.CallOption) (Agent_CheckDomainReachabilityClient, error)
 	ReconcileServedPortsState(ctx context.Context, in *ReconcileServedPortsStateRequest, opts ...grpc.CallOption) (Agent_ReconcileServedPortsStateClient, error)
 	TryToStartLongRunningProcess(ctx context.Context, in *TryToStartLongRunningProcessRequest, opts ...grpc.CallOption) (Agent_TryToStartLongRunningProcessClient, error)
+	StreamAccessLogs(ctx context.Context, in *StreamAccessLogsRequest, opts ...grpc.CallOption) (Agent_StreamAccessLogsClient, error)
+	SetActiveUpstream(ctx context.Context, in *SetActiveUpstreamRequest, opts ...grpc.CallOption) (Agent_SetActiveUpstreamClient, error)
+	GetServedPortsState(ctx context.Context, in *GetServedPortsStateRequest, opts ...grpc.CallOption) (Agent_GetServedPortsStateClient, error)
+	ListInspectedRequests(ctx context.Context, in *ListInspectedRequestsRequest, opts ...grpc.CallOption) (Agent_ListInspectedRequestsClient, error)
+	StreamInspectedRequests(ctx context.Context, in *StreamInspectedRequestsRequest, opts ...grpc.CallOption) (Agent_StreamInspectedRequestsClient, error)
+	ReplayRequest(ctx context.Context, in *ReplayRequestRequest, opts ...grpc.CallOption) (Agent_ReplayRequestClient, error)
+	SetNetworkConditions(ctx context.Context, in *SetNetworkConditionsRequest, opts ...grpc.CallOption) (Agent_SetNetworkConditionsClient, error)
+	ListProxyConnections(ctx context.Context, in *ListProxyConnectionsRequest, opts ...grpc.CallOption) (Agent_ListProxyConnectionsClient, error)
+	CloseProxyConnection(ctx context.Context, in *CloseProxyConnectionRequest, opts ...grpc.CallOption) (Agent_CloseProxyConnectionClient, error)
+	WatchListeningPorts(ctx context.Context, in *WatchListeningPortsRequest, opts ...grpc.CallOption) (Agent_WatchListeningPortsClient, error)
+	ListSessionRecordings(ctx context.Context, in *ListSessionRecordingsRequest, opts ...grpc.CallOption) (Agent_ListSessionRecordingsClient, error)
+	StreamSessionRecording(ctx context.Context, in *StreamSessionRecordingRequest, opts ...grpc.CallOption) (Agent_StreamSessionRecordingClient, error)
+	DeleteSessionRecording(ctx context.Context, in *DeleteSessionRecordingRequest, opts ...grpc.CallOption) (Agent_DeleteSessionRecordingClient, error)
+	ValidateAuthorizedKeys(ctx context.Context, in *ValidateAuthorizedKeysRequest, opts ...grpc.CallOption) (Agent_ValidateAuthorizedKeysClient, error)
 }
 
 type agentClient struct {
@@ -194,6 +208,454 @@ func (x *agentTryToStartLongRunningProcessClient) Recv() (*TryToStartLongRunning
 	return m, nil
 }
 
+func (c *agentClient) StreamAccessLogs(ctx context.Context, in *StreamAccessLogsRequest, opts ...grpc.CallOption) (Agent_StreamAccessLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[5], "/eleven.agent.Agent/StreamAccessLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentStreamAccessLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_StreamAccessLogsClient interface {
+	Recv() (*StreamAccessLogsReply, error)
+	grpc.ClientStream
+}
+
+type agentStreamAccessLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentStreamAccessLogsClient) Recv() (*StreamAccessLogsReply, error) {
+	m := new(StreamAccessLogsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) SetActiveUpstream(ctx context.Context, in *SetActiveUpstreamRequest, opts ...grpc.CallOption) (Agent_SetActiveUpstreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[6], "/eleven.agent.Agent/SetActiveUpstream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentSetActiveUpstreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_SetActiveUpstreamClient interface {
+	Recv() (*SetActiveUpstreamReply, error)
+	grpc.ClientStream
+}
+
+type agentSetActiveUpstreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentSetActiveUpstreamClient) Recv() (*SetActiveUpstreamReply, error) {
+	m := new(SetActiveUpstreamReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) GetServedPortsState(ctx context.Context, in *GetServedPortsStateRequest, opts ...grpc.CallOption) (Agent_GetServedPortsStateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[7], "/eleven.agent.Agent/GetServedPortsState", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentGetServedPortsStateClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_GetServedPortsStateClient interface {
+	Recv() (*GetServedPortsStateReply, error)
+	grpc.ClientStream
+}
+
+type agentGetServedPortsStateClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentGetServedPortsStateClient) Recv() (*GetServedPortsStateReply, error) {
+	m := new(GetServedPortsStateReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) ListInspectedRequests(ctx context.Context, in *ListInspectedRequestsRequest, opts ...grpc.CallOption) (Agent_ListInspectedRequestsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[8], "/eleven.agent.Agent/ListInspectedRequests", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentListInspectedRequestsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_ListInspectedRequestsClient interface {
+	Recv() (*ListInspectedRequestsReply, error)
+	grpc.ClientStream
+}
+
+type agentListInspectedRequestsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentListInspectedRequestsClient) Recv() (*ListInspectedRequestsReply, error) {
+	m := new(ListInspectedRequestsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) StreamInspectedRequests(ctx context.Context, in *StreamInspectedRequestsRequest, opts ...grpc.CallOption) (Agent_StreamInspectedRequestsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[9], "/eleven.agent.Agent/StreamInspectedRequests", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentStreamInspectedRequestsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_StreamInspectedRequestsClient interface {
+	Recv() (*StreamInspectedRequestsReply, error)
+	grpc.ClientStream
+}
+
+type agentStreamInspectedRequestsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentStreamInspectedRequestsClient) Recv() (*StreamInspectedRequestsReply, error) {
+	m := new(StreamInspectedRequestsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) ReplayRequest(ctx context.Context, in *ReplayRequestRequest, opts ...grpc.CallOption) (Agent_ReplayRequestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[10], "/eleven.agent.Agent/ReplayRequest", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentReplayRequestClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_ReplayRequestClient interface {
+	Recv() (*ReplayRequestReply, error)
+	grpc.ClientStream
+}
+
+type agentReplayRequestClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentReplayRequestClient) Recv() (*ReplayRequestReply, error) {
+	m := new(ReplayRequestReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) SetNetworkConditions(ctx context.Context, in *SetNetworkConditionsRequest, opts ...grpc.CallOption) (Agent_SetNetworkConditionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[11], "/eleven.agent.Agent/SetNetworkConditions", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentSetNetworkConditionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_SetNetworkConditionsClient interface {
+	Recv() (*SetNetworkConditionsReply, error)
+	grpc.ClientStream
+}
+
+type agentSetNetworkConditionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentSetNetworkConditionsClient) Recv() (*SetNetworkConditionsReply, error) {
+	m := new(SetNetworkConditionsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) ListProxyConnections(ctx context.Context, in *ListProxyConnectionsRequest, opts ...grpc.CallOption) (Agent_ListProxyConnectionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[12], "/eleven.agent.Agent/ListProxyConnections", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentListProxyConnectionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_ListProxyConnectionsClient interface {
+	Recv() (*ListProxyConnectionsReply, error)
+	grpc.ClientStream
+}
+
+type agentListProxyConnectionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentListProxyConnectionsClient) Recv() (*ListProxyConnectionsReply, error) {
+	m := new(ListProxyConnectionsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) CloseProxyConnection(ctx context.Context, in *CloseProxyConnectionRequest, opts ...grpc.CallOption) (Agent_CloseProxyConnectionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[13], "/eleven.agent.Agent/CloseProxyConnection", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentCloseProxyConnectionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_CloseProxyConnectionClient interface {
+	Recv() (*CloseProxyConnectionReply, error)
+	grpc.ClientStream
+}
+
+type agentCloseProxyConnectionClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentCloseProxyConnectionClient) Recv() (*CloseProxyConnectionReply, error) {
+	m := new(CloseProxyConnectionReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) WatchListeningPorts(ctx context.Context, in *WatchListeningPortsRequest, opts ...grpc.CallOption) (Agent_WatchListeningPortsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[14], "/eleven.agent.Agent/WatchListeningPorts", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentWatchListeningPortsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_WatchListeningPortsClient interface {
+	Recv() (*WatchListeningPortsReply, error)
+	grpc.ClientStream
+}
+
+type agentWatchListeningPortsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentWatchListeningPortsClient) Recv() (*WatchListeningPortsReply, error) {
+	m := new(WatchListeningPortsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) ListSessionRecordings(ctx context.Context, in *ListSessionRecordingsRequest, opts ...grpc.CallOption) (Agent_ListSessionRecordingsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[15], "/eleven.agent.Agent/ListSessionRecordings", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentListSessionRecordingsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_ListSessionRecordingsClient interface {
+	Recv() (*ListSessionRecordingsReply, error)
+	grpc.ClientStream
+}
+
+type agentListSessionRecordingsClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentListSessionRecordingsClient) Recv() (*ListSessionRecordingsReply, error) {
+	m := new(ListSessionRecordingsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) StreamSessionRecording(ctx context.Context, in *StreamSessionRecordingRequest, opts ...grpc.CallOption) (Agent_StreamSessionRecordingClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[16], "/eleven.agent.Agent/StreamSessionRecording", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentStreamSessionRecordingClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_StreamSessionRecordingClient interface {
+	Recv() (*StreamSessionRecordingReply, error)
+	grpc.ClientStream
+}
+
+type agentStreamSessionRecordingClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentStreamSessionRecordingClient) Recv() (*StreamSessionRecordingReply, error) {
+	m := new(StreamSessionRecordingReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) DeleteSessionRecording(ctx context.Context, in *DeleteSessionRecordingRequest, opts ...grpc.CallOption) (Agent_DeleteSessionRecordingClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[17], "/eleven.agent.Agent/DeleteSessionRecording", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentDeleteSessionRecordingClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_DeleteSessionRecordingClient interface {
+	Recv() (*DeleteSessionRecordingReply, error)
+	grpc.ClientStream
+}
+
+type agentDeleteSessionRecordingClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentDeleteSessionRecordingClient) Recv() (*DeleteSessionRecordingReply, error) {
+	m := new(DeleteSessionRecordingReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *agentClient) ValidateAuthorizedKeys(ctx context.Context, in *ValidateAuthorizedKeysRequest, opts ...grpc.CallOption) (Agent_ValidateAuthorizedKeysClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Agent_ServiceDesc.Streams[18], "/eleven.agent.Agent/ValidateAuthorizedKeys", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &agentValidateAuthorizedKeysClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Agent_ValidateAuthorizedKeysClient interface {
+	Recv() (*ValidateAuthorizedKeysReply, error)
+	grpc.ClientStream
+}
+
+type agentValidateAuthorizedKeysClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentValidateAuthorizedKeysClient) Recv() (*ValidateAuthorizedKeysReply, error) {
+	m := new(ValidateAuthorizedKeysReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // AgentServer is the server API for Agent service.
 // All implementations must embed UnimplementedAgentServer
 // for forward compatibility
@@ -203,6 +665,20 @@ type AgentServer interface {
 	CheckDomainReachability(*CheckDomainReachabilityRequest, Agent_CheckDomainReachabilityServer) error
 	ReconcileServedPortsState(*ReconcileServedPortsStateRequest, Agent_ReconcileServedPortsStateServer) error
 	TryToStartLongRunningProcess(*TryToStartLongRunningProcessRequest, Agent_TryToStartLongRunningProcessServer) error
+	StreamAccessLogs(*StreamAccessLogsRequest, Agent_StreamAccessLogsServer) error
+	SetActiveUpstream(*SetActiveUpstreamRequest, Agent_SetActiveUpstreamServer) error
+	GetServedPortsState(*GetServedPortsStateRequest, Agent_GetServedPortsStateServer) error
+	ListInspectedRequests(*ListInspectedRequestsRequest, Agent_ListInspectedRequestsServer) error
+	StreamInspectedRequests(*StreamInspectedRequestsRequest, Agent_StreamInspectedRequestsServer) error
+	ReplayRequest(*ReplayRequestRequest, Agent_ReplayRequestServer) error
+	SetNetworkConditions(*SetNetworkConditionsRequest, Agent_SetNetworkConditionsServer) error
+	ListProxyConnections(*ListProxyConnectionsRequest, Agent_ListProxyConnectionsServer) error
+	CloseProxyConnection(*CloseProxyConnectionRequest, Agent_CloseProxyConnectionServer) error
+	WatchListeningPorts(*WatchListeningPortsRequest, Agent_WatchListeningPortsServer) error
+	ListSessionRecordings(*ListSessionRecordingsRequest, Agent_ListSessionRecordingsServer) error
+	StreamSessionRecording(*StreamSessionRecordingRequest, Agent_StreamSessionRecordingServer) error
+	DeleteSessionRecording(*DeleteSessionRecordingRequest, Agent_DeleteSessionRecordingServer) error
+	ValidateAuthorizedKeys(*ValidateAuthorizedKeysRequest, Agent_ValidateAuthorizedKeysServer) error
 	mustEmbedUnimplementedAgentServer()
 }
 
@@ -225,6 +701,48 @@ func (UnimplementedAgentServer) ReconcileServedPortsState(*ReconcileServedPortsS
 func (UnimplementedAgentServer) TryToStartLongRunningProcess(*TryToStartLongRunningProcessRequest, Agent_TryToStartLongRunningProcessServer) error {
 	return status.Errorf(codes.Unimplemented, "method TryToStartLongRunningProcess not implemented")
 }
+func (UnimplementedAgentServer) StreamAccessLogs(*StreamAccessLogsRequest, Agent_StreamAccessLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamAccessLogs not implemented")
+}
+func (UnimplementedAgentServer) SetActiveUpstream(*SetActiveUpstreamRequest, Agent_SetActiveUpstreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SetActiveUpstream not implemented")
+}
+func (UnimplementedAgentServer) GetServedPortsState(*GetServedPortsStateRequest, Agent_GetServedPortsStateServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetServedPortsState not implemented")
+}
+func (UnimplementedAgentServer) ListInspectedRequests(*ListInspectedRequestsRequest, Agent_ListInspectedRequestsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListInspectedRequests not implemented")
+}
+func (UnimplementedAgentServer) StreamInspectedRequests(*StreamInspectedRequestsRequest, Agent_StreamInspectedRequestsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamInspectedRequests not implemented")
+}
+func (UnimplementedAgentServer) ReplayRequest(*ReplayRequestRequest, Agent_ReplayRequestServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReplayRequest not implemented")
+}
+func (UnimplementedAgentServer) SetNetworkConditions(*SetNetworkConditionsRequest, Agent_SetNetworkConditionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SetNetworkConditions not implemented")
+}
+func (UnimplementedAgentServer) ListProxyConnections(*ListProxyConnectionsRequest, Agent_ListProxyConnectionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListProxyConnections not implemented")
+}
+func (UnimplementedAgentServer) CloseProxyConnection(*CloseProxyConnectionRequest, Agent_CloseProxyConnectionServer) error {
+	return status.Errorf(codes.Unimplemented, "method CloseProxyConnection not implemented")
+}
+func (UnimplementedAgentServer) WatchListeningPorts(*WatchListeningPortsRequest, Agent_WatchListeningPortsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchListeningPorts not implemented")
+}
+func (UnimplementedAgentServer) ListSessionRecordings(*ListSessionRecordingsRequest, Agent_ListSessionRecordingsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListSessionRecordings not implemented")
+}
+func (UnimplementedAgentServer) StreamSessionRecording(*StreamSessionRecordingRequest, Agent_StreamSessionRecordingServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSessionRecording not implemented")
+}
+func (UnimplementedAgentServer) DeleteSessionRecording(*DeleteSessionRecordingRequest, Agent_DeleteSessionRecordingServer) error {
+	return status.Errorf(codes.Unimplemented, "method DeleteSessionRecording not implemented")
+}
+func (UnimplementedAgentServer) ValidateAuthorizedKeys(*ValidateAuthorizedKeysRequest, Agent_ValidateAuthorizedKeysServer) error {
+	return status.Errorf(codes.Unimplemented, "method ValidateAuthorizedKeys not implemented")
+}
 func (UnimplementedAgentServer) mustEmbedUnimplementedAgentServer() {}
 
 // UnsafeAgentServer may be embedded to opt out of forward compatibility for this service.
@@ -343,6 +861,300 @@ func (x *agentTryToStartLongRunningProcessServer) Send(m *TryToStartLongRunningP
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Agent_StreamAccessLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamAccessLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).StreamAccessLogs(m, &agentStreamAccessLogsServer{stream})
+}
+
+type Agent_StreamAccessLogsServer interface {
+	Send(*StreamAccessLogsReply) error
+	grpc.ServerStream
+}
+
+type agentStreamAccessLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentStreamAccessLogsServer) Send(m *StreamAccessLogsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_SetActiveUpstream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SetActiveUpstreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).SetActiveUpstream(m, &agentSetActiveUpstreamServer{stream})
+}
+
+type Agent_SetActiveUpstreamServer interface {
+	Send(*SetActiveUpstreamReply) error
+	grpc.ServerStream
+}
+
+type agentSetActiveUpstreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentSetActiveUpstreamServer) Send(m *SetActiveUpstreamReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_GetServedPortsState_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetServedPortsStateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).GetServedPortsState(m, &agentGetServedPortsStateServer{stream})
+}
+
+type Agent_GetServedPortsStateServer interface {
+	Send(*GetServedPortsStateReply) error
+	grpc.ServerStream
+}
+
+type agentGetServedPortsStateServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentGetServedPortsStateServer) Send(m *GetServedPortsStateReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_ListInspectedRequests_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListInspectedRequestsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).ListInspectedRequests(m, &agentListInspectedRequestsServer{stream})
+}
+
+type Agent_ListInspectedRequestsServer interface {
+	Send(*ListInspectedRequestsReply) error
+	grpc.ServerStream
+}
+
+type agentListInspectedRequestsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentListInspectedRequestsServer) Send(m *ListInspectedRequestsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_StreamInspectedRequests_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamInspectedRequestsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).StreamInspectedRequests(m, &agentStreamInspectedRequestsServer{stream})
+}
+
+type Agent_StreamInspectedRequestsServer interface {
+	Send(*StreamInspectedRequestsReply) error
+	grpc.ServerStream
+}
+
+type agentStreamInspectedRequestsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentStreamInspectedRequestsServer) Send(m *StreamInspectedRequestsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_ReplayRequest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReplayRequestRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).ReplayRequest(m, &agentReplayRequestServer{stream})
+}
+
+type Agent_ReplayRequestServer interface {
+	Send(*ReplayRequestReply) error
+	grpc.ServerStream
+}
+
+type agentReplayRequestServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentReplayRequestServer) Send(m *ReplayRequestReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_SetNetworkConditions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SetNetworkConditionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).SetNetworkConditions(m, &agentSetNetworkConditionsServer{stream})
+}
+
+type Agent_SetNetworkConditionsServer interface {
+	Send(*SetNetworkConditionsReply) error
+	grpc.ServerStream
+}
+
+type agentSetNetworkConditionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentSetNetworkConditionsServer) Send(m *SetNetworkConditionsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_ListProxyConnections_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListProxyConnectionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).ListProxyConnections(m, &agentListProxyConnectionsServer{stream})
+}
+
+type Agent_ListProxyConnectionsServer interface {
+	Send(*ListProxyConnectionsReply) error
+	grpc.ServerStream
+}
+
+type agentListProxyConnectionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentListProxyConnectionsServer) Send(m *ListProxyConnectionsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_CloseProxyConnection_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CloseProxyConnectionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).CloseProxyConnection(m, &agentCloseProxyConnectionServer{stream})
+}
+
+type Agent_CloseProxyConnectionServer interface {
+	Send(*CloseProxyConnectionReply) error
+	grpc.ServerStream
+}
+
+type agentCloseProxyConnectionServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentCloseProxyConnectionServer) Send(m *CloseProxyConnectionReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_WatchListeningPorts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchListeningPortsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).WatchListeningPorts(m, &agentWatchListeningPortsServer{stream})
+}
+
+type Agent_WatchListeningPortsServer interface {
+	Send(*WatchListeningPortsReply) error
+	grpc.ServerStream
+}
+
+type agentWatchListeningPortsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentWatchListeningPortsServer) Send(m *WatchListeningPortsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_ListSessionRecordings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListSessionRecordingsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).ListSessionRecordings(m, &agentListSessionRecordingsServer{stream})
+}
+
+type Agent_ListSessionRecordingsServer interface {
+	Send(*ListSessionRecordingsReply) error
+	grpc.ServerStream
+}
+
+type agentListSessionRecordingsServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentListSessionRecordingsServer) Send(m *ListSessionRecordingsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_StreamSessionRecording_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSessionRecordingRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).StreamSessionRecording(m, &agentStreamSessionRecordingServer{stream})
+}
+
+type Agent_StreamSessionRecordingServer interface {
+	Send(*StreamSessionRecordingReply) error
+	grpc.ServerStream
+}
+
+type agentStreamSessionRecordingServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentStreamSessionRecordingServer) Send(m *StreamSessionRecordingReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_DeleteSessionRecording_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DeleteSessionRecordingRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).DeleteSessionRecording(m, &agentDeleteSessionRecordingServer{stream})
+}
+
+type Agent_DeleteSessionRecordingServer interface {
+	Send(*DeleteSessionRecordingReply) error
+	grpc.ServerStream
+}
+
+type agentDeleteSessionRecordingServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentDeleteSessionRecordingServer) Send(m *DeleteSessionRecordingReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Agent_ValidateAuthorizedKeys_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ValidateAuthorizedKeysRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AgentServer).ValidateAuthorizedKeys(m, &agentValidateAuthorizedKeysServer{stream})
+}
+
+type Agent_ValidateAuthorizedKeysServer interface {
+	Send(*ValidateAuthorizedKeysReply) error
+	grpc.ServerStream
+}
+
+type agentValidateAuthorizedKeysServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentValidateAuthorizedKeysServer) Send(m *ValidateAuthorizedKeysReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Agent_ServiceDesc is the grpc.ServiceDesc for Agent service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -376,6 +1188,76 @@ var Agent_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Agent_TryToStartLongRunningProcess_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamAccessLogs",
+			Handler:       _Agent_StreamAccessLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SetActiveUpstream",
+			Handler:       _Agent_SetActiveUpstream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetServedPortsState",
+			Handler:       _Agent_GetServedPortsState_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListInspectedRequests",
+			Handler:       _Agent_ListInspectedRequests_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamInspectedRequests",
+			Handler:       _Agent_StreamInspectedRequests_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ReplayRequest",
+			Handler:       _Agent_ReplayRequest_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SetNetworkConditions",
+			Handler:       _Agent_SetNetworkConditions_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListProxyConnections",
+			Handler:       _Agent_ListProxyConnections_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "CloseProxyConnection",
+			Handler:       _Agent_CloseProxyConnection_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchListeningPorts",
+			Handler:       _Agent_WatchListeningPorts_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListSessionRecordings",
+			Handler:       _Agent_ListSessionRecordings_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamSessionRecording",
+			Handler:       _Agent_StreamSessionRecording_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DeleteSessionRecording",
+			Handler:       _Agent_DeleteSessionRecording_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ValidateAuthorizedKeys",
+			Handler:       _Agent_ValidateAuthorizedKeys_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "agent.proto",
 }